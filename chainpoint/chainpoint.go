@@ -0,0 +1,130 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package chainpoint converts Merkle paths to and from Chainpoint receipts.
+//
+// It supports Chainpoint v2 JSON-LD receipts and the operations lists of
+// Chainpoint v3 proofs, including the double SHA-256 operations of their
+// bitcoin branches. Only hashers that compute the hash of a node as
+// H(left || right) can be expressed in these formats.
+package chainpoint
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha3"
+	"crypto/sha512"
+	"hash"
+
+	"github.com/stratumn/merkle/types"
+)
+
+// Contexts of the JSON-LD documents.
+const (
+	ContextV2 = "https://w3id.org/chainpoint/v2"
+	ContextV3 = "https://w3id.org/chainpoint/v3"
+)
+
+var (
+	sha224Hasher   = types.NewHasher(sha256.New224)
+	sha384Hasher   = types.NewHasher(sha512.New384)
+	sha512Hasher   = types.NewHasher(sha512.New)
+	sha3224Hasher  = types.NewHasher(func() hash.Hash { return sha3.New224() })
+	sha3384Hasher  = types.NewHasher(func() hash.Hash { return sha3.New384() })
+	sha3512Hasher  = types.NewHasher(func() hash.Hash { return sha3.New512() })
+	receiptHashers = map[string]types.Hasher{
+		TypeSHA224:   sha224Hasher,
+		TypeSHA256:   types.SHA256,
+		TypeSHA384:   sha384Hasher,
+		TypeSHA512:   sha512Hasher,
+		TypeSHA3_224: sha3224Hasher,
+		TypeSHA3_256: types.SHA3_256,
+		TypeSHA3_384: sha3384Hasher,
+		TypeSHA3_512: sha3512Hasher,
+	}
+	opHashers = map[string]types.Hasher{
+		OpSHA224:   sha224Hasher,
+		OpSHA256:   types.SHA256,
+		OpSHA384:   sha384Hasher,
+		OpSHA512:   sha512Hasher,
+		OpSHA3_224: sha3224Hasher,
+		OpSHA3_256: types.SHA3_256,
+		OpSHA3_384: sha3384Hasher,
+		OpSHA3_512: sha3512Hasher,
+	}
+)
+
+// A step of a path, where sibling is the hash of the sibling of the node and
+// isLeft tells whether the sibling is on the left.
+type step struct {
+	sibling []byte
+	isLeft  bool
+}
+
+// Converts a path starting at the given leaf to a list of steps.
+func pathSteps(leaf []byte, path types.Path, hasher types.Hasher) ([]step, error) {
+	if err := path.ValidateWith(hasher); err != nil {
+		return nil, err
+	}
+
+	var (
+		steps = make([]step, len(path))
+		node  = leaf
+	)
+
+	for i, h := range path {
+		switch {
+		case bytes.Equal(h.Left, node):
+			steps[i] = step{sibling: h.Right}
+		case bytes.Equal(h.Right, node):
+			steps[i] = step{sibling: h.Left, isLeft: true}
+		case i == 0:
+			return nil, types.ErrLeafMismatch
+		default:
+			return nil, types.ErrIndexMismatch
+		}
+		node = h.Parent
+	}
+
+	return steps, nil
+}
+
+// Converts a list of steps starting at the given leaf to a path.
+func stepsPath(leaf []byte, steps []step, hasher types.Hasher) types.Path {
+	var (
+		path = make(types.Path, len(steps))
+		node = leaf
+	)
+
+	for i, s := range steps {
+		h := &path[i]
+		if s.isLeft {
+			h.Left, h.Right = s.sibling, node
+		} else {
+			h.Left, h.Right = node, s.sibling
+		}
+		h.Parent = hasher.HashNode(h.Left, h.Right)
+		node = h.Parent
+	}
+
+	return path
+}
+
+// Returns the root of a path starting at the given leaf.
+func pathRoot(leaf []byte, path types.Path) []byte {
+	if len(path) == 0 {
+		return leaf
+	}
+	return path[len(path)-1].Parent
+}
@@ -0,0 +1,324 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chainpoint
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/stratumn/merkle/types"
+)
+
+// TypeV3 is the type of Chainpoint v3 proofs.
+const TypeV3 = "Chainpoint"
+
+// Hash operations of Chainpoint v3 proofs.
+const (
+	OpSHA224   = "sha-224"
+	OpSHA256   = "sha-256"
+	OpSHA384   = "sha-384"
+	OpSHA512   = "sha-512"
+	OpSHA3_224 = "sha3-224"
+	OpSHA3_256 = "sha3-256"
+	OpSHA3_384 = "sha3-384"
+	OpSHA3_512 = "sha3-512"
+
+	// OpSHA256x2 hashes twice with SHA-256, like the Merkle trees of bitcoin
+	// blocks. It is only used by bitcoin branches.
+	OpSHA256x2 = "sha-256-x2"
+)
+
+// ErrBTCBranch is returned when a path is requested for a bitcoin branch.
+var ErrBTCBranch = errors.New("bitcoin branches using double SHA-256 cannot be converted to a path")
+
+// BranchAnchor is where the result of the operations of a branch of a
+// Chainpoint v3 proof is anchored.
+type BranchAnchor struct {
+	Type     string   `json:"type"`
+	AnchorID string   `json:"anchor_id"`
+	URIs     []string `json:"uris,omitempty"`
+}
+
+// Op is an operation of a Chainpoint v3 proof. L and R respectively prepend
+// and append a value to the current hash, Op hashes it and Anchors lists the
+// anchors of the current hash.
+type Op struct {
+	L       string         `json:"l,omitempty"`
+	R       string         `json:"r,omitempty"`
+	Op      string         `json:"op,omitempty"`
+	Anchors []BranchAnchor `json:"anchors,omitempty"`
+}
+
+// Branch is a list of operations of a Chainpoint v3 proof. Operations of
+// nested branches start from the result of their parent branch.
+type Branch struct {
+	Label    string   `json:"label,omitempty"`
+	Ops      []Op     `json:"ops"`
+	Branches []Branch `json:"branches,omitempty"`
+}
+
+// isBTC tells whether the branch anchors to bitcoin, which hashes with double
+// SHA-256 and wraps the hash in a transaction.
+func (b *Branch) isBTC() bool {
+	for _, o := range b.Ops {
+		if o.Op == OpSHA256x2 {
+			return true
+		}
+	}
+	return false
+}
+
+// AnchoredHash is the hash of a Chainpoint v3 proof at one of its anchors.
+type AnchoredHash struct {
+	Anchor BranchAnchor
+	Hash   []byte
+}
+
+// Proof is a Chainpoint v3 proof.
+type Proof struct {
+	Context             string   `json:"@context"`
+	Type                string   `json:"type"`
+	Hash                string   `json:"hash"`
+	HashIDNode          string   `json:"hash_id_node,omitempty"`
+	HashSubmittedNodeAt string   `json:"hash_submitted_node_at,omitempty"`
+	HashIDCore          string   `json:"hash_id_core,omitempty"`
+	HashSubmittedCoreAt string   `json:"hash_submitted_core_at,omitempty"`
+	Branches            []Branch `json:"branches"`
+}
+
+// NewProof creates a Chainpoint v3 proof with a single branch from the path of
+// a leaf hash. The hash operation must match the hash function of the tree.
+func NewProof(op string, leaf []byte, path types.Path, label string, anchors []BranchAnchor) (*Proof, error) {
+	hasher, ok := opHashers[op]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash operation %q", op)
+	}
+
+	steps, err := pathSteps(leaf, path, hasher)
+	if err != nil {
+		return nil, err
+	}
+
+	branch := Branch{Label: label, Ops: make([]Op, 0, 2*len(steps)+1)}
+
+	for _, s := range steps {
+		if s.isLeft {
+			branch.Ops = append(branch.Ops, Op{L: hex.EncodeToString(s.sibling)})
+		} else {
+			branch.Ops = append(branch.Ops, Op{R: hex.EncodeToString(s.sibling)})
+		}
+		branch.Ops = append(branch.Ops, Op{Op: op})
+	}
+
+	if len(anchors) > 0 {
+		branch.Ops = append(branch.Ops, Op{Anchors: anchors})
+	}
+
+	return &Proof{
+		Context:  ContextV3,
+		Type:     TypeV3,
+		Hash:     hex.EncodeToString(leaf),
+		Branches: []Branch{branch},
+	}, nil
+}
+
+// ParseProof parses a Chainpoint v3 proof.
+func ParseProof(data []byte) (*Proof, error) {
+	var p Proof
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	if p.Context != ContextV3 {
+		return nil, fmt.Errorf("unsupported proof context %q", p.Context)
+	}
+	if p.Type != TypeV3 {
+		return nil, fmt.Errorf("unsupported proof type %q", p.Type)
+	}
+	if _, _, err := p.Path(); err != nil {
+		return nil, err
+	}
+	if _, err := p.Anchors(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Path converts the operations of the first branch of the proof, and of the
+// first branch nested in each branch, to a path starting at the hash of the
+// proof. It also returns the hasher matching the hash operations.
+//
+// Each hash operation must be preceded by exactly one L or R operation, and
+// all hash operations must use the same hash function.
+//
+// Bitcoin branches, such as the btc_anchor_branch nested in the calendar
+// branch of proofs issued by Chainpoint nodes, are skipped: the path then ends
+// at the calendar anchor. Use Anchors to check the bitcoin anchors. If the
+// first branch of the proof is a bitcoin branch, ErrBTCBranch is returned.
+func (p *Proof) Path() (types.Path, types.Hasher, error) {
+	leaf, err := hex.DecodeString(p.Hash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		steps   []step
+		hashOp  string
+		pending *step
+	)
+
+	if len(p.Branches) > 0 && p.Branches[0].isBTC() {
+		return nil, nil, ErrBTCBranch
+	}
+
+	for branches := p.Branches; len(branches) > 0 && !branches[0].isBTC(); branches = branches[0].Branches {
+		for _, o := range branches[0].Ops {
+			switch {
+			case o.L != "" || o.R != "":
+				if pending != nil || o.L != "" && o.R != "" {
+					return nil, nil, errors.New("operations should alternate values and hashes")
+				}
+				pending = &step{sibling: opValue(o.L + o.R), isLeft: o.L != ""}
+			case o.Op != "":
+				if pending == nil {
+					return nil, nil, errors.New("operations should alternate values and hashes")
+				}
+				if hashOp != "" && o.Op != hashOp {
+					return nil, nil, errors.New("operations should use the same hash function")
+				}
+				hashOp = o.Op
+				steps = append(steps, *pending)
+				pending = nil
+			}
+		}
+	}
+
+	if pending != nil {
+		return nil, nil, errors.New("operations should end with a hash")
+	}
+
+	if hashOp == "" {
+		hashOp = OpSHA256
+	}
+
+	hasher, ok := opHashers[hashOp]
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported hash operation %q", hashOp)
+	}
+
+	return stepsPath(leaf, steps, hasher), hasher, nil
+}
+
+// Verify checks that the operations of the proof go from the hash of the proof
+// to the given Merkle root.
+func (p *Proof) Verify(root []byte) error {
+	path, _, err := p.Path()
+	if err != nil {
+		return err
+	}
+
+	leaf, err := hex.DecodeString(p.Hash)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(pathRoot(leaf, path), root) {
+		return types.ErrRootMismatch
+	}
+
+	return nil
+}
+
+// Anchors runs the operations of all the branches of the proof, including
+// bitcoin branches, and returns the hash at each anchor.
+//
+// Unlike Path, it allows several L and R operations before a hash operation,
+// as in the transaction of a bitcoin branch. The hash of a bitcoin anchor is
+// the Merkle root of the block in the byte order of the block header, which is
+// the reverse of the order in which block explorers usually display it.
+func (p *Proof) Anchors() ([]AnchoredHash, error) {
+	leaf, err := hex.DecodeString(p.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	var anchored []AnchoredHash
+	if err := runBranches(leaf, p.Branches, &anchored); err != nil {
+		return nil, err
+	}
+
+	return anchored, nil
+}
+
+// Runs the operations of branches starting from the given hash, appending the
+// hashes at anchors.
+func runBranches(hash []byte, branches []Branch, anchored *[]AnchoredHash) error {
+	for _, b := range branches {
+		h := hash
+		for _, o := range b.Ops {
+			switch {
+			case o.L != "" && o.R != "":
+				return errors.New("operations should have a single value")
+			case o.L != "":
+				h = append(opValue(o.L), h...)
+			case o.R != "":
+				h = append(append([]byte{}, h...), opValue(o.R)...)
+			case o.Op != "":
+				sum, err := opHash(o.Op, h)
+				if err != nil {
+					return err
+				}
+				h = sum
+			}
+			for _, a := range o.Anchors {
+				*anchored = append(*anchored, AnchoredHash{Anchor: a, Hash: h})
+			}
+		}
+		if err := runBranches(h, b.Branches, anchored); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Hashes data with a hash operation.
+func opHash(op string, data []byte) ([]byte, error) {
+	if op == OpSHA256x2 {
+		sum := sha256.Sum256(data)
+		sum = sha256.Sum256(sum[:])
+		return sum[:], nil
+	}
+
+	hasher, ok := opHashers[op].(types.DataHasher)
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash operation %q", op)
+	}
+
+	h := hasher.HashFunc()()
+	h.Write(data)
+	return h.Sum(nil), nil
+}
+
+// Decodes the value of an L or R operation, which is either a hex string or
+// a UTF-8 string.
+func opValue(s string) []byte {
+	if b, err := hex.DecodeString(s); err == nil {
+		return b
+	}
+	return []byte(s)
+}
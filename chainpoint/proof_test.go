@@ -0,0 +1,215 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chainpoint_test
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stratumn/merkle/chainpoint"
+	"github.com/stratumn/merkle/types"
+)
+
+func TestNewProof(t *testing.T) {
+	var (
+		tree    = newTree(t, "abcde")
+		anchors = []chainpoint.BranchAnchor{{
+			Type:     "cal",
+			AnchorID: "985635",
+			URIs:     []string{"https://a.chainpoint.org/calendar/985635/hash"},
+		}}
+	)
+
+	p, err := chainpoint.NewProof(chainpoint.OpSHA256, tree.Leaf(4), tree.Path(4), "merkle", anchors)
+	if err != nil {
+		t.Fatalf("chainpoint.NewProof(): err: %s", err)
+	}
+
+	got, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		t.Fatalf("json.MarshalIndent(): err: %s", err)
+	}
+
+	if want := readFile(t, "testdata/proof-abcde-4.json"); string(got)+"\n" != string(want) {
+		t.Errorf("chainpoint.NewProof() = %s\nwant %s", got, want)
+	}
+
+	if err := p.Verify(tree.Root()); err != nil {
+		t.Errorf("p.Verify(): err: %s", err)
+	}
+}
+
+func TestNewProof_Error(t *testing.T) {
+	tree := newTree(t, "abcde")
+
+	if _, err := chainpoint.NewProof("sha-1", tree.Leaf(2), tree.Path(2), "", nil); err == nil {
+		t.Error("chainpoint.NewProof(): err = nil want Error")
+	}
+	if _, err := chainpoint.NewProof(chainpoint.OpSHA256, tree.Leaf(1), tree.Path(2), "", nil); err != types.ErrLeafMismatch {
+		t.Errorf("chainpoint.NewProof(): err = %v want %v", err, types.ErrLeafMismatch)
+	}
+}
+
+func TestParseProof(t *testing.T) {
+	tree := newTree(t, "abcde")
+
+	p, err := chainpoint.ParseProof(readFile(t, "testdata/proof-abcde-4.json"))
+	if err != nil {
+		t.Fatalf("chainpoint.ParseProof(): err: %s", err)
+	}
+
+	path, hasher, err := p.Path()
+	if err != nil {
+		t.Fatalf("p.Path(): err: %s", err)
+	}
+	if got, want := path, tree.Path(4); !reflect.DeepEqual(got, want) {
+		t.Errorf("p.Path() = %v want %v", got, want)
+	}
+	if got, want := hasher, types.SHA256; got != want {
+		t.Errorf("p.Path() hasher = %v want %v", got, want)
+	}
+}
+
+func TestParseProof_example(t *testing.T) {
+	p, err := chainpoint.ParseProof(readFile(t, "testdata/proof-example.json"))
+	if err != nil {
+		t.Fatalf("chainpoint.ParseProof(): err: %s", err)
+	}
+
+	path, hasher, err := p.Path()
+	if err != nil {
+		t.Fatalf("p.Path(): err: %s", err)
+	}
+	if got, want := len(path), 3; got != want {
+		t.Errorf("len(path) = %d want %d", got, want)
+	}
+	if got, want := string(path[0].Left), "node_id:52b8d0a0-4a96-11e8-9a3c-0242ac110002"; got != want {
+		t.Errorf("path[0].Left = %q want %q", got, want)
+	}
+	if err := path.ValidateWith(hasher); err != nil {
+		t.Errorf("path.ValidateWith(): err: %s", err)
+	}
+
+	root, _ := hex.DecodeString("88a95a0908c269814a20c40ef267a5f478ac7742d22aed0e6c80ea2adc658ccd")
+	if err := p.Verify(root); err != nil {
+		t.Errorf("p.Verify(): err: %s", err)
+	}
+	if err := p.Verify(path[0].Parent); err != types.ErrRootMismatch {
+		t.Errorf("p.Verify(): err = %v want %v", err, types.ErrRootMismatch)
+	}
+}
+
+// The fixture follows the layout of the proofs issued by Chainpoint nodes once
+// anchored to bitcoin: a btc_anchor_branch nested in the calendar branch wraps
+// the hash in a transaction and climbs the Merkle tree of the block using
+// double SHA-256.
+func TestParseProof_btc(t *testing.T) {
+	p, err := chainpoint.ParseProof(readFile(t, "testdata/proof-btc.json"))
+	if err != nil {
+		t.Fatalf("chainpoint.ParseProof(): err: %s", err)
+	}
+
+	// The path ends at the calendar anchor.
+	path, _, err := p.Path()
+	if err != nil {
+		t.Fatalf("p.Path(): err: %s", err)
+	}
+	if got, want := len(path), 3; got != want {
+		t.Errorf("len(path) = %d want %d", got, want)
+	}
+
+	calRoot := "88a95a0908c269814a20c40ef267a5f478ac7742d22aed0e6c80ea2adc658ccd"
+	root, _ := hex.DecodeString(calRoot)
+	if err := p.Verify(root); err != nil {
+		t.Errorf("p.Verify(): err: %s", err)
+	}
+
+	anchored, err := p.Anchors()
+	if err != nil {
+		t.Fatalf("p.Anchors(): err: %s", err)
+	}
+	if got, want := len(anchored), 2; got != want {
+		t.Fatalf("len(p.Anchors()) = %d want %d", got, want)
+	}
+
+	tests := []struct {
+		typ  string
+		id   string
+		hash string
+	}{
+		{"cal", "1130513", calRoot},
+		{"btc", "520150", "061ecdf9f642fd4510e8d73d6463052b83813d7cce70b2193922ae2041a0133c"},
+	}
+
+	for i, tt := range tests {
+		a := anchored[i]
+		if a.Anchor.Type != tt.typ || a.Anchor.AnchorID != tt.id {
+			t.Errorf("anchored[%d].Anchor = %v want %s %s", i, a.Anchor, tt.typ, tt.id)
+		}
+		if got, want := hex.EncodeToString(a.Hash), tt.hash; got != want {
+			t.Errorf("anchored[%d].Hash = %q want %q", i, got, want)
+		}
+	}
+}
+
+func TestProof_Path_btc(t *testing.T) {
+	p, err := chainpoint.ParseProof(readFile(t, "testdata/proof-btc.json"))
+	if err != nil {
+		t.Fatalf("chainpoint.ParseProof(): err: %s", err)
+	}
+
+	// Only keep the bitcoin branch.
+	p.Branches = p.Branches[0].Branches[0].Branches
+
+	if _, _, err := p.Path(); err != chainpoint.ErrBTCBranch {
+		t.Errorf("p.Path(): err = %v want %v", err, chainpoint.ErrBTCBranch)
+	}
+	if _, err := p.Anchors(); err != nil {
+		t.Errorf("p.Anchors(): err: %s", err)
+	}
+}
+
+func TestParseProof_Error(t *testing.T) {
+	valid := string(readFile(t, "testdata/proof-example.json"))
+
+	tests := []struct {
+		name string
+		old  string
+		new  string
+	}{
+		{"context", "chainpoint/v3", "chainpoint/v2"},
+		{"type", `"Chainpoint"`, `"Chainpoint2"`},
+		{"hash", "3f79bb7b", "3f79bb7x"},
+		{"op", `"sha-256"`, `"sha-1"`},
+		{"mixed ops", `"sha-256"`, `"sha-512"`},
+		{"missing op", `"op": "sha-256"`, `"r": "00"`},
+		{"missing value", `"l": "node_id`, `"op": "node_id`},
+	}
+
+	btc := string(readFile(t, "testdata/proof-btc.json"))
+	if _, err := chainpoint.ParseProof([]byte(strings.Replace(btc, `"sha-256-x2"`, `"sha-1-x2"`, 1))); err == nil {
+		t.Error("btc op: chainpoint.ParseProof(): err = nil want Error")
+	}
+
+	for _, test := range tests {
+		data := strings.Replace(valid, test.old, test.new, 1)
+		if _, err := chainpoint.ParseProof([]byte(data)); err == nil {
+			t.Errorf("%s: chainpoint.ParseProof(): err = nil want Error", test.name)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chainpoint
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/stratumn/merkle/types"
+)
+
+// Types of Chainpoint v2 receipts.
+const (
+	TypeSHA224   = "ChainpointSHA224v2"
+	TypeSHA256   = "ChainpointSHA256v2"
+	TypeSHA384   = "ChainpointSHA384v2"
+	TypeSHA512   = "ChainpointSHA512v2"
+	TypeSHA3_224 = "ChainpointSHA3-224v2"
+	TypeSHA3_256 = "ChainpointSHA3-256v2"
+	TypeSHA3_384 = "ChainpointSHA3-384v2"
+	TypeSHA3_512 = "ChainpointSHA3-512v2"
+)
+
+// Anchor is where the Merkle root of a Chainpoint v2 receipt is anchored, for
+// instance the identifier of a Bitcoin transaction.
+type Anchor struct {
+	Type     string `json:"type"`
+	SourceID string `json:"sourceId"`
+}
+
+// ReceiptStep is a step of the proof of a Chainpoint v2 receipt. Exactly one
+// of Left and Right contains the hex encoded hash of the sibling.
+type ReceiptStep struct {
+	Left  string `json:"left,omitempty"`
+	Right string `json:"right,omitempty"`
+}
+
+// Receipt is a Chainpoint v2 receipt.
+type Receipt struct {
+	Context    string        `json:"@context"`
+	Type       string        `json:"type"`
+	TargetHash string        `json:"targetHash"`
+	MerkleRoot string        `json:"merkleRoot"`
+	Proof      []ReceiptStep `json:"proof"`
+	Anchors    []Anchor      `json:"anchors"`
+}
+
+// NewReceipt creates a Chainpoint v2 receipt of the given type from the path
+// of a leaf hash. The type must match the hash function of the tree.
+func NewReceipt(typ string, leaf []byte, path types.Path, anchors []Anchor) (*Receipt, error) {
+	hasher, ok := receiptHashers[typ]
+	if !ok {
+		return nil, fmt.Errorf("unsupported receipt type %q", typ)
+	}
+
+	steps, err := pathSteps(leaf, path, hasher)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Receipt{
+		Context:    ContextV2,
+		Type:       typ,
+		TargetHash: hex.EncodeToString(leaf),
+		MerkleRoot: hex.EncodeToString(pathRoot(leaf, path)),
+		Proof:      make([]ReceiptStep, len(steps)),
+		Anchors:    anchors,
+	}
+
+	if r.Anchors == nil {
+		r.Anchors = []Anchor{}
+	}
+
+	for i, s := range steps {
+		if s.isLeft {
+			r.Proof[i].Left = hex.EncodeToString(s.sibling)
+		} else {
+			r.Proof[i].Right = hex.EncodeToString(s.sibling)
+		}
+	}
+
+	return r, nil
+}
+
+// ParseReceipt parses a Chainpoint v2 receipt and checks that it is valid.
+func ParseReceipt(data []byte) (*Receipt, error) {
+	var r Receipt
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	if r.Context != ContextV2 {
+		return nil, fmt.Errorf("unsupported receipt context %q", r.Context)
+	}
+	if err := r.Verify(); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Hasher returns the hasher matching the type of the receipt.
+func (r *Receipt) Hasher() (types.Hasher, error) {
+	hasher, ok := receiptHashers[r.Type]
+	if !ok {
+		return nil, fmt.Errorf("unsupported receipt type %q", r.Type)
+	}
+	return hasher, nil
+}
+
+// Path converts the receipt to a path starting at the target hash.
+func (r *Receipt) Path() (types.Path, error) {
+	hasher, err := r.Hasher()
+	if err != nil {
+		return nil, err
+	}
+
+	leaf, err := hex.DecodeString(r.TargetHash)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := make([]step, len(r.Proof))
+	for i, s := range r.Proof {
+		if (s.Left == "") == (s.Right == "") {
+			return nil, errors.New("receipt step should have either a left or a right hash")
+		}
+		steps[i].isLeft = s.Left != ""
+		if steps[i].sibling, err = hex.DecodeString(s.Left + s.Right); err != nil {
+			return nil, err
+		}
+	}
+
+	return stepsPath(leaf, steps, hasher), nil
+}
+
+// Verify checks that the proof of the receipt goes from the target hash to the
+// Merkle root.
+func (r *Receipt) Verify() error {
+	path, err := r.Path()
+	if err != nil {
+		return err
+	}
+
+	leaf, err := hex.DecodeString(r.TargetHash)
+	if err != nil {
+		return err
+	}
+
+	root, err := hex.DecodeString(r.MerkleRoot)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(pathRoot(leaf, path), root) {
+		return types.ErrRootMismatch
+	}
+
+	return nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chainpoint_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stratumn/merkle"
+	"github.com/stratumn/merkle/chainpoint"
+	"github.com/stratumn/merkle/types"
+)
+
+// Creates a tree whose leaves are the hashes of the given letters.
+func newTree(t *testing.T, letters string) *merkle.StaticTree {
+	leaves := make([][]byte, len(letters))
+	for i := range letters {
+		h := sha256.Sum256([]byte(letters[i : i+1]))
+		leaves[i] = h[:]
+	}
+
+	tree, err := merkle.NewStaticTree(leaves)
+	if err != nil {
+		t.Fatalf("merkle.NewStaticTree(): err: %s", err)
+	}
+	return tree
+}
+
+func readFile(t *testing.T, filename string) []byte {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("ioutil.ReadFile(): err: %s", err)
+	}
+	return data
+}
+
+func TestNewReceipt(t *testing.T) {
+	var (
+		tree    = newTree(t, "abcde")
+		anchors = []chainpoint.Anchor{{
+			Type:     "BTCOpReturn",
+			SourceID: "f3be82fe1b5d8f18e009cb9a491781289d2e01678311fe2b2e4e84381aafadee",
+		}}
+	)
+
+	r, err := chainpoint.NewReceipt(chainpoint.TypeSHA256, tree.Leaf(2), tree.Path(2), anchors)
+	if err != nil {
+		t.Fatalf("chainpoint.NewReceipt(): err: %s", err)
+	}
+
+	got, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		t.Fatalf("json.MarshalIndent(): err: %s", err)
+	}
+
+	if want := readFile(t, "testdata/receipt-abcde-2.json"); string(got)+"\n" != string(want) {
+		t.Errorf("chainpoint.NewReceipt() = %s\nwant %s", got, want)
+	}
+}
+
+func TestNewReceipt_Error(t *testing.T) {
+	tree := newTree(t, "abcde")
+
+	if _, err := chainpoint.NewReceipt("ChainpointSHA1v2", tree.Leaf(2), tree.Path(2), nil); err == nil {
+		t.Error("chainpoint.NewReceipt(): err = nil want Error")
+	}
+	if _, err := chainpoint.NewReceipt(chainpoint.TypeSHA3_256, tree.Leaf(2), tree.Path(2), nil); err == nil {
+		t.Error("chainpoint.NewReceipt(): err = nil want Error")
+	}
+	if _, err := chainpoint.NewReceipt(chainpoint.TypeSHA256, tree.Leaf(1), tree.Path(2), nil); err != types.ErrLeafMismatch {
+		t.Errorf("chainpoint.NewReceipt(): err = %v want %v", err, types.ErrLeafMismatch)
+	}
+}
+
+func TestParseReceipt(t *testing.T) {
+	tree := newTree(t, "abcde")
+
+	r, err := chainpoint.ParseReceipt(readFile(t, "testdata/receipt-abcde-2.json"))
+	if err != nil {
+		t.Fatalf("chainpoint.ParseReceipt(): err: %s", err)
+	}
+
+	path, err := r.Path()
+	if err != nil {
+		t.Fatalf("r.Path(): err: %s", err)
+	}
+	if got, want := path, tree.Path(2); !reflect.DeepEqual(got, want) {
+		t.Errorf("r.Path() = %v want %v", got, want)
+	}
+	if got, want := len(r.Anchors), 1; got != want {
+		t.Errorf("len(r.Anchors) = %d want %d", got, want)
+	}
+}
+
+// The fixture is the example receipt published in the Chainpoint v2
+// specification, whose Merkle root is stored in a bitcoin transaction.
+func TestParseReceipt_example(t *testing.T) {
+	r, err := chainpoint.ParseReceipt(readFile(t, "testdata/receipt-example.json"))
+	if err != nil {
+		t.Fatalf("chainpoint.ParseReceipt(): err: %s", err)
+	}
+
+	path, err := r.Path()
+	if err != nil {
+		t.Fatalf("r.Path(): err: %s", err)
+	}
+	if err := path.Validate(); err != nil {
+		t.Errorf("path.Validate(): err: %s", err)
+	}
+
+	root := "51296468ea48ddbcc546abb85b935c73058fd8acdb0b953da6aa1ae966581a7a"
+	if got := hex.EncodeToString(path[len(path)-1].Parent); got != root {
+		t.Errorf("path root = %q want %q", got, root)
+	}
+
+	want := []chainpoint.Anchor{{
+		Type:     "BTCOpReturn",
+		SourceID: "f3be82fe1b5d8f18e009cb9a491781289d2e01678311fe2b2e4e84381aafadee",
+	}}
+	if got := r.Anchors; !reflect.DeepEqual(got, want) {
+		t.Errorf("r.Anchors = %v want %v", got, want)
+	}
+}
+
+func TestParseReceipt_Error(t *testing.T) {
+	valid := string(readFile(t, "testdata/receipt-abcde-2.json"))
+
+	tests := []struct {
+		name string
+		old  string
+		new  string
+	}{
+		{"context", "chainpoint/v2", "chainpoint/v1"},
+		{"type", "ChainpointSHA256v2", "ChainpointSHA1v2"},
+		{"root", "d71f8983", "d71f8984"},
+		{"target", "2e7d2c03", "2e7d2c04"},
+		{"sibling", "18ac3e73", "18ac3e74"},
+		{"hex", "18ac3e73", "18ac3e7x"},
+		{"step", `"left"`, `"middle"`},
+	}
+
+	for _, test := range tests {
+		data := strings.Replace(valid, test.old, test.new, 1)
+		if _, err := chainpoint.ParseReceipt([]byte(data)); err == nil {
+			t.Errorf("%s: chainpoint.ParseReceipt(): err = nil want Error", test.name)
+		}
+	}
+}
+
+func TestReceipt_singleLeaf(t *testing.T) {
+	tree := newTree(t, "a")
+
+	r, err := chainpoint.NewReceipt(chainpoint.TypeSHA256, tree.Leaf(0), tree.Path(0), nil)
+	if err != nil {
+		t.Fatalf("chainpoint.NewReceipt(): err: %s", err)
+	}
+	if got, want := r.MerkleRoot, r.TargetHash; got != want {
+		t.Errorf("r.MerkleRoot = %q want %q", got, want)
+	}
+	if err := r.Verify(); err != nil {
+		t.Errorf("r.Verify(): err: %s", err)
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command merkle builds Merkle trees and proofs from the command line.
+//
+// Usage:
+//
+//	merkle build [-hash name] [-raw] [file]
+//	merkle prove -i index [-hash name] [-raw] [file]
+//	merkle verify -leaf hex -root hex [-hash name] [-q] [path.json]
+//	merkle seal [-hash name] [-o manifest.json] dir
+//	merkle check -m manifest.json dir
+//
+// The leaves are read from the file, or from the standard input if no file is
+// given, one per line. By default each line is a hex encoded hash. With -raw,
+// each line is hashed to obtain the leaf.
+//
+// The hash algorithm defaults to sha256 and can be the name of any hasher
+// registered in the types package. With rfc6962_sha256, leaves are
+// always hashed with the leaf prefix of RFC 6962, so hex lines are decoded and
+// hashed as leaf data.
+//
+// The verify command checks that a path in JSON, read from the file or from
+// the standard input, goes from the leaf to the root. It prints each step and
+// exits with a non-zero code and the reason if the path does not verify.
+//
+// The seal command hashes the path and the content of each file of a
+// directory and writes a manifest in JSON with the root of the files and a
+// proof for each file. The check command scans the directory again and prints
+// the files that were added, removed or modified since it was sealed.
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/stratumn/merkle"
+	"github.com/stratumn/merkle/types"
+)
+
+// Maximum length of a line of leaves.
+const maxLineLen = 1 << 20
+
+// errUsage is returned when the arguments of a command are invalid. The
+// usage has already been printed.
+var errUsage = errors.New("invalid usage")
+
+// command is a subcommand of the tool.
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(flags *flag.FlagSet, args []string, stdin io.Reader, stdout io.Writer) error
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{"build", "build [-hash name] [-raw] [file]", "print the root of a tree", runBuild},
+		{"prove", "prove -i index [-hash name] [-raw] [file]", "print the path of a leaf as JSON", runProve},
+		{"verify", "verify -leaf hex -root hex [-hash name] [-q] [path.json]", "check the path of a leaf to a root", runVerify},
+		{"seal", "seal [-hash name] [-o manifest.json] dir", "write the manifest of a directory", runSeal},
+		{"check", "check -m manifest.json dir", "compare a directory to its manifest", runCheck},
+	}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// Runs the tool and returns the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) < 1 || args[0] == "help" || args[0] == "-h" || args[0] == "-help" {
+		usage(stderr)
+		return 2
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+
+		flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+		flags.SetOutput(stderr)
+		flags.Usage = func() {
+			fmt.Fprintf(stderr, "usage: merkle %s\n", cmd.usage)
+			flags.PrintDefaults()
+		}
+
+		err := cmd.run(flags, args[1:], stdin, stdout)
+		switch {
+		case err == nil:
+			return 0
+		case err == errUsage:
+			return 2
+		default:
+			fmt.Fprintf(stderr, "merkle %s: %s\n", cmd.name, err)
+			return 1
+		}
+	}
+
+	fmt.Fprintf(stderr, "merkle: unknown command %q\n", args[0])
+	usage(stderr)
+
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: merkle <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+}
+
+// treeFlags are the flags of the commands that build a tree.
+type treeFlags struct {
+	hash string
+	raw  bool
+}
+
+func (f *treeFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&f.hash, "hash", types.NameSHA256, "hash algorithm: "+strings.Join(types.HasherNames(), ", "))
+	flags.BoolVar(&f.raw, "raw", false, "hash each line instead of decoding it as a hex hash")
+}
+
+// Parses the flags and the optional file argument and builds the tree.
+func (f *treeFlags) parse(flags *flag.FlagSet, args []string, stdin io.Reader) (*merkle.StaticTree, error) {
+	// The flag set prints the error and the usage.
+	if err := flags.Parse(args); err != nil {
+		return nil, errUsage
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return nil, errUsage
+	}
+
+	hasher, err := types.HasherByName(f.hash)
+	if err != nil {
+		return nil, err
+	}
+
+	r := stdin
+	if flags.NArg() == 1 {
+		file, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	leaves, err := readLeaves(r, f.hash, f.raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(leaves) < 1 {
+		return nil, errors.New("no leaves")
+	}
+
+	return merkle.NewStaticTree(leaves, merkle.WithHasher(hasher))
+}
+
+func runBuild(flags *flag.FlagSet, args []string, stdin io.Reader, stdout io.Writer) error {
+	var tf treeFlags
+	tf.register(flags)
+
+	tree, err := tf.parse(flags, args, stdin)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(stdout, hex.EncodeToString(tree.Root()))
+	return err
+}
+
+func runProve(flags *flag.FlagSet, args []string, stdin io.Reader, stdout io.Writer) error {
+	var tf treeFlags
+	tf.register(flags)
+	index := flags.Int("i", -1, "index of the leaf")
+
+	tree, err := tf.parse(flags, args, stdin)
+	if err != nil {
+		return err
+	}
+
+	if *index < 0 || *index >= tree.LeavesLen() {
+		return fmt.Errorf("index %d out of range [0, %d)", *index, tree.LeavesLen())
+	}
+
+	js, err := json.MarshalIndent(tree.Path(*index), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(stdout, "%s\n", js)
+	return err
+}
+
+// Reads one leaf per line. Lines are hex encoded hashes, or raw data to hash
+// with the hash function of the given hasher. Blank lines are ignored unless
+// the lines are raw.
+func readLeaves(r io.Reader, hashName string, raw bool) ([][]byte, error) {
+	var leaves [][]byte
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineLen)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+
+		if raw {
+			leaf, err := hashLine(hashName, []byte(text))
+			if err != nil {
+				return nil, err
+			}
+			leaves = append(leaves, leaf)
+			continue
+		}
+
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+
+		leaf, err := hex.DecodeString(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid hex hash: %s", line, err)
+		}
+		if len(leaves) > 0 && len(leaf) != len(leaves[0]) {
+			return nil, fmt.Errorf("line %d: hashes should have the same size", line)
+		}
+		leaves = append(leaves, leaf)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return leaves, nil
+}
+
+// Returns the leaf of a raw line, using the hash function of the hasher
+// registered under the given name.
+func hashLine(hashName string, data []byte) ([]byte, error) {
+	hasher, err := types.HasherByName(hashName)
+	if err != nil {
+		return nil, err
+	}
+
+	dh, ok := hasher.(types.DataHasher)
+	if !ok {
+		return nil, fmt.Errorf("cannot hash raw lines with %q", hashName)
+	}
+	if dh.HashesLeafData() {
+		// The hasher computes the leaf hash from the data.
+		return data, nil
+	}
+
+	h := dh.HashFunc()()
+	h.Write(data)
+
+	return h.Sum(nil), nil
+}
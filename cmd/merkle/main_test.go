@@ -0,0 +1,221 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha3"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stratumn/merkle"
+	"github.com/stratumn/merkle/types"
+)
+
+// Hashes of "a", "b" and "c" using SHA-256.
+const (
+	hashA = "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb"
+	hashB = "3e23e8160039594a33894f6564e1b1348bbd7a0088d42c4acb73eeaed59c009d"
+	hashC = "2e7d2c03a9507ae265ecf5b5356885a53393a2029d241394997265a1a25aefc6"
+)
+
+// runTest runs the tool and returns the exit code and the outputs.
+func runTest(args []string, stdin string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func loadTestPath(t *testing.T, filename string) types.Path {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("ioutil.ReadFile(): err: %s", err)
+	}
+
+	var path types.Path
+	if err := json.Unmarshal(data, &path); err != nil {
+		t.Fatalf("json.Unmarshal(): err: %s", err)
+	}
+
+	return path
+}
+
+func TestBuild(t *testing.T) {
+	want := loadTestPath(t, "../../testdata/path-abc-0.json")
+	root := hex.EncodeToString(want[len(want)-1].Parent)
+
+	tests := []struct {
+		name  string
+		args  []string
+		stdin string
+	}{
+		{"raw", []string{"build", "-raw"}, "a\nb\nc\n"},
+		{"raw CRLF", []string{"build", "-raw"}, "a\r\nb\r\nc"},
+		{"hex", []string{"build"}, hashA + "\n\n" + hashB + "\n  " + hashC + "  \n"},
+		{"hash", []string{"build", "-hash", "sha256"}, hashA + "\n" + hashB + "\n" + hashC + "\n"},
+	}
+
+	for _, tt := range tests {
+		code, stdout, stderr := runTest(tt.args, tt.stdin)
+		if code != 0 {
+			t.Errorf("%s: exit code = %d want 0: %s", tt.name, code, stderr)
+		}
+		if got, want := stdout, root+"\n"; got != want {
+			t.Errorf("%s: stdout = %q want %q", tt.name, got, want)
+		}
+	}
+}
+
+func TestBuild_file(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "leaves")
+	if err := ioutil.WriteFile(filename, []byte("a\nb\n"), 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile(): err: %s", err)
+	}
+
+	want := loadTestPath(t, "../../testdata/path-ab-0.json")
+
+	code, stdout, stderr := runTest([]string{"build", "-raw", filename}, "")
+	if code != 0 {
+		t.Fatalf("exit code = %d want 0: %s", code, stderr)
+	}
+	if got, want := stdout, hex.EncodeToString(want[0].Parent)+"\n"; got != want {
+		t.Errorf("stdout = %q want %q", got, want)
+	}
+}
+
+func TestBuild_hashers(t *testing.T) {
+	lines := []string{"alpha", "beta", "gamma", "delta", "epsilon"}
+
+	// A hasher registered at runtime can be used like the built-in ones.
+	if err := types.RegisterHasher("test_sha224", types.NewHasher(sha256.New224)); err != nil {
+		t.Fatalf("types.RegisterHasher(): err: %s", err)
+	}
+
+	tests := []struct {
+		name string
+		hash func([]byte) []byte
+	}{
+		{"sha256", func(b []byte) []byte { h := sha256.Sum256(b); return h[:] }},
+		{"sha512_256", func(b []byte) []byte { h := sha512.Sum512_256(b); return h[:] }},
+		{"sha3_256", func(b []byte) []byte { h := sha3.Sum256(b); return h[:] }},
+		{"sha1", func(b []byte) []byte { h := sha1.Sum(b); return h[:] }},
+		{"rfc6962_sha256", func(b []byte) []byte { return b }},
+		{"test_sha224", func(b []byte) []byte { h := sha256.Sum224(b); return h[:] }},
+	}
+
+	for _, tt := range tests {
+		hasher, err := types.HasherByName(tt.name)
+		if err != nil {
+			t.Fatalf("types.HasherByName(): err: %s", err)
+		}
+
+		leaves := make([][]byte, len(lines))
+		for i, line := range lines {
+			leaves[i] = tt.hash([]byte(line))
+		}
+		tree, err := merkle.NewStaticTree(leaves, merkle.WithHasher(hasher))
+		if err != nil {
+			t.Fatalf("merkle.NewStaticTree(): err: %s", err)
+		}
+
+		code, stdout, stderr := runTest([]string{"build", "-hash", tt.name, "-raw"}, strings.Join(lines, "\n"))
+		if code != 0 {
+			t.Fatalf("%s: exit code = %d want 0: %s", tt.name, code, stderr)
+		}
+		if got, want := stdout, hex.EncodeToString(tree.Root())+"\n"; got != want {
+			t.Errorf("%s: stdout = %q want %q", tt.name, got, want)
+		}
+	}
+
+	// Check a known RFC 6962 root.
+	code, stdout, _ := runTest([]string{"build", "-hash", "rfc6962_sha256", "-raw"}, "\n")
+	if got, want := stdout, "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d\n"; code != 0 || got != want {
+		t.Errorf("rfc6962_sha256: stdout = %q want %q", got, want)
+	}
+
+	if _, _, stderr := runTest([]string{"build", "-h"}, ""); !strings.Contains(stderr, "test_sha224") {
+		t.Errorf("usage = %q want test_sha224", stderr)
+	}
+}
+
+func TestProve(t *testing.T) {
+	for i, filename := range []string{
+		"../../testdata/path-abcde-0.json",
+		"../../testdata/path-abcde-1.json",
+		"../../testdata/path-abcde-2.json",
+		"../../testdata/path-abcde-3.json",
+		"../../testdata/path-abcde-4.json",
+	} {
+		want := loadTestPath(t, filename)
+
+		code, stdout, stderr := runTest([]string{"prove", "-raw", "-i", strconv.Itoa(i)}, "a\nb\nc\nd\ne\n")
+		if code != 0 {
+			t.Fatalf("exit code = %d want 0: %s", code, stderr)
+		}
+
+		var got types.Path
+		if err := json.Unmarshal([]byte(stdout), &got); err != nil {
+			t.Fatalf("json.Unmarshal(): err: %s", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("prove -i %d = %s want %s", i, stdout, filename)
+		}
+	}
+}
+
+func TestRun_Error(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		stdin  string
+		code   int
+		stderr string
+	}{
+		{"no command", nil, "", 2, "usage"},
+		{"unknown command", []string{"plant"}, "", 2, "unknown command"},
+		{"unknown flag", []string{"build", "-x"}, "", 2, "usage"},
+		{"too many files", []string{"build", "a", "b"}, "", 2, "usage"},
+		{"no leaves", []string{"build"}, "\n", 1, "no leaves"},
+		{"invalid hex", []string{"build"}, hashA + "\nzz\n", 1, "line 2"},
+		{"leaf size", []string{"build"}, hashA + "\nabcd\n", 1, "same size"},
+		{"unknown hash", []string{"build", "-hash", "md5"}, hashA, 1, "unknown hasher"},
+		{"missing file", []string{"build", filepath.Join(os.TempDir(), "does-not-exist")}, "", 1, "no such file"},
+		{"no index", []string{"prove"}, hashA, 1, "out of range"},
+		{"index", []string{"prove", "-i", "1"}, hashA, 1, "out of range"},
+	}
+
+	for _, tt := range tests {
+		code, stdout, stderr := runTest(tt.args, tt.stdin)
+		if code != tt.code {
+			t.Errorf("%s: exit code = %d want %d", tt.name, code, tt.code)
+		}
+		if stdout != "" {
+			t.Errorf("%s: stdout = %q want empty", tt.name, stdout)
+		}
+		if !strings.Contains(stderr, tt.stderr) {
+			t.Errorf("%s: stderr = %q want to contain %q", tt.name, stderr, tt.stderr)
+		}
+	}
+}
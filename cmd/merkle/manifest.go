@@ -0,0 +1,154 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/stratumn/merkle/manifest"
+	"github.com/stratumn/merkle/types"
+)
+
+// errDirMismatch is returned by check when the directory was changed. The
+// changes have already been printed.
+var errDirMismatch = errors.New("directory does not match the manifest")
+
+func runSeal(flags *flag.FlagSet, args []string, stdin io.Reader, stdout io.Writer) error {
+	hashName := flags.String("hash", types.NameSHA256, "hash algorithm: "+strings.Join(types.HasherNames(), ", "))
+	output := flags.String("o", "", "write the manifest to a file and print the root instead")
+
+	// The flag set prints the error and the usage.
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errUsage
+	}
+	dir := flags.Arg(0)
+
+	opts := []manifest.Option{manifest.WithHash(*hashName)}
+	if *output != "" {
+		// Don't seal the manifest itself when it is written in the directory.
+		if rel, ok := relPath(dir, *output); ok {
+			opts = append(opts, manifest.WithExclude(rel))
+		}
+	}
+
+	m, err := manifest.Build(dir, opts...)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if *output == "" {
+		_, err = stdout.Write(data)
+		return err
+	}
+
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(stdout, hex.EncodeToString(m.Root))
+	return err
+}
+
+func runCheck(flags *flag.FlagSet, args []string, stdin io.Reader, stdout io.Writer) error {
+	filename := flags.String("m", "", "manifest file (required)")
+
+	// The flag set prints the error and the usage.
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if flags.NArg() != 1 || *filename == "" {
+		flags.Usage()
+		return errUsage
+	}
+	dir := flags.Arg(0)
+
+	data, err := os.ReadFile(*filename)
+	if err != nil {
+		return err
+	}
+
+	var m manifest.Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	var opts []manifest.Option
+	if rel, ok := relPath(dir, *filename); ok {
+		opts = append(opts, manifest.WithExclude(rel))
+	}
+
+	report, err := manifest.Check(dir, &m, opts...)
+	if err != nil {
+		return err
+	}
+
+	for _, change := range []struct {
+		name  string
+		paths []string
+	}{
+		{"added", report.Added},
+		{"removed", report.Removed},
+		{"modified", report.Modified},
+	} {
+		for _, path := range change.paths {
+			fmt.Fprintf(stdout, "%-8s %s\n", change.name, path)
+		}
+	}
+
+	if !report.OK() {
+		return errDirMismatch
+	}
+
+	_, err = fmt.Fprintln(stdout, "OK", hex.EncodeToString(m.Root))
+	return err
+}
+
+// Returns the slash separated path of a file relative to a directory if the
+// file is inside the directory.
+func relPath(dir, filename string) (string, bool) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	absFile, err := filepath.Abs(filename)
+	if err != nil {
+		return "", false
+	}
+
+	rel, err := filepath.Rel(absDir, absFile)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	return filepath.ToSlash(rel), true
+}
@@ -0,0 +1,127 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stratumn/merkle/manifest"
+)
+
+func writeSealTestDir(t *testing.T) string {
+	dir := t.TempDir()
+	for path, content := range map[string]string{"a.txt": "a", "sub/b.txt": "b"} {
+		filename := filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatalf("os.MkdirAll(): err: %s", err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatalf("os.WriteFile(): err: %s", err)
+		}
+	}
+	return dir
+}
+
+func TestSeal(t *testing.T) {
+	dir := writeSealTestDir(t)
+
+	code, stdout, stderr := runTest([]string{"seal", "-hash", "rfc6962_sha256", dir}, "")
+	if code != 0 {
+		t.Fatalf("exit code = %d want 0: %s", code, stderr)
+	}
+
+	var m manifest.Manifest
+	if err := json.Unmarshal([]byte(stdout), &m); err != nil {
+		t.Fatalf("json.Unmarshal(): err: %s", err)
+	}
+	if err := m.Verify(); err != nil {
+		t.Errorf("m.Verify(): err: %s", err)
+	}
+	if got, want := m.Hash, "rfc6962_sha256"; got != want {
+		t.Errorf("m.Hash = %q want %q", got, want)
+	}
+	if got, want := len(m.Files), 2; got != want {
+		t.Errorf("len(m.Files) = %d want %d", got, want)
+	}
+}
+
+func TestSealCheck(t *testing.T) {
+	var (
+		dir      = writeSealTestDir(t)
+		filename = filepath.Join(dir, "MANIFEST.json")
+	)
+
+	// The manifest is written inside the directory and must be ignored.
+	code, root, stderr := runTest([]string{"seal", "-o", filename, dir}, "")
+	if code != 0 {
+		t.Fatalf("seal: exit code = %d want 0: %s", code, stderr)
+	}
+
+	code, stdout, stderr := runTest([]string{"check", "-m", filename, dir}, "")
+	if code != 0 {
+		t.Fatalf("check: exit code = %d want 0: %s", code, stderr)
+	}
+	if got, want := stdout, "OK "+root; got != want {
+		t.Errorf("check: stdout = %q want %q", got, want)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("A"), 0644); err != nil {
+		t.Fatalf("os.WriteFile(): err: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "c.txt"), []byte("c"), 0644); err != nil {
+		t.Fatalf("os.WriteFile(): err: %s", err)
+	}
+	if err := os.Remove(filepath.Join(dir, "sub", "b.txt")); err != nil {
+		t.Fatalf("os.Remove(): err: %s", err)
+	}
+
+	code, stdout, stderr = runTest([]string{"check", "-m", filename, dir}, "")
+	if code != 1 {
+		t.Errorf("changed: exit code = %d want 1", code)
+	}
+	want := "added    c.txt\nremoved  sub/b.txt\nmodified a.txt\n"
+	if stdout != want {
+		t.Errorf("changed: stdout = %q want %q", stdout, want)
+	}
+	if !strings.Contains(stderr, errDirMismatch.Error()) {
+		t.Errorf("changed: stderr = %q want %q", stderr, errDirMismatch)
+	}
+}
+
+func TestSealCheck_usage(t *testing.T) {
+	dir := writeSealTestDir(t)
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"seal no dir", []string{"seal"}, 2},
+		{"seal unknown hash", []string{"seal", "-hash", "md5", dir}, 1},
+		{"seal empty dir", []string{"seal", t.TempDir()}, 1},
+		{"check no manifest", []string{"check", dir}, 2},
+		{"check missing manifest", []string{"check", "-m", filepath.Join(dir, "missing.json"), dir}, 1},
+	}
+
+	for _, tt := range tests {
+		if code, _, _ := runTest(tt.args, ""); code != tt.code {
+			t.Errorf("%s: exit code = %d want %d", tt.name, code, tt.code)
+		}
+	}
+}
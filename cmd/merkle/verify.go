@@ -0,0 +1,169 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/stratumn/merkle/types"
+)
+
+// Reasons why a path does not verify, in addition to types.ErrLeafMismatch
+// and types.ErrRootMismatch.
+var (
+	errParentMismatch = errors.New("parent hash does not match the hash of its children")
+	errBrokenPath     = errors.New("step does not contain the parent hash of the previous step")
+	errEmptyPath      = errors.New("path is empty and the leaf is not the root")
+)
+
+func runVerify(flags *flag.FlagSet, args []string, stdin io.Reader, stdout io.Writer) error {
+	var (
+		hashName = flags.String("hash", types.NameSHA256, "hash algorithm")
+		leafHex  = flags.String("leaf", "", "hex encoded hash of the leaf")
+		rootHex  = flags.String("root", "", "hex encoded Merkle root")
+		quiet    = flags.Bool("q", false, "do not print the trace")
+	)
+
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if flags.NArg() > 1 || *leafHex == "" || *rootHex == "" {
+		flags.Usage()
+		return errUsage
+	}
+
+	hasher, err := types.HasherByName(*hashName)
+	if err != nil {
+		return err
+	}
+
+	leaf, err := hex.DecodeString(*leafHex)
+	if err != nil {
+		return fmt.Errorf("invalid leaf: %s", err)
+	}
+	root, err := hex.DecodeString(*rootHex)
+	if err != nil {
+		return fmt.Errorf("invalid root: %s", err)
+	}
+
+	path, err := readPath(flags.Arg(0), stdin)
+	if err != nil {
+		return err
+	}
+
+	w := stdout
+	if *quiet {
+		w = ioutil.Discard
+	}
+
+	if err := tracePath(w, hasher, leaf, root, path); err != nil {
+		fmt.Fprintln(w, "FAIL")
+		return err
+	}
+
+	// The trace already checked the path, but the validation of the package
+	// is the reference.
+	if err := path.ValidateWith(hasher); err != nil {
+		fmt.Fprintln(w, "FAIL")
+		return err
+	}
+
+	fmt.Fprintln(w, "OK")
+
+	return nil
+}
+
+// Reads a path in JSON from a file, or from the standard input if the
+// filename is empty or a dash.
+func readPath(filename string, stdin io.Reader) (types.Path, error) {
+	var (
+		data []byte
+		err  error
+	)
+
+	if filename == "" || filename == "-" {
+		data, err = ioutil.ReadAll(stdin)
+	} else {
+		data, err = ioutil.ReadFile(filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var path types.Path
+	if err := json.Unmarshal(data, &path); err != nil {
+		return nil, fmt.Errorf("invalid path: %s", err)
+	}
+
+	return path, nil
+}
+
+// Walks the path from the leaf to the root, printing each step, and returns
+// the reason why the path does not verify, if any.
+func tracePath(w io.Writer, hasher types.Hasher, leaf, root []byte, path types.Path) error {
+	fmt.Fprintf(w, "leaf       %x\n", leaf)
+
+	node := leaf
+
+	for i, h := range path {
+		step := i + 1
+
+		fmt.Fprintf(w, "step %d\n", step)
+		fmt.Fprintf(w, "  left     %x%s\n", h.Left, traceMark(h.Left, node))
+		fmt.Fprintf(w, "  right    %x%s\n", h.Right, traceMark(h.Right, node))
+
+		if !bytes.Equal(h.Left, node) && !bytes.Equal(h.Right, node) {
+			if i == 0 {
+				return types.ErrLeafMismatch
+			}
+			return fmt.Errorf("step %d: %w", step, errBrokenPath)
+		}
+
+		expected := hasher.HashNode(h.Left, h.Right)
+		if !bytes.Equal(h.Parent, expected) {
+			fmt.Fprintf(w, "  parent   %x (mismatch, computed %x)\n", h.Parent, expected)
+			return fmt.Errorf("step %d: %w", step, errParentMismatch)
+		}
+		fmt.Fprintf(w, "  parent   %x (ok)\n", h.Parent)
+
+		node = h.Parent
+	}
+
+	if !bytes.Equal(node, root) {
+		fmt.Fprintf(w, "root       %x (mismatch, path ends at %x)\n", root, node)
+		if len(path) == 0 {
+			return errEmptyPath
+		}
+		return types.ErrRootMismatch
+	}
+	fmt.Fprintf(w, "root       %x (ok)\n", root)
+
+	return nil
+}
+
+// Returns a mark to show which child is the node coming from the leaf.
+func traceMark(hash, node []byte) string {
+	if bytes.Equal(hash, node) {
+		return " <"
+	}
+	return ""
+}
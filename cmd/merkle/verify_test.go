@@ -0,0 +1,130 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	// Hash of "e" using SHA-256.
+	hashE = "3f79bb7b435b05321651daefd374cdc681dc06faa65e374e38337b88ca046dea"
+
+	// Root of the tree of "a", "b", "c", "d" and "e" using SHA-256.
+	rootABCDE = "d71f8983ad4ee170f8129f1ebcdd7440be7798d8e1c80420bf11f1eced610dba"
+)
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name     string
+		leaf     string
+		root     string
+		filename string
+		steps    int
+	}{
+		{"a", hashA, hashA, "path-a-0.json", 0},
+		{"abcde-0", hashA, rootABCDE, "path-abcde-0.json", 3},
+		{"abcde-2", hashC, rootABCDE, "path-abcde-2.json", 3},
+		{"abcde-4", hashE, rootABCDE, "path-abcde-4.json", 1},
+	}
+
+	for _, tt := range tests {
+		filename := filepath.Join("../../testdata", tt.filename)
+		code, stdout, stderr := runTest([]string{"verify", "-leaf", tt.leaf, "-root", tt.root, filename}, "")
+		if code != 0 {
+			t.Errorf("%s: exit code = %d want 0: %s", tt.name, code, stderr)
+		}
+		if !strings.HasSuffix(stdout, "OK\n") {
+			t.Errorf("%s: stdout = %q want to end with OK", tt.name, stdout)
+		}
+		if got := strings.Count(stdout, "\nstep "); got != tt.steps {
+			t.Errorf("%s: stdout has %d steps want %d", tt.name, got, tt.steps)
+		}
+	}
+}
+
+func TestVerify_stdin(t *testing.T) {
+	data, err := ioutil.ReadFile("../../testdata/path-abcde-4.json")
+	if err != nil {
+		t.Fatalf("ioutil.ReadFile(): err: %s", err)
+	}
+
+	for _, args := range [][]string{
+		{"verify", "-leaf", hashE, "-root", rootABCDE},
+		{"verify", "-leaf", hashE, "-root", rootABCDE, "-q", "-"},
+	} {
+		code, stdout, stderr := runTest(args, string(data))
+		if code != 0 {
+			t.Errorf("%v: exit code = %d want 0: %s", args, code, stderr)
+		}
+		if quiet := args[len(args)-1] == "-"; quiet && stdout != "" {
+			t.Errorf("%v: stdout = %q want empty", args, stdout)
+		}
+	}
+}
+
+func TestVerify_Error(t *testing.T) {
+	const abcde2 = `[
+		{"left": "` + hashC + `", "right": "18ac3e7343f016890c510e93f935261169d9e3f565436429830faf0934f4f8e4", "parent": "bffe0b34dba16bc6fac17c08bac55d676cded5a4ade41fe2c9924a5dde8f3e5b"},
+		{"left": "e5a01fee14e0ed5c48714f22180f25ad8365b53f9779f79dc4a3d7e93963f94a", "right": "bffe0b34dba16bc6fac17c08bac55d676cded5a4ade41fe2c9924a5dde8f3e5b", "parent": "14ede5e8e97ad9372327728f5099b95604a39593cac3bd38a343ad76205213e7"},
+		{"left": "14ede5e8e97ad9372327728f5099b95604a39593cac3bd38a343ad76205213e7", "right": "` + hashE + `", "parent": "` + rootABCDE + `"}
+	]`
+
+	tests := []struct {
+		name   string
+		args   []string
+		stdin  string
+		code   int
+		reason string
+	}{
+		{"leaf", []string{"-leaf", hashB, "-root", rootABCDE}, abcde2, 1, "path does not start at the leaf"},
+		{"root", []string{"-leaf", hashC, "-root", hashA}, abcde2, 1, "path does not end at the root"},
+		{"parent", []string{"-leaf", hashC, "-root", rootABCDE}, strings.Replace(abcde2, "bffe0b34", "bffe0b35", 1), 1, "step 1: parent hash does not match"},
+		{"broken", []string{"-leaf", hashC, "-root", rootABCDE}, strings.Replace(abcde2, `"right": "bffe0b34`, `"right": "bffe0b35`, 1), 1, "step 2: step does not contain"},
+		{"hasher", []string{"-leaf", hashC, "-root", rootABCDE, "-hash", "sha3_256"}, abcde2, 1, "step 1: parent hash does not match"},
+		{"empty", []string{"-leaf", hashA, "-root", hashB}, "[]", 1, "path is empty"},
+		{"json", []string{"-leaf", hashA, "-root", hashB}, "{", 1, "invalid path"},
+		{"leaf hex", []string{"-leaf", "zz", "-root", hashB}, "[]", 1, "invalid leaf"},
+		{"root hex", []string{"-leaf", hashA, "-root", "zz"}, "[]", 1, "invalid root"},
+		{"no leaf", []string{"-root", hashB}, "[]", 2, "usage"},
+		{"no root", []string{"-leaf", hashB}, "[]", 2, "usage"},
+	}
+
+	for _, tt := range tests {
+		code, _, stderr := runTest(append([]string{"verify"}, tt.args...), tt.stdin)
+		if code != tt.code {
+			t.Errorf("%s: exit code = %d want %d", tt.name, code, tt.code)
+		}
+		if !strings.Contains(stderr, tt.reason) {
+			t.Errorf("%s: stderr = %q want to contain %q", tt.name, stderr, tt.reason)
+		}
+	}
+}
+
+func TestVerify_invalidFixtures(t *testing.T) {
+	for _, filename := range []string{"path-invalid-0.json", "path-invalid-1.json"} {
+		args := []string{"verify", "-leaf", hashC, "-root", rootABCDE, filepath.Join("../../testdata", filename)}
+		code, stdout, _ := runTest(args, "")
+		if code != 1 {
+			t.Errorf("%s: exit code = %d want 1", filename, code)
+		}
+		if !strings.HasSuffix(stdout, "FAIL\n") {
+			t.Errorf("%s: stdout = %q want to end with FAIL", filename, stdout)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctlog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// Entry is an entry of a log.
+type Entry struct {
+	// LeafInput is the MerkleTreeLeaf structure of section 3.4 of RFC 6962.
+	// Its hash is the leaf of the tree.
+	LeafInput []byte
+
+	// ExtraData is the chain of the certificate without the certificate.
+	ExtraData []byte
+}
+
+// entryFile appends the entries of a log to a file. Each entry is written as
+// the lengths of its leaf input and of its extra data as big-endian 32-bit
+// integers, followed by the leaf input, the extra data and the big-endian
+// CRC-32C of the previous bytes.
+type entryFile struct {
+	file    *os.File
+	size    int64
+	offsets []int64
+}
+
+const entryHeaderSize = 8
+
+// Opens the entries stored in the given file, creating it if it does not
+// exist. The entry that was partially written at the end of the file, if
+// any, is ignored until discardTail is called, but an error wrapping
+// ErrInvalidEntries is returned if any entry is invalid. The given function
+// is called with each entry.
+func openEntryFile(filename string, fn func(e *Entry) error) (*entryFile, error) {
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &entryFile{file: file}
+	if err := f.load(fn); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Returns the number of entries.
+func (f *entryFile) len() int {
+	return len(f.offsets)
+}
+
+// Appends an entry and syncs the file.
+func (f *entryFile) append(e *Entry) error {
+	buf := make([]byte, entryHeaderSize, entryHeaderSize+len(e.LeafInput)+len(e.ExtraData)+crc32.Size)
+	binary.BigEndian.PutUint32(buf, uint32(len(e.LeafInput)))
+	binary.BigEndian.PutUint32(buf[4:], uint32(len(e.ExtraData)))
+	buf = append(buf, e.LeafInput...)
+	buf = append(buf, e.ExtraData...)
+	buf = binary.BigEndian.AppendUint32(buf, crc32.Checksum(buf, crc32c))
+
+	if _, err := f.file.WriteAt(buf, f.size); err != nil {
+		return err
+	}
+	if err := f.file.Sync(); err != nil {
+		return err
+	}
+
+	f.offsets = append(f.offsets, f.size)
+	f.size += int64(len(buf))
+
+	return nil
+}
+
+// Reads the entry at the given index.
+func (f *entryFile) get(index int) (*Entry, error) {
+	end := f.size
+	if index+1 < len(f.offsets) {
+		end = f.offsets[index+1]
+	}
+
+	buf := make([]byte, end-f.offsets[index])
+	if _, err := f.file.ReadAt(buf, f.offsets[index]); err != nil {
+		return nil, err
+	}
+
+	return decodeEntry(buf)
+}
+
+func (f *entryFile) close() error {
+	return f.file.Close()
+}
+
+// Reads the offsets of the entries up to the last complete entry. Since
+// entries are synced before being added to the tree, only an entry that ends
+// after the end of the file can have been torn by a crash, and any other
+// invalid entry is an error.
+func (f *entryFile) load(fn func(e *Entry) error) error {
+	r := bufio.NewReader(f.file)
+
+	for {
+		var header [entryHeaderSize]byte
+		if _, err := io.ReadFull(r, header[:]); err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[:])) + int64(binary.BigEndian.Uint32(header[4:]))
+		if size > maxEntrySize {
+			return fmt.Errorf("%w: entry at offset %d has %d bytes", ErrInvalidEntries, f.size, size)
+		}
+
+		buf := make([]byte, entryHeaderSize+size+crc32.Size)
+		copy(buf, header[:])
+		if _, err := io.ReadFull(r, buf[entryHeaderSize:]); err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		e, err := decodeEntry(buf)
+		if err == errInvalidEntry {
+			return fmt.Errorf("%w: invalid entry at offset %d", ErrInvalidEntries, f.size)
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+
+		f.offsets = append(f.offsets, f.size)
+		f.size += int64(len(buf))
+	}
+
+	return nil
+}
+
+// Discards the entry that was partially written at the end of the file, if
+// any, so that new entries can be appended.
+func (f *entryFile) discardTail() error {
+	return f.file.Truncate(f.size)
+}
+
+// maxEntrySize is the maximum size of the leaf input and the extra data of an
+// entry, which contain at most two 24-bit length-prefixed certificate lists.
+const maxEntrySize = 2 * (1<<24 + 64)
+
+// ErrInvalidEntries is returned when the entries of a log are corrupted.
+var ErrInvalidEntries = errors.New("invalid log entries")
+
+// errInvalidEntry is returned when an entry does not match its checksum or
+// its length.
+var errInvalidEntry = errors.New("invalid entry")
+
+func decodeEntry(buf []byte) (*Entry, error) {
+	if len(buf) < entryHeaderSize+crc32.Size {
+		return nil, errInvalidEntry
+	}
+
+	var (
+		leafLen  = int64(binary.BigEndian.Uint32(buf))
+		extraLen = int64(binary.BigEndian.Uint32(buf[4:]))
+		body     = buf[:len(buf)-crc32.Size]
+	)
+
+	if int64(len(body)) != entryHeaderSize+leafLen+extraLen {
+		return nil, errInvalidEntry
+	}
+	if crc32.Checksum(body, crc32c) != binary.BigEndian.Uint32(buf[len(body):]) {
+		return nil, errInvalidEntry
+	}
+
+	return &Entry{
+		LeafInput: body[entryHeaderSize : entryHeaderSize+leafLen],
+		ExtraData: body[entryHeaderSize+leafLen:],
+	}, nil
+}
@@ -0,0 +1,255 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctlog
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/stratumn/merkle/internal/httpapi"
+	"github.com/stratumn/merkle/types"
+)
+
+// MaxBodySize is the maximum size of the body of a request.
+const MaxBodySize = 1 << 22
+
+// AddChainRequest is the body of an add-chain request. Byte slices are
+// encoded in base64 like in RFC 6962.
+type AddChainRequest struct {
+	Chain [][]byte `json:"chain"`
+}
+
+// AddChainResponse is the body of the response to an add-chain request.
+type AddChainResponse struct {
+	SCTVersion int    `json:"sct_version"`
+	ID         []byte `json:"id"`
+	Timestamp  uint64 `json:"timestamp"`
+	Extensions []byte `json:"extensions"`
+	Signature  []byte `json:"signature"`
+}
+
+// SCT returns the signed certificate timestamp of the response.
+func (r *AddChainResponse) SCT() (*SCT, error) {
+	if r.SCTVersion != v1 || len(r.ID) != sha256.Size {
+		return nil, errors.New("unsupported signed certificate timestamp")
+	}
+
+	sct := &SCT{Timestamp: r.Timestamp, Extensions: r.Extensions, Signature: r.Signature}
+	copy(sct.LogID[:], r.ID)
+
+	return sct, nil
+}
+
+// GetSTHResponse is the body of the response to a get-sth request.
+type GetSTHResponse struct {
+	TreeSize          int    `json:"tree_size"`
+	Timestamp         uint64 `json:"timestamp"`
+	SHA256RootHash    []byte `json:"sha256_root_hash"`
+	TreeHeadSignature []byte `json:"tree_head_signature"`
+}
+
+// SignedTreeHead returns the tree head of the response, whose signature can
+// be checked with SignedTreeHead.Verify.
+func (r *GetSTHResponse) SignedTreeHead() (*types.SignedTreeHead, error) {
+	sig, err := unmarshalDigitallySigned(r.TreeHeadSignature)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.SignedTreeHead{
+		TreeSize:  r.TreeSize,
+		Timestamp: r.Timestamp,
+		RootHash:  r.SHA256RootHash,
+		Signature: sig,
+	}, nil
+}
+
+// GetSTHConsistencyResponse is the body of the response to a
+// get-sth-consistency request.
+type GetSTHConsistencyResponse struct {
+	Consistency [][]byte `json:"consistency"`
+}
+
+// GetProofByHashResponse is the body of the response to a get-proof-by-hash
+// request.
+type GetProofByHashResponse struct {
+	LeafIndex int      `json:"leaf_index"`
+	AuditPath [][]byte `json:"audit_path"`
+}
+
+// GetEntriesResponse is the body of the response to a get-entries request.
+type GetEntriesResponse struct {
+	Entries []JSONEntry `json:"entries"`
+}
+
+// JSONEntry is used to Marshal/Unmarshal Entry type with base64
+// representation.
+type JSONEntry struct {
+	LeafInput []byte `json:"leaf_input"`
+	ExtraData []byte `json:"extra_data"`
+}
+
+// Handler serves the API of section 4 of RFC 6962 for a log, under /ct/v1/.
+//
+// Errors are returned as {"error": message} with a status code: 400 for
+// malformed requests, invalid chains and invalid tree sizes, 404 for indices
+// out of range and unknown leaf hashes, 405 for unsupported methods, 413 for
+// bodies larger than MaxBodySize and 500 otherwise.
+type Handler struct {
+	log    *Log
+	routes httpapi.Router
+}
+
+// NewHandler creates a handler for a log.
+func NewHandler(log *Log) *Handler {
+	h := &Handler{log: log}
+
+	h.routes = httpapi.Router{
+		"/ct/v1/add-chain":           {Method: http.MethodPost, Handle: h.addChain},
+		"/ct/v1/get-sth":             {Method: http.MethodGet, Handle: h.getSTH},
+		"/ct/v1/get-sth-consistency": {Method: http.MethodGet, Handle: h.getSTHConsistency},
+		"/ct/v1/get-proof-by-hash":   {Method: http.MethodGet, Handle: h.getProofByHash},
+		"/ct/v1/get-entries":         {Method: http.MethodGet, Handle: h.getEntries},
+	}
+
+	return h
+}
+
+// ServeHTTP implements net/http.Handler.ServeHTTP.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.routes.ServeHTTP(w, r)
+}
+
+func (h *Handler) addChain(r *http.Request) (int, interface{}, error) {
+	var req AddChainRequest
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, MaxBodySize)).Decode(&req); err != nil {
+		return 0, nil, httpapi.BadRequest(err)
+	}
+
+	sct, err := h.log.AddChain(req.Chain)
+	if errors.Is(err, ErrInvalidChain) {
+		return 0, nil, httpapi.BadRequest(err)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, AddChainResponse{
+		SCTVersion: v1,
+		ID:         sct.LogID[:],
+		Timestamp:  sct.Timestamp,
+		Extensions: []byte{},
+		Signature:  sct.Signature,
+	}, nil
+}
+
+func (h *Handler) getSTH(r *http.Request) (int, interface{}, error) {
+	sth, err := h.log.STH()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, GetSTHResponse{
+		TreeSize:          sth.TreeSize,
+		Timestamp:         sth.Timestamp,
+		SHA256RootHash:    sth.RootHash,
+		TreeHeadSignature: marshalDigitallySigned(sth.Signature),
+	}, nil
+}
+
+func (h *Handler) getSTHConsistency(r *http.Request) (int, interface{}, error) {
+	first, err := intParam(r, "first")
+	if err != nil {
+		return 0, nil, err
+	}
+	second, err := intParam(r, "second")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	proof, err := h.log.Consistency(first, second)
+	if err != nil {
+		return 0, nil, err
+	}
+	if proof == nil {
+		proof = [][]byte{}
+	}
+
+	return http.StatusOK, GetSTHConsistencyResponse{Consistency: proof}, nil
+}
+
+func (h *Handler) getProofByHash(r *http.Request) (int, interface{}, error) {
+	hash, err := base64.StdEncoding.DecodeString(r.URL.Query().Get("hash"))
+	if err != nil {
+		return 0, nil, httpapi.BadRequest(fmt.Errorf("hash: %w", err))
+	}
+	treeSize, err := intParam(r, "tree_size")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	proof, err := h.log.ProofByHash(hash, treeSize)
+	if errors.Is(err, ErrLeafNotFound) {
+		return 0, nil, httpapi.NotFound(err)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	if proof.Siblings == nil {
+		proof.Siblings = [][]byte{}
+	}
+
+	return http.StatusOK, GetProofByHashResponse{LeafIndex: proof.Index, AuditPath: proof.Siblings}, nil
+}
+
+func (h *Handler) getEntries(r *http.Request) (int, interface{}, error) {
+	start, err := intParam(r, "start")
+	if err != nil {
+		return 0, nil, err
+	}
+	end, err := intParam(r, "end")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if end < start {
+		return 0, nil, httpapi.BadRequest(errors.New("end is smaller than start"))
+	}
+
+	entries, err := h.log.Entries(start, end)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	res := GetEntriesResponse{Entries: make([]JSONEntry, len(entries))}
+	for i, e := range entries {
+		res.Entries[i] = JSONEntry{LeafInput: e.LeafInput, ExtraData: e.ExtraData}
+	}
+
+	return http.StatusOK, res, nil
+}
+
+func intParam(r *http.Request, name string) (int, error) {
+	v, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil {
+		return 0, httpapi.BadRequest(fmt.Errorf("%s: %w", name, err))
+	}
+	return v, nil
+}
@@ -0,0 +1,235 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctlog_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stratumn/merkle/ctlog"
+	"github.com/stratumn/merkle/types"
+)
+
+func newTestServer(t *testing.T) (types.Verifier, *httptest.Server) {
+	signer := newTestSigner(t)
+	l := openTestLog(t, t.TempDir(), signer)
+
+	s := httptest.NewServer(ctlog.NewHandler(l))
+	t.Cleanup(s.Close)
+
+	return newTestVerifier(t, signer), s
+}
+
+// do sends a request and decodes the body of a successful response,
+// returning the status code.
+func do(t *testing.T, method, url, body string, res interface{}) int {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("http.NewRequest(): err: %s", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("http.Do(): err: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK && res != nil {
+		if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+			t.Fatalf("json.Decode(): err: %s", err)
+		}
+	}
+
+	return resp.StatusCode
+}
+
+func addChainBody(t *testing.T, chain ...[]byte) string {
+	body, err := json.Marshal(ctlog.AddChainRequest{Chain: chain})
+	if err != nil {
+		t.Fatalf("json.Marshal(): err: %s", err)
+	}
+	return string(body)
+}
+
+func getSTH(t *testing.T, verifier types.Verifier, s *httptest.Server) *types.SignedTreeHead {
+	var res ctlog.GetSTHResponse
+	if status := do(t, http.MethodGet, s.URL+"/ct/v1/get-sth", "", &res); status != http.StatusOK {
+		t.Fatalf("get-sth: status = %d want %d", status, http.StatusOK)
+	}
+
+	sth, err := res.SignedTreeHead()
+	if err != nil {
+		t.Fatalf("res.SignedTreeHead(): err: %s", err)
+	}
+	if err := sth.Verify(verifier); err != nil {
+		t.Errorf("sth.Verify(): err: %s", err)
+	}
+
+	return sth
+}
+
+func TestHandler(t *testing.T) {
+	var (
+		verifier, s = newTestServer(t)
+		roots       [][]byte
+		hashes      [][]byte
+	)
+
+	if sth := getSTH(t, verifier, s); sth.TreeSize != 0 {
+		t.Errorf("empty: sth.TreeSize = %d want 0", sth.TreeSize)
+	}
+
+	for i := 0; i < 6; i++ {
+		var (
+			cert = testCert(t, fmt.Sprintf("leaf %d", i))
+			res  ctlog.AddChainResponse
+		)
+
+		status := do(t, http.MethodPost, s.URL+"/ct/v1/add-chain", addChainBody(t, cert, testCert(t, "root")), &res)
+		if status != http.StatusOK {
+			t.Fatalf("add-chain: status = %d want %d", status, http.StatusOK)
+		}
+		sct, err := res.SCT()
+		if err != nil {
+			t.Fatalf("res.SCT(): err: %s", err)
+		}
+		if err := sct.Verify(verifier, cert); err != nil {
+			t.Errorf("sct.Verify(): err: %s", err)
+		}
+
+		sth := getSTH(t, verifier, s)
+		if got, want := sth.TreeSize, i+1; got != want {
+			t.Errorf("sth.TreeSize = %d want %d", got, want)
+		}
+		if sth.Timestamp < sct.Timestamp {
+			t.Errorf("sth.Timestamp = %d want at least %d", sth.Timestamp, sct.Timestamp)
+		}
+		roots = append(roots, sth.RootHash)
+	}
+
+	var entries ctlog.GetEntriesResponse
+	if status := do(t, http.MethodGet, s.URL+"/ct/v1/get-entries?start=0&end=10", "", &entries); status != http.StatusOK {
+		t.Fatalf("get-entries: status = %d want %d", status, http.StatusOK)
+	}
+	if got, want := len(entries.Entries), len(roots); got != want {
+		t.Fatalf("len(entries.Entries) = %d want %d", got, want)
+	}
+	for _, e := range entries.Entries {
+		hashes = append(hashes, types.RFC6962SHA256.HashLeaf(e.LeafInput))
+	}
+
+	for size := 1; size <= len(roots); size++ {
+		for index := 0; index < size; index++ {
+			var (
+				query = url.Values{"hash": {base64.StdEncoding.EncodeToString(hashes[index])}, "tree_size": {fmt.Sprint(size)}}
+				res   ctlog.GetProofByHashResponse
+			)
+
+			status := do(t, http.MethodGet, s.URL+"/ct/v1/get-proof-by-hash?"+query.Encode(), "", &res)
+			if status != http.StatusOK {
+				t.Fatalf("get-proof-by-hash: status = %d want %d", status, http.StatusOK)
+			}
+
+			p := types.InclusionProof{Index: res.LeafIndex, TreeSize: size, Siblings: res.AuditPath}
+			if err := p.VerifyWith(types.RFC6962SHA256, hashes[index], roots[size-1]); err != nil {
+				t.Errorf("get-proof-by-hash %d, %d: p.VerifyWith(): err: %s", index, size, err)
+			}
+		}
+
+		for first := 1; first <= size; first++ {
+			var res ctlog.GetSTHConsistencyResponse
+
+			url := fmt.Sprintf("%s/ct/v1/get-sth-consistency?first=%d&second=%d", s.URL, first, size)
+			if status := do(t, http.MethodGet, url, "", &res); status != http.StatusOK {
+				t.Fatalf("get-sth-consistency: status = %d want %d", status, http.StatusOK)
+			}
+
+			err := types.VerifyConsistencyWith(types.RFC6962SHA256, first, size, roots[first-1], roots[size-1], res.Consistency)
+			if err != nil {
+				t.Errorf("get-sth-consistency %d, %d: types.VerifyConsistencyWith(): err: %s", first, size, err)
+			}
+		}
+	}
+}
+
+func TestHandler_emptyLists(t *testing.T) {
+	_, s := newTestServer(t)
+	do(t, http.MethodPost, s.URL+"/ct/v1/add-chain", addChainBody(t, testCert(t, "leaf")), nil)
+
+	var entries ctlog.GetEntriesResponse
+	do(t, http.MethodGet, s.URL+"/ct/v1/get-entries?start=0&end=0", "", &entries)
+	hash := types.RFC6962SHA256.HashLeaf(entries.Entries[0].LeafInput)
+
+	// Empty proofs are encoded as empty arrays rather than null.
+	for _, path := range []string{
+		"/ct/v1/get-sth-consistency?first=1&second=1",
+		"/ct/v1/get-proof-by-hash?tree_size=1&hash=" + url.QueryEscape(base64.StdEncoding.EncodeToString(hash)),
+	} {
+		resp, err := http.Get(s.URL + path)
+		if err != nil {
+			t.Fatalf("http.Get(): err: %s", err)
+		}
+		var body bytes.Buffer
+		body.ReadFrom(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s: status = %d want %d", path, resp.StatusCode, http.StatusOK)
+		}
+		if strings.Contains(body.String(), "null") {
+			t.Errorf("%s: body = %q want no null", path, body.String())
+		}
+	}
+}
+
+func TestHandler_error(t *testing.T) {
+	_, s := newTestServer(t)
+	do(t, http.MethodPost, s.URL+"/ct/v1/add-chain", addChainBody(t, testCert(t, "leaf")), nil)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"malformed chain", http.MethodPost, "/ct/v1/add-chain", `{"chain":`, http.StatusBadRequest},
+		{"empty chain", http.MethodPost, "/ct/v1/add-chain", `{"chain":[]}`, http.StatusBadRequest},
+		{"invalid certificate", http.MethodPost, "/ct/v1/add-chain", `{"chain":["AAAA"]}`, http.StatusBadRequest},
+		{"large chain", http.MethodPost, "/ct/v1/add-chain", `{"chain":["` + strings.Repeat("A", ctlog.MaxBodySize) + `"]}`, http.StatusRequestEntityTooLarge},
+		{"consistency missing", http.MethodGet, "/ct/v1/get-sth-consistency?first=1", "", http.StatusBadRequest},
+		{"consistency size", http.MethodGet, "/ct/v1/get-sth-consistency?first=1&second=2", "", http.StatusBadRequest},
+		{"proof hash", http.MethodGet, "/ct/v1/get-proof-by-hash?hash=%25&tree_size=1", "", http.StatusBadRequest},
+		{"proof size", http.MethodGet, "/ct/v1/get-proof-by-hash?hash=AAAA&tree_size=2", "", http.StatusBadRequest},
+		{"proof unknown", http.MethodGet, "/ct/v1/get-proof-by-hash?hash=AAAA&tree_size=1", "", http.StatusNotFound},
+		{"entries range", http.MethodGet, "/ct/v1/get-entries?start=1&end=2", "", http.StatusNotFound},
+		{"entries order", http.MethodGet, "/ct/v1/get-entries?start=1&end=0", "", http.StatusBadRequest},
+		{"unknown endpoint", http.MethodGet, "/ct/v1/get-roots", "", http.StatusNotFound},
+		{"method", http.MethodGet, "/ct/v1/add-chain", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		if got := do(t, tt.method, s.URL+tt.path, tt.body, nil); got != tt.status {
+			t.Errorf("%s: status = %d want %d", tt.name, got, tt.status)
+		}
+	}
+}
@@ -0,0 +1,466 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ctlog implements a Certificate Transparency log as described in RFC
+// 6962 on top of a DynTree.
+//
+// The log stores its entries and its tree in a local directory. It accepts
+// any chain of well-formed X.509 certificates, without checking that it chains
+// to a trusted root, and adds the certificates to the tree immediately, so
+// the maximum merge delay is zero. Precertificates are not supported.
+package ctlog
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/stratumn/merkle"
+	"github.com/stratumn/merkle/types"
+)
+
+// MaxEntries is the maximum number of entries returned at once.
+const MaxEntries = 256
+
+var (
+	// ErrInvalidChain is returned when a submitted chain is empty or contains
+	// a certificate that cannot be parsed.
+	ErrInvalidChain = errors.New("invalid certificate chain")
+
+	// ErrLeafNotFound is returned when no entry has the requested leaf hash.
+	ErrLeafNotFound = errors.New("leaf not found")
+
+	// ErrUnsupportedSigner is returned when the signer of a log does not use
+	// ECDSA with the P-256 curve.
+	ErrUnsupportedSigner = errors.New("signer should use ECDSA with P-256")
+
+	// ErrMalformedSignature is returned when a DigitallySigned structure
+	// cannot be decoded or uses other algorithms than SHA-256 and ECDSA.
+	ErrMalformedSignature = errors.New("malformed digitally-signed structure")
+)
+
+// Constants of RFC 6962.
+const (
+	v1                   = 0
+	certificateTimestamp = 0
+	timestampedEntry     = 0
+	x509Entry            = 0
+	hashAlgSHA256        = 4
+	sigAlgECDSA          = 3
+)
+
+// Log is a Certificate Transparency log.
+type Log struct {
+	signer types.Signer
+	logID  [sha256.Size]byte
+
+	// mutex serializes the additions, and protects the entries and the
+	// indices.
+	mutex   sync.RWMutex
+	tree    *merkle.DynTree
+	entries *entryFile
+
+	// certs maps the hash of the certificates to their index, and leaves
+	// maps the leaf hashes to their index.
+	certs  map[[sha256.Size]byte]int
+	leaves map[[sha256.Size]byte]int
+}
+
+// Open opens the log stored in the given directory, creating it if it does
+// not exist. The signer must use ECDSA with the P-256 curve, which is what
+// RFC 6962 requires, such as a types.ECDSASigner.
+//
+// The entries are the source of truth: the tree is rebuilt from the entries
+// that were added after its last change if it is behind, for instance after
+// a crash. Since the tree may have been signed, Open fails instead of
+// removing leaves if the tree has more leaves than there are entries.
+func Open(dir string, signer types.Signer) (*Log, error) {
+	pub, ok := signer.Public().(*ecdsa.PublicKey)
+	if !ok || pub.Curve != elliptic.P256() {
+		return nil, ErrUnsupportedSigner
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := merkle.OpenDynTree(filepath.Join(dir, "tree"), merkle.WithHasher(types.RFC6962SHA256))
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Log{
+		signer: signer,
+		logID:  sha256.Sum256(der),
+		tree:   tree,
+		certs:  map[[sha256.Size]byte]int{},
+		leaves: map[[sha256.Size]byte]int{},
+	}
+
+	var (
+		size   = tree.LeavesLen()
+		hashes [][]byte
+	)
+
+	l.entries, err = openEntryFile(filepath.Join(dir, "entries"), func(e *Entry) error {
+		_, cert, err := parseLeafInput(e.LeafInput)
+		if err != nil {
+			return err
+		}
+
+		index := len(hashes)
+		hash := types.RFC6962SHA256.HashLeaf(e.LeafInput)
+		hashes = append(hashes, hash)
+		l.index(index, cert, hash)
+
+		if index >= size {
+			// The entry was not added to the tree.
+			tree.Add(e.LeafInput)
+		}
+
+		return nil
+	})
+	if err != nil {
+		tree.Close()
+		return nil, err
+	}
+
+	if size > len(hashes) {
+		// Entries are synced before being added to the tree, so some were
+		// lost.
+		err = fmt.Errorf("%w: the tree has %d leaves but there are %d entries", ErrInvalidEntries, size, len(hashes))
+	}
+	for i := 0; err == nil && i < size; i++ {
+		if !bytes.Equal(tree.Leaf(i), hashes[i]) {
+			err = fmt.Errorf("leaf %d of the tree does not match the entries", i)
+		}
+	}
+	if err == nil {
+		err = tree.Err()
+	}
+	if err == nil {
+		err = l.entries.discardTail()
+	}
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// LogID returns the identifier of the log, which is the SHA-256 hash of its
+// public key.
+func (l *Log) LogID() [sha256.Size]byte {
+	return l.logID
+}
+
+// Close closes the log.
+func (l *Log) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	err := l.tree.Close()
+	if closeErr := l.entries.close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// AddChain adds a chain of DER encoded certificates to the log, starting
+// with the certificate to log, and returns a timestamp signed by the log. If
+// the certificate was already logged, the timestamp of its entry is signed
+// again.
+func (l *Log) AddChain(chain [][]byte) (*SCT, error) {
+	if len(chain) < 1 {
+		return nil, ErrInvalidChain
+	}
+	for _, cert := range chain {
+		if len(cert) >= 1<<24 {
+			return nil, ErrInvalidChain
+		}
+		if _, err := x509.ParseCertificate(cert); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidChain, err)
+		}
+	}
+
+	timestamp, err := l.add(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sign without holding the lock since signing can be slow.
+	sct := &SCT{LogID: l.logID, Timestamp: timestamp}
+	sig, err := l.signer.Sign(certificateTimestampData(timestamp, chain[0]))
+	if err != nil {
+		return nil, err
+	}
+	sct.Signature = marshalDigitallySigned(sig)
+
+	return sct, nil
+}
+
+// Adds a chain unless its certificate was already logged and returns the
+// timestamp of its entry.
+func (l *Log) add(chain [][]byte) (uint64, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if index, ok := l.certs[sha256.Sum256(chain[0])]; ok {
+		e, err := l.entries.get(index)
+		if err != nil {
+			return 0, err
+		}
+		timestamp, _, err := parseLeafInput(e.LeafInput)
+		return timestamp, err
+	}
+
+	var (
+		timestamp = now()
+		e         = &Entry{
+			LeafInput: leafInput(timestamp, chain[0]),
+			ExtraData: extraData(chain[1:]),
+		}
+	)
+
+	if err := l.entries.append(e); err != nil {
+		return 0, err
+	}
+
+	index := l.tree.Append(e.LeafInput)
+	if err := l.tree.Err(); err != nil {
+		return 0, err
+	}
+	l.index(index, chain[0], l.tree.Leaf(index))
+
+	return timestamp, nil
+}
+
+// Indexes an entry given its certificate and its leaf hash, unless they were
+// already indexed.
+func (l *Log) index(index int, cert, hash []byte) {
+	certKey := sha256.Sum256(cert)
+	if _, ok := l.certs[certKey]; !ok {
+		l.certs[certKey] = index
+	}
+
+	var leafKey [sha256.Size]byte
+	copy(leafKey[:], hash)
+	if _, ok := l.leaves[leafKey]; !ok {
+		l.leaves[leafKey] = index
+	}
+}
+
+// STH returns a tree head for the current tree signed by the log. The root of
+// the empty tree is the SHA-256 hash of the empty string.
+func (l *Log) STH() (*types.SignedTreeHead, error) {
+	var sth *types.SignedTreeHead
+
+	l.tree.View(func(s *merkle.DynTreeSnapshot) {
+		root := s.Root()
+		if s.LeavesLen() == 0 {
+			empty := sha256.Sum256(nil)
+			root = empty[:]
+		}
+		sth = types.NewSignedTreeHead(s.LeavesLen(), root)
+	})
+
+	if err := sth.Sign(l.signer); err != nil {
+		return nil, err
+	}
+
+	return sth, nil
+}
+
+// Consistency returns a proof that the tree with the first size is a prefix
+// of the tree with the second size. The proof is empty if the first size is
+// zero or equal to the second size.
+func (l *Log) Consistency(first, second int) ([][]byte, error) {
+	if first < 0 || first > second || second > l.tree.LeavesLen() {
+		return nil, types.ErrSizeOutOfRange
+	}
+	if first == 0 {
+		return nil, nil
+	}
+
+	return l.tree.ConsistencyProof(first, second)
+}
+
+// ProofByHash returns a proof that the leaf with the given hash is included
+// in the tree with the given size.
+func (l *Log) ProofByHash(hash []byte, treeSize int) (*types.InclusionProof, error) {
+	if treeSize < 1 || treeSize > l.tree.LeavesLen() {
+		return nil, types.ErrSizeOutOfRange
+	}
+
+	var key [sha256.Size]byte
+	copy(key[:], hash)
+
+	l.mutex.RLock()
+	index, ok := l.leaves[key]
+	l.mutex.RUnlock()
+
+	if !ok || len(hash) != sha256.Size || index >= treeSize {
+		return nil, ErrLeafNotFound
+	}
+
+	return l.tree.InclusionProof(index, treeSize)
+}
+
+// Entries returns the entries from start to end, inclusive. At most
+// MaxEntries entries are returned, and the end is lowered to the last entry
+// of the log.
+func (l *Log) Entries(start, end int) ([]*Entry, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if start < 0 || end < start || start >= l.entries.len() {
+		return nil, types.ErrIndexOutOfRange
+	}
+	if end >= l.entries.len() {
+		end = l.entries.len() - 1
+	}
+	if end-start >= MaxEntries {
+		end = start + MaxEntries - 1
+	}
+
+	entries := make([]*Entry, 0, end-start+1)
+	for i := start; i <= end; i++ {
+		e, err := l.entries.get(i)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// SCT is a signed certificate timestamp, which is the promise of a log to
+// include a certificate.
+type SCT struct {
+	LogID [sha256.Size]byte
+
+	// Timestamp is the number of milliseconds since the Unix epoch when the
+	// certificate was added.
+	Timestamp uint64
+
+	Extensions []byte
+
+	// Signature is a DigitallySigned structure.
+	Signature []byte
+}
+
+// Verify checks that the timestamp was signed for the given DER encoded
+// certificate.
+func (s *SCT) Verify(verifier types.Verifier, cert []byte) error {
+	sig, err := unmarshalDigitallySigned(s.Signature)
+	if err != nil {
+		return err
+	}
+	if len(s.Extensions) > 0 {
+		return errors.New("extensions are not supported")
+	}
+
+	return verifier.Verify(certificateTimestampData(s.Timestamp, cert), sig)
+}
+
+// Returns the current time in milliseconds since the Unix epoch.
+func now() uint64 {
+	return uint64(time.Now().UnixNano() / int64(time.Millisecond))
+}
+
+// Returns the MerkleTreeLeaf structure of an X.509 entry.
+func leafInput(timestamp uint64, cert []byte) []byte {
+	buf := make([]byte, 0, 15+len(cert))
+	buf = append(buf, v1, timestampedEntry)
+	buf = binary.BigEndian.AppendUint64(buf, timestamp)
+	buf = binary.BigEndian.AppendUint16(buf, x509Entry)
+	buf = appendUint24(buf, len(cert))
+	buf = append(buf, cert...)
+
+	// The extensions are empty.
+	return append(buf, 0, 0)
+}
+
+// Returns the timestamp and the certificate of a MerkleTreeLeaf structure.
+func parseLeafInput(leaf []byte) (uint64, []byte, error) {
+	if len(leaf) < 15 || leaf[0] != v1 || leaf[1] != timestampedEntry || binary.BigEndian.Uint16(leaf[10:]) != x509Entry {
+		return 0, nil, errors.New("unsupported leaf input")
+	}
+
+	size := int(leaf[12])<<16 | int(leaf[13])<<8 | int(leaf[14])
+	if len(leaf) != 15+size+2 {
+		return 0, nil, errors.New("malformed leaf input")
+	}
+
+	return binary.BigEndian.Uint64(leaf[2:]), leaf[15 : 15+size], nil
+}
+
+// Returns the certificate_chain structure of an X.509 entry.
+func extraData(chain [][]byte) []byte {
+	size := 0
+	for _, cert := range chain {
+		size += 3 + len(cert)
+	}
+
+	buf := appendUint24(make([]byte, 0, 3+size), size)
+	for _, cert := range chain {
+		buf = appendUint24(buf, len(cert))
+		buf = append(buf, cert...)
+	}
+
+	return buf
+}
+
+// Returns the data signed by a signed certificate timestamp of an X.509
+// entry, as described in section 3.2 of RFC 6962. It only differs from the
+// leaf input by the meaning of its first two bytes.
+func certificateTimestampData(timestamp uint64, cert []byte) []byte {
+	data := leafInput(timestamp, cert)
+	data[0], data[1] = v1, certificateTimestamp
+	return data
+}
+
+// Returns the DigitallySigned structure of RFC 5246 of an ECDSA signature of
+// a SHA-256 digest.
+func marshalDigitallySigned(sig []byte) []byte {
+	buf := make([]byte, 0, 4+len(sig))
+	buf = append(buf, hashAlgSHA256, sigAlgECDSA)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(sig)))
+	return append(buf, sig...)
+}
+
+func unmarshalDigitallySigned(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != hashAlgSHA256 || data[1] != sigAlgECDSA {
+		return nil, ErrMalformedSignature
+	}
+	if int(binary.BigEndian.Uint16(data[2:])) != len(data)-4 {
+		return nil, ErrMalformedSignature
+	}
+	return data[4:], nil
+}
+
+func appendUint24(buf []byte, v int) []byte {
+	return append(buf, byte(v>>16), byte(v>>8), byte(v))
+}
@@ -0,0 +1,492 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctlog_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stratumn/merkle"
+	"github.com/stratumn/merkle/ctlog"
+	"github.com/stratumn/merkle/types"
+)
+
+func newTestSigner(t *testing.T) *types.ECDSASigner {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey(): err: %s", err)
+	}
+	signer, err := types.NewECDSASigner(key)
+	if err != nil {
+		t.Fatalf("types.NewECDSASigner(): err: %s", err)
+	}
+	return signer
+}
+
+func newTestVerifier(t *testing.T, signer types.Signer) types.Verifier {
+	verifier, err := types.NewVerifier(signer.Public())
+	if err != nil {
+		t.Fatalf("types.NewVerifier(): err: %s", err)
+	}
+	return verifier
+}
+
+func openTestLog(t *testing.T, dir string, signer types.Signer) *ctlog.Log {
+	l, err := ctlog.Open(dir, signer)
+	if err != nil {
+		t.Fatalf("ctlog.Open(): err: %s", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+// testCert returns a self-signed DER encoded certificate.
+func testCert(t *testing.T, name string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey(): err: %s", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	cert, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate(): err: %s", err)
+	}
+
+	return cert
+}
+
+// addTestCerts adds chains of two certificates and returns the chains.
+func addTestCerts(t *testing.T, l *ctlog.Log, n int) [][][]byte {
+	chains := make([][][]byte, n)
+	for i := range chains {
+		chains[i] = [][]byte{testCert(t, "leaf"), testCert(t, "root")}
+		if _, err := l.AddChain(chains[i]); err != nil {
+			t.Fatalf("l.AddChain(): err: %s", err)
+		}
+	}
+	return chains
+}
+
+// checkTestLog checks the root of the log against the root of a tree of its
+// entries.
+func checkTestLog(t *testing.T, name string, l *ctlog.Log, verifier types.Verifier, size int) {
+	sth, err := l.STH()
+	if err != nil {
+		t.Fatalf("%s: l.STH(): err: %s", name, err)
+	}
+	if err := sth.Verify(verifier); err != nil {
+		t.Errorf("%s: sth.Verify(): err: %s", name, err)
+	}
+	if got, want := sth.TreeSize, size; got != want {
+		t.Fatalf("%s: sth.TreeSize = %d want %d", name, got, want)
+	}
+
+	var leaves [][]byte
+	for start := 0; start < size; start += len(leaves) {
+		entries, err := l.Entries(start, size-1)
+		if err != nil {
+			t.Fatalf("%s: l.Entries(): err: %s", name, err)
+		}
+		for _, e := range entries {
+			leaves = append(leaves, e.LeafInput)
+		}
+	}
+
+	tree, err := merkle.NewStaticTree(leaves, merkle.WithHasher(types.RFC6962SHA256))
+	if err != nil {
+		t.Fatalf("%s: merkle.NewStaticTree(): err: %s", name, err)
+	}
+	if got, want := sth.RootHash, tree.Root(); !bytes.Equal(got, want) {
+		t.Errorf("%s: sth.RootHash = %x want %x", name, got, want)
+	}
+}
+
+func TestOpen_unsupportedSigner(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey(): err: %s", err)
+	}
+	signer, err := types.NewEd25519Signer(key)
+	if err != nil {
+		t.Fatalf("types.NewEd25519Signer(): err: %s", err)
+	}
+
+	if _, err := ctlog.Open(t.TempDir(), signer); err != ctlog.ErrUnsupportedSigner {
+		t.Errorf("err = %v want %v", err, ctlog.ErrUnsupportedSigner)
+	}
+}
+
+func TestLog_LogID(t *testing.T) {
+	signer := newTestSigner(t)
+	l := openTestLog(t, t.TempDir(), signer)
+
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		t.Fatalf("x509.MarshalPKIXPublicKey(): err: %s", err)
+	}
+	if got, want := l.LogID(), sha256.Sum256(der); got != want {
+		t.Errorf("l.LogID() = %x want %x", got, want)
+	}
+}
+
+func TestLog_AddChain(t *testing.T) {
+	var (
+		signer   = newTestSigner(t)
+		verifier = newTestVerifier(t, signer)
+		l        = openTestLog(t, t.TempDir(), signer)
+		cert     = testCert(t, "example.com")
+	)
+
+	sct, err := l.AddChain([][]byte{cert})
+	if err != nil {
+		t.Fatalf("l.AddChain(): err: %s", err)
+	}
+	if got, want := sct.LogID, l.LogID(); got != want {
+		t.Errorf("sct.LogID = %x want %x", got, want)
+	}
+	if err := sct.Verify(verifier, cert); err != nil {
+		t.Errorf("sct.Verify(): err: %s", err)
+	}
+	if err := sct.Verify(verifier, testCert(t, "example.org")); err != types.ErrInvalidSignature {
+		t.Errorf("sct.Verify(): other certificate: err = %v want %v", err, types.ErrInvalidSignature)
+	}
+
+	// Adding the certificate again returns the same timestamp.
+	dup, err := l.AddChain([][]byte{cert, testCert(t, "root")})
+	if err != nil {
+		t.Fatalf("l.AddChain(): duplicate: err: %s", err)
+	}
+	if got, want := dup.Timestamp, sct.Timestamp; got != want {
+		t.Errorf("duplicate: dup.Timestamp = %d want %d", got, want)
+	}
+
+	checkTestLog(t, "AddChain", l, verifier, 1)
+}
+
+func TestLog_AddChain_invalid(t *testing.T) {
+	l := openTestLog(t, t.TempDir(), newTestSigner(t))
+
+	for _, chain := range [][][]byte{nil, {[]byte("not a certificate")}, {testCert(t, "leaf"), {}}} {
+		if _, err := l.AddChain(chain); !errors.Is(err, ctlog.ErrInvalidChain) {
+			t.Errorf("l.AddChain(%q): err = %v want %v", chain, err, ctlog.ErrInvalidChain)
+		}
+	}
+}
+
+func TestLog_STH_empty(t *testing.T) {
+	var (
+		signer = newTestSigner(t)
+		l      = openTestLog(t, t.TempDir(), signer)
+	)
+
+	sth, err := l.STH()
+	if err != nil {
+		t.Fatalf("l.STH(): err: %s", err)
+	}
+	if empty := sha256.Sum256(nil); !bytes.Equal(sth.RootHash, empty[:]) {
+		t.Errorf("sth.RootHash = %x want %x", sth.RootHash, empty)
+	}
+	if err := sth.Verify(newTestVerifier(t, signer)); err != nil {
+		t.Errorf("sth.Verify(): err: %s", err)
+	}
+}
+
+func TestLog_proofs(t *testing.T) {
+	var (
+		signer = newTestSigner(t)
+		l      = openTestLog(t, t.TempDir(), signer)
+		roots  [][]byte
+		hashes [][]byte
+	)
+
+	for i := 0; i < 12; i++ {
+		addTestCerts(t, l, 1)
+
+		sth, err := l.STH()
+		if err != nil {
+			t.Fatalf("l.STH(): err: %s", err)
+		}
+		roots = append(roots, sth.RootHash)
+
+		entries, err := l.Entries(i, i)
+		if err != nil {
+			t.Fatalf("l.Entries(): err: %s", err)
+		}
+		hashes = append(hashes, types.RFC6962SHA256.HashLeaf(entries[0].LeafInput))
+	}
+
+	for size := 1; size <= len(roots); size++ {
+		for index := 0; index < size; index++ {
+			p, err := l.ProofByHash(hashes[index], size)
+			if err != nil {
+				t.Fatalf("l.ProofByHash(%d, %d): err: %s", index, size, err)
+			}
+			if err := p.VerifyWith(types.RFC6962SHA256, hashes[index], roots[size-1]); err != nil {
+				t.Errorf("l.ProofByHash(%d, %d): p.VerifyWith(): err: %s", index, size, err)
+			}
+		}
+
+		for first := 1; first <= size; first++ {
+			proof, err := l.Consistency(first, size)
+			if err != nil {
+				t.Fatalf("l.Consistency(%d, %d): err: %s", first, size, err)
+			}
+			if err := types.VerifyConsistencyWith(types.RFC6962SHA256, first, size, roots[first-1], roots[size-1], proof); err != nil {
+				t.Errorf("l.Consistency(%d, %d): types.VerifyConsistencyWith(): err: %s", first, size, err)
+			}
+		}
+	}
+
+	if _, err := l.ProofByHash(hashes[5], 5); err != ctlog.ErrLeafNotFound {
+		t.Errorf("l.ProofByHash(): later leaf: err = %v want %v", err, ctlog.ErrLeafNotFound)
+	}
+	if _, err := l.ProofByHash(make([]byte, sha256.Size), 5); err != ctlog.ErrLeafNotFound {
+		t.Errorf("l.ProofByHash(): unknown leaf: err = %v want %v", err, ctlog.ErrLeafNotFound)
+	}
+	if _, err := l.ProofByHash(hashes[0], 13); err != types.ErrSizeOutOfRange {
+		t.Errorf("l.ProofByHash(): size: err = %v want %v", err, types.ErrSizeOutOfRange)
+	}
+
+	if proof, err := l.Consistency(0, 12); err != nil || len(proof) != 0 {
+		t.Errorf("l.Consistency(0, 12) = %x, %v want empty", proof, err)
+	}
+	if _, err := l.Consistency(2, 13); err != types.ErrSizeOutOfRange {
+		t.Errorf("l.Consistency(2, 13): err = %v want %v", err, types.ErrSizeOutOfRange)
+	}
+}
+
+func TestLog_Entries(t *testing.T) {
+	l := openTestLog(t, t.TempDir(), newTestSigner(t))
+	chains := addTestCerts(t, l, ctlog.MaxEntries+2)
+
+	entries, err := l.Entries(1, 1)
+	if err != nil {
+		t.Fatalf("l.Entries(): err: %s", err)
+	}
+	if got, want := len(entries), 1; got != want {
+		t.Fatalf("len(entries) = %d want %d", got, want)
+	}
+	leaf := entries[0].LeafInput
+	if got, want := leaf[len(leaf)-2-len(chains[1][0]):len(leaf)-2], chains[1][0]; !bytes.Equal(got, want) {
+		t.Errorf("certificate = %x want %x", got, want)
+	}
+	if got, want := entries[0].ExtraData[6:], chains[1][1]; !bytes.Equal(got, want) {
+		t.Errorf("chain = %x want %x", got, want)
+	}
+
+	tests := []struct {
+		start, end int
+		want       int
+	}{
+		{0, 0, 1},
+		{0, ctlog.MaxEntries + 10, ctlog.MaxEntries},
+		{ctlog.MaxEntries, ctlog.MaxEntries + 10, 2},
+	}
+
+	for _, tt := range tests {
+		entries, err := l.Entries(tt.start, tt.end)
+		if err != nil {
+			t.Fatalf("l.Entries(%d, %d): err: %s", tt.start, tt.end, err)
+		}
+		if got := len(entries); got != tt.want {
+			t.Errorf("l.Entries(%d, %d): len = %d want %d", tt.start, tt.end, got, tt.want)
+		}
+	}
+
+	for _, r := range [][2]int{{-1, 0}, {2, 1}, {ctlog.MaxEntries + 2, ctlog.MaxEntries + 3}} {
+		if _, err := l.Entries(r[0], r[1]); err != types.ErrIndexOutOfRange {
+			t.Errorf("l.Entries(%d, %d): err = %v want %v", r[0], r[1], err, types.ErrIndexOutOfRange)
+		}
+	}
+}
+
+func TestOpen_reopen(t *testing.T) {
+	var (
+		dir      = t.TempDir()
+		signer   = newTestSigner(t)
+		verifier = newTestVerifier(t, signer)
+	)
+
+	l, err := ctlog.Open(dir, signer)
+	if err != nil {
+		t.Fatalf("ctlog.Open(): err: %s", err)
+	}
+	chains := addTestCerts(t, l, 5)
+	sct, err := l.AddChain(chains[2])
+	if err != nil {
+		t.Fatalf("l.AddChain(): err: %s", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("l.Close(): err: %s", err)
+	}
+
+	l = openTestLog(t, dir, signer)
+	checkTestLog(t, "reopen", l, verifier, 5)
+
+	dup, err := l.AddChain(chains[2])
+	if err != nil {
+		t.Fatalf("l.AddChain(): err: %s", err)
+	}
+	if got, want := dup.Timestamp, sct.Timestamp; got != want {
+		t.Errorf("dup.Timestamp = %d want %d", got, want)
+	}
+}
+
+func TestOpen_recover(t *testing.T) {
+	var (
+		signer   = newTestSigner(t)
+		verifier = newTestVerifier(t, signer)
+	)
+
+	tests := []struct {
+		name  string
+		crash func(t *testing.T, dir string)
+		size  int
+	}{{
+		// The tree is rebuilt from the entries.
+		"tree lost",
+		func(t *testing.T, dir string) {
+			if err := os.RemoveAll(filepath.Join(dir, "tree")); err != nil {
+				t.Fatalf("os.RemoveAll(): err: %s", err)
+			}
+		},
+		5,
+	}, {
+		// The process crashed while appending an entry, before adding it to
+		// the tree.
+		"torn entry",
+		func(t *testing.T, dir string) {
+			filename := filepath.Join(dir, "entries")
+			data, err := ioutil.ReadFile(filename)
+			if err != nil {
+				t.Fatalf("ioutil.ReadFile(): err: %s", err)
+			}
+			if err := ioutil.WriteFile(filename, append(data, data[:20]...), 0644); err != nil {
+				t.Fatalf("ioutil.WriteFile(): err: %s", err)
+			}
+		},
+		5,
+	}}
+
+	for _, tt := range tests {
+		dir := t.TempDir()
+
+		l, err := ctlog.Open(dir, signer)
+		if err != nil {
+			t.Fatalf("%s: ctlog.Open(): err: %s", tt.name, err)
+		}
+		addTestCerts(t, l, 5)
+		if err := l.Close(); err != nil {
+			t.Fatalf("%s: l.Close(): err: %s", tt.name, err)
+		}
+
+		tt.crash(t, dir)
+
+		l = openTestLog(t, dir, signer)
+		checkTestLog(t, tt.name, l, verifier, tt.size)
+		addTestCerts(t, l, 1)
+		checkTestLog(t, tt.name, l, verifier, tt.size+1)
+	}
+}
+
+func TestOpen_invalidEntries(t *testing.T) {
+	signer := newTestSigner(t)
+
+	tests := []struct {
+		name    string
+		corrupt func(data []byte) []byte
+	}{{
+		// The tree has leaves that were signed but whose entries are lost.
+		"lost entry",
+		func(data []byte) []byte { return data[:len(data)-10] },
+	}, {
+		"checksum",
+		func(data []byte) []byte {
+			data[20] ^= 0xff
+			return data
+		},
+	}, {
+		"size",
+		func(data []byte) []byte {
+			data[0] = 0xff
+			return data
+		},
+	}}
+
+	for _, tt := range tests {
+		dir := t.TempDir()
+		filename := filepath.Join(dir, "entries")
+
+		l, err := ctlog.Open(dir, signer)
+		if err != nil {
+			t.Fatalf("%s: ctlog.Open(): err: %s", tt.name, err)
+		}
+		addTestCerts(t, l, 5)
+		if err := l.Close(); err != nil {
+			t.Fatalf("%s: l.Close(): err: %s", tt.name, err)
+		}
+
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatalf("%s: ioutil.ReadFile(): err: %s", tt.name, err)
+		}
+		corrupted := tt.corrupt(data)
+		if err := ioutil.WriteFile(filename, corrupted, 0644); err != nil {
+			t.Fatalf("%s: ioutil.WriteFile(): err: %s", tt.name, err)
+		}
+
+		if _, err := ctlog.Open(dir, signer); !errors.Is(err, ctlog.ErrInvalidEntries) {
+			t.Errorf("%s: ctlog.Open() err = %v want %v", tt.name, err, ctlog.ErrInvalidEntries)
+		}
+
+		got, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatalf("%s: ioutil.ReadFile(): err: %s", tt.name, err)
+		}
+		if !bytes.Equal(got, corrupted) {
+			t.Errorf("%s: the entries were modified", tt.name)
+		}
+
+		// The tree must not have been truncated either.
+		tree, err := merkle.OpenDynTree(filepath.Join(dir, "tree"), merkle.WithHasher(types.RFC6962SHA256))
+		if err != nil {
+			t.Fatalf("%s: merkle.OpenDynTree(): err: %s", tt.name, err)
+		}
+		if got, want := tree.LeavesLen(), 5; got != want {
+			t.Errorf("%s: tree.LeavesLen() = %d want %d", tt.name, got, want)
+		}
+		tree.Close()
+	}
+}
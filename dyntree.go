@@ -15,94 +15,114 @@
 package merkle
 
 import (
-	"crypto/sha256"
-	"hash"
+	"bytes"
+	"errors"
+	"fmt"
+	"math/bits"
 	"sync"
 
 	"github.com/stratumn/merkle/types"
 )
 
-// DynTreeNode is a node within a DynTree.
-type DynTreeNode struct {
-	hash   []byte
-	left   *DynTreeNode
-	right  *DynTreeNode
-	parent *DynTreeNode
-	height int
-}
-
-// Hash returns the hash of the node.
-func (n *DynTreeNode) Hash() []byte {
-	return n.hash
-}
+// Maximum number of nodes written at once when recomputing a tree.
+const dynTreeBatchLen = 1024
 
-// Left returns the node to the left, if any.
-func (n *DynTreeNode) Left() *DynTreeNode {
-	return n.left
-}
+// DynTree is designed for Merkle trees that can mutate.
+// It supports pausing/resuming the computation of hashes, which is useful
+// when adding a large number of leaves to the tree to gain more performance.
+//
+// The nodes are kept in a NodeStore, which by default is a MemNodeStore.
+// They are addressed by level and index instead of being linked by pointers,
+// so the tree can grow past its initial capacity without bound.
+// Nodes are not exposed as values: use Root, Leaf and Path, or read them
+// from the NodeStore.
+type DynTree struct {
+	store  NodeStore
+	size   int
+	mutex  sync.RWMutex
+	hasher types.Hasher
+	paused bool
 
-// Right returns the node to the right, if any.
-func (n *DynTreeNode) Right() *DynTreeNode {
-	return n.right
-}
+	// log persists the changes of a tree opened with OpenDynTree, and err is
+	// the first error that occurred while accessing the log or the store.
+	// Since nodes are read under a read lock, err is guarded by errMutex.
+	log      *dynTreeLog
+	err      error
+	errMutex sync.Mutex
 
-// Parent returns the parent node, if any.
-func (n *DynTreeNode) Parent() *DynTreeNode {
-	return n.parent
+	// scratch is reused to write the nodes changed by a leaf.
+	scratch []StoredNode
 }
 
-func (n *DynTreeNode) rehash(h hash.Hash, a, b []byte, rehashParent bool) {
-	h.Reset()
+// NewDynTree creates a DynTree. If no node store is given, the nodes are kept
+// in memory. The tree starts empty and overwrites the nodes of the store. Use
+// ReopenDynTree to load a tree from the nodes of a store instead.
+func NewDynTree(initialCap int, opts ...Option) *DynTree {
+	o := newOptions(opts)
 
-	// Write never returns an error.
-	h.Write(a)
-	h.Write(b)
-	n.hash = h.Sum(nil)
+	store := o.store
+	if store == nil {
+		store = NewMemNodeStore(initialCap)
+	}
 
-	if rehashParent && n.parent != nil {
-		if n.left != nil {
-			n.parent.rehash(h, n.left.hash, n.hash, true)
-		} else {
-			n.parent.rehash(h, n.hash, n.right.hash, true)
-		}
+	return &DynTree{
+		store:  store,
+		hasher: o.hasher,
 	}
 }
 
-// DynTree is designed for Merkle trees that can mutate.
-// It supports pausing/resuming the computation of hashes, which is useful
-// when adding a large number of leaves to the tree to gain more performance
-type DynTree struct {
-	nodes  []DynTreeNode
-	root   *DynTreeNode
-	leaves []*DynTreeNode
-	height int
-	mutex  sync.RWMutex
-	hash   hash.Hash
-	paused bool
-}
+// ReopenDynTree creates a DynTree from the nodes written to the given store
+// by another DynTree using the same hasher. The store option is ignored.
+//
+// The nodes above the leaves are checked and the ones that are missing or
+// wrong, for instance because a write was interrupted, are recomputed, which
+// takes time proportional to the number of leaves. If the tree that wrote the
+// nodes was truncated, the store must implement NodeStoreTruncater.
+func ReopenDynTree(store NodeStore, opts ...Option) (*DynTree, error) {
+	o := newOptions(opts)
+	t := &DynTree{store: store, hasher: o.hasher}
+
+	size, err := nodeStoreLevelLen(store, 0)
+	if err != nil {
+		return nil, err
+	}
+	t.size = size
+	t.recompute(true)
 
-// NewDynTree creates a DynTree.
-func NewDynTree(initialCap int) *DynTree {
-	return &DynTree{
-		nodes:  make([]DynTreeNode, 0, initialCap*2-1),
-		leaves: make([]*DynTreeNode, 0, initialCap),
-		hash:   sha256.New(),
+	if err := t.firstErr(); err != nil {
+		return nil, err
 	}
+
+	return t, nil
+}
+
+// Hasher returns the hasher used to compute the nodes.
+func (t *DynTree) Hasher() types.Hasher {
+	return t.hasher
 }
 
 // LeavesLen returns the number of leaves. Implements Tree.LeavesLen.
 func (t *DynTree) LeavesLen() int {
-	return len(t.leaves)
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return t.size
 }
 
 // Root returns the Merkle root. Implements Tree.Root.
 func (t *DynTree) Root() []byte {
-	return t.root.Hash()
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return t.root()
 }
 
 // Leaf returns the leaf at the specified index. Implements Tree.Leaf.
 func (t *DynTree) Leaf(index int) []byte {
-	return t.leaves[index].Hash()
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return t.leaf(index)
 }
 
 // Path returns the path of a leaf to the Merkle root. Implements Tree.Path.
@@ -110,99 +130,262 @@ func (t *DynTree) Path(index int) types.Path {
 	t.mutex.RLock()
 	defer t.mutex.RUnlock()
 
-	if len(t.leaves) < 2 {
-		return types.Path{}
+	return t.path(index)
+}
+
+// MultiProof returns a proof of the inclusion of the leaves at the specified
+// indices, without the hashes that can be computed from the leaves.
+func (t *DynTree) MultiProof(indices []int) (*types.MultiProof, error) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return t.multiProof(indices)
+}
+
+// View calls the given function with a snapshot of the tree. The tree cannot
+// be modified until the function returns, so all the reads made through the
+// snapshot belong to the same state of the tree.
+//
+// The snapshot must not be used after the function returns, and the function
+// must not modify the tree.
+func (t *DynTree) View(fn func(snapshot *DynTreeSnapshot)) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	fn(&DynTreeSnapshot{tree: t})
+}
+
+func (t *DynTree) multiProof(indices []int) (*types.MultiProof, error) {
+	proof, err := types.NewMultiProof(indices, t.size, t.path)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.firstErr(); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+func (t *DynTree) root() []byte {
+	if t.size < 1 {
+		return nil
+	}
+	return t.get(dynTreeHeight(t.size), 0)
+}
+
+func (t *DynTree) leaf(index int) []byte {
+	if index < 0 || index >= t.size {
+		panic(types.ErrIndexOutOfRange)
+	}
+	return t.get(0, index)
+}
+
+func (t *DynTree) path(index int) types.Path {
+	if index < 0 || index >= t.size {
+		panic(types.ErrIndexOutOfRange)
 	}
 
 	var (
-		path  = make(types.Path, t.height)
-		node  = t.leaves[index]
-		level = 0
+		height = dynTreeHeight(t.size)
+		path   = make(types.Path, 0, height)
 	)
 
-	for node.parent != nil {
-		if node.left != nil {
-			path[level] = types.MerkleNodeHashes{
-				Left:   node.left.hash,
-				Right:  node.hash,
-				Parent: node.parent.hash,
-			}
-		} else {
-			path[level] = types.MerkleNodeHashes{
-				Left:   node.hash,
-				Right:  node.right.hash,
-				Parent: node.parent.hash,
-			}
+	for level := 0; level < height; level, index = level+1, index/2 {
+		var left, right int
+
+		switch {
+		case index%2 == 1:
+			left, right = index-1, index
+		case index+1 < dynTreeLevelLen(t.size, level):
+			left, right = index, index+1
+		default:
+			// Orphans are promoted without a triplet.
+			continue
 		}
 
-		node = node.parent
-		level++
+		path = append(path, types.MerkleNodeHashes{
+			Left:   t.get(level, left),
+			Right:  t.get(level, right),
+			Parent: t.get(level+1, index/2),
+		})
 	}
 
-	return path[:level]
+	return path
 }
 
-// Add adds a leaf to the tree.
+// Add adds a leaf to the tree. The leaf is hashed using the hasher of the
+// tree, which by default leaves it unchanged.
 func (t *DynTree) Add(leaf []byte) {
+	t.Append(leaf)
+}
+
+// Append adds a leaf to the tree like Add and returns the index of the leaf,
+// which cannot be obtained reliably from LeavesLen when the tree is shared.
+func (t *DynTree) Append(leaf []byte) int {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 
-	t.nodes = append(t.nodes, DynTreeNode{hash: leaf})
-	node := &t.nodes[len(t.nodes)-1]
-	t.leaves = append(t.leaves, node)
+	index := t.size
+	leaf = t.hasher.HashLeaf(leaf)
+	t.add(leaf)
+	t.persist(func(l *dynTreeLog) error { return l.add(leaf) })
 
-	if t.root == nil {
-		t.root = node
-	} else {
-		left := t.leaves[len(t.leaves)-2]
+	return index
+}
 
-		for left.parent != nil && left.parent.height == left.height+1 {
-			left = left.parent
-		}
+// Adds a leaf that has already been hashed.
+func (t *DynTree) add(leaf []byte) {
+	index := t.size
+	t.size++
 
-		t.nodes = append(t.nodes, DynTreeNode{
-			left:   left.left,
-			parent: left.parent,
-			height: left.height + 1,
-		})
+	if t.paused {
+		t.put(0, index, leaf)
+		return
+	}
 
-		parent := &t.nodes[len(t.nodes)-1]
-		node.parent, node.left = parent, left
-		left.parent, left.right = parent, node
+	t.putBatch(t.climb(index, leaf))
+}
 
-		if left.left != nil {
-			left.left.right, left.left = parent, nil
-		}
+// Update updates a leaf of the tree. The leaf is hashed using the hasher of
+// the tree, which by default leaves it unchanged.
+func (t *DynTree) Update(index int, hash []byte) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
 
-		if parent.parent == nil {
-			t.root = parent
-		}
+	hash = t.hasher.HashLeaf(hash)
+	t.update(index, hash)
+	t.persist(func(l *dynTreeLog) error { return l.update(index, hash) })
+}
 
-		if parent.height > t.height {
-			t.height = parent.height
-		}
+// Updates a leaf with a hash that has already been hashed.
+func (t *DynTree) update(index int, hash []byte) {
+	if index < 0 || index >= t.size {
+		panic(types.ErrIndexOutOfRange)
+	}
 
-		if !t.paused {
-			parent.rehash(t.hash, left.hash, leaf, true)
+	if t.paused {
+		t.put(0, index, hash)
+		return
+	}
+
+	t.putBatch(t.climb(index, hash))
+}
+
+// Returns the nodes from the leaf at the given index to the root given the
+// new hash of the leaf. The returned slice is reused by the next call.
+func (t *DynTree) climb(index int, hash []byte) []StoredNode {
+	nodes := append(t.scratch[:0], StoredNode{Index: index, Hash: hash})
+	height := dynTreeHeight(t.size)
+
+	for level := 0; level < height; level, index = level+1, index/2 {
+		switch {
+		case index%2 == 1:
+			hash = t.hasher.HashNode(t.get(level, index-1), hash)
+		case index+1 < dynTreeLevelLen(t.size, level):
+			hash = t.hasher.HashNode(hash, t.get(level, index+1))
 		}
+		nodes = append(nodes, StoredNode{Level: level + 1, Index: index / 2, Hash: hash})
 	}
+
+	t.scratch = nodes
+
+	return nodes
 }
 
-// Update updates a leaf of the tree.
-func (t *DynTree) Update(index int, hash []byte) {
+// DynTreeSnapshot gives read access to a state of a DynTree. It is only valid
+// within the function given to DynTree.View. It implements Tree.
+type DynTreeSnapshot struct {
+	tree *DynTree
+}
+
+// LeavesLen returns the number of leaves. Implements Tree.LeavesLen.
+func (s *DynTreeSnapshot) LeavesLen() int {
+	return s.tree.size
+}
+
+// Root returns the Merkle root. Implements Tree.Root.
+func (s *DynTreeSnapshot) Root() []byte {
+	return s.tree.root()
+}
+
+// Leaf returns the leaf at the specified index. Implements Tree.Leaf.
+func (s *DynTreeSnapshot) Leaf(index int) []byte {
+	return s.tree.leaf(index)
+}
+
+// Path returns the path of a leaf to the Merkle root. Implements Tree.Path.
+func (s *DynTreeSnapshot) Path(index int) types.Path {
+	return s.tree.path(index)
+}
+
+// MultiProof returns a proof of the inclusion of the leaves at the specified
+// indices, without the hashes that can be computed from the leaves.
+func (s *DynTreeSnapshot) MultiProof(indices []int) (*types.MultiProof, error) {
+	return s.tree.multiProof(indices)
+}
+
+// RootAt returns the root the tree had when it had the given number of
+// leaves. See DynTree.RootAt.
+func (s *DynTreeSnapshot) RootAt(size int) ([]byte, error) {
+	return s.tree.rootAt(size)
+}
+
+// ConsistencyProof returns a proof that the tree with the given old size is
+// a prefix of the tree with the given new size. See DynTree.ConsistencyProof.
+func (s *DynTreeSnapshot) ConsistencyProof(oldSize, newSize int) ([][]byte, error) {
+	return s.tree.consistencyProof(oldSize, newSize)
+}
+
+// RemoveLast removes the last leaf of the tree, restoring the root the tree
+// had before the leaf was added.
+func (t *DynTree) RemoveLast() error {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 
-	node := t.leaves[index]
-	node.hash = hash
+	if t.size < 1 {
+		return errors.New("tree is empty")
+	}
+
+	t.truncate(t.size - 1)
+	t.persist(func(l *dynTreeLog) error { return l.truncate(t.size) })
+
+	return nil
+}
 
-	if !t.paused {
-		if node.left != nil {
-			node.parent.rehash(t.hash, node.left.hash, hash, true)
-		} else if node.right != nil {
-			node.parent.rehash(t.hash, hash, node.right.hash, true)
+// Truncate removes the leaves after the given number of leaves, restoring
+// the root the tree had when it had that many leaves. The removed nodes are
+// discarded if the node store implements NodeStoreTruncater.
+func (t *DynTree) Truncate(size int) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if size < 0 || size > t.size {
+		return types.ErrSizeOutOfRange
+	}
+
+	t.truncate(size)
+	t.persist(func(l *dynTreeLog) error { return l.truncate(size) })
+
+	return nil
+}
+
+// Removes the leaves after the given number of leaves and recomputes the
+// ancestors of the new last leaf, which are the only nodes affected by the
+// removal.
+func (t *DynTree) truncate(size int) {
+	if truncater, ok := t.store.(NodeStoreTruncater); ok {
+		for level := 0; level <= dynTreeHeight(t.size); level++ {
+			if err := truncater.Truncate(level, dynTreeLevelLen(size, level)); err != nil {
+				t.setErr(err)
+			}
 		}
 	}
+
+	t.size = size
+
+	if !t.paused && size > 0 {
+		t.putBatch(t.climb(size-1, t.get(0, size-1))[1:])
+	}
 }
 
 // Pause pauses the computation of hashes.
@@ -216,29 +399,299 @@ func (t *DynTree) Pause() {
 func (t *DynTree) Resume() {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
-	t.recompute()
+	t.recompute(false)
 	t.paused = false
 }
 
-func (t *DynTree) recompute() {
-	rows := t.leaves
+// Recomputes all the nodes from the leaves, one level at a time. If repair is
+// true, only the nodes that differ from the stored ones are written.
+func (t *DynTree) recompute(repair bool) {
+	for level := 1; level <= dynTreeHeight(t.size); level++ {
+		var (
+			below = dynTreeLevelLen(t.size, level-1)
+			nodes = make([]StoredNode, 0, dynTreeBatchLen)
+		)
+
+		for index := 0; index < dynTreeLevelLen(t.size, level); index++ {
+			hash := t.get(level-1, 2*index)
+			if 2*index+1 < below {
+				hash = t.hasher.HashNode(hash, t.get(level-1, 2*index+1))
+			}
+			if repair {
+				if stored, err := t.store.Get(level, index); err == nil && bytes.Equal(stored, hash) {
+					continue
+				}
+			}
+
+			nodes = append(nodes, StoredNode{Level: level, Index: index, Hash: hash})
+			if len(nodes) == cap(nodes) {
+				t.putBatch(nodes)
+				nodes = nodes[:0]
+			}
+		}
+
+		t.putBatch(nodes)
+	}
+}
+
+// Returns the hash of a node from the store. Errors are recorded in t.err and
+// nil is returned. While the tree is paused, the nodes above the leaves may
+// not have been computed, so missing nodes are not errors.
+func (t *DynTree) get(level, index int) []byte {
+	hash, err := t.store.Get(level, index)
+	if err == ErrNodeNotFound && t.paused && level > 0 {
+		return nil
+	}
+	if err != nil {
+		t.setErr(fmt.Errorf("cannot read node %d at level %d: %w", index, level, err))
+		return nil
+	}
+	return hash
+}
+
+// Writes a node to the store. Errors are recorded in t.err.
+func (t *DynTree) put(level, index int, hash []byte) {
+	if err := t.store.Put(level, index, hash); err != nil {
+		t.setErr(err)
+	}
+}
+
+// Writes nodes to the store. Errors are recorded in t.err.
+func (t *DynTree) putBatch(nodes []StoredNode) {
+	if len(nodes) < 1 {
+		return
+	}
+	if err := t.store.PutBatch(nodes); err != nil {
+		t.setErr(err)
+	}
+}
+
+// Records an error unless one was already recorded.
+func (t *DynTree) setErr(err error) {
+	t.errMutex.Lock()
+	defer t.errMutex.Unlock()
+
+	if t.err == nil {
+		t.err = err
+	}
+}
+
+// Returns the first error that occurred while accessing the log or the store.
+func (t *DynTree) firstErr() error {
+	t.errMutex.Lock()
+	defer t.errMutex.Unlock()
+
+	return t.err
+}
+
+// RootAt returns the root the tree had when it had the given number of
+// leaves. If some of these leaves were updated since, the root is computed
+// from their current values, so that it matches ConsistencyProof.
+func (t *DynTree) RootAt(size int) ([]byte, error) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return t.rootAt(size)
+}
+
+func (t *DynTree) rootAt(size int) ([]byte, error) {
+	if t.paused {
+		return nil, errors.New("tree is paused")
+	}
+	if size < 1 || size > t.size {
+		return nil, types.ErrSizeOutOfRange
+	}
+
+	root := t.subtreeHash(0, size)
+	if err := t.firstErr(); err != nil {
+		return nil, err
+	}
+
+	return root, nil
+}
+
+// SignTreeHead returns a tree head for the current root of the tree signed
+// with the given signer, using the current time as timestamp.
+func (t *DynTree) SignTreeHead(signer types.Signer) (*types.SignedTreeHead, error) {
+	sth, err := t.treeHead()
+	if err != nil {
+		return nil, err
+	}
+
+	// Sign without holding the lock since signing can be slow.
+	if err := sth.Sign(signer); err != nil {
+		return nil, err
+	}
+
+	return sth, nil
+}
+
+// SignCheckpoint returns a checkpoint for the current root of the tree as a
+// note signed with the given signer. The origin of the checkpoint is the name
+// of the key of the signer.
+func (t *DynTree) SignCheckpoint(signer *types.NoteSigner, extensions ...string) ([]byte, error) {
+	sth, err := t.treeHead()
+	if err != nil {
+		return nil, err
+	}
+
+	c := types.Checkpoint{
+		Origin:     signer.Name(),
+		TreeSize:   sth.TreeSize,
+		RootHash:   sth.RootHash,
+		Extensions: extensions,
+	}
+
+	return c.Sign(signer)
+}
+
+// Returns an unsigned tree head for the current root of the tree.
+func (t *DynTree) treeHead() (*types.SignedTreeHead, error) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	if t.paused {
+		return nil, errors.New("tree is paused")
+	}
+	if t.size < 1 {
+		return nil, errors.New("tree is empty")
+	}
+
+	root := t.root()
+	if err := t.firstErr(); err != nil {
+		return nil, err
+	}
+
+	return types.NewSignedTreeHead(t.size, root), nil
+}
+
+// ConsistencyProof returns a proof that the tree with the given old size is
+// a prefix of the tree with the given new size, in the format described in
+// section 2.1.2 of RFC 6962.
+//
+// The proof is computed from the current leaves of the tree, so it will not
+// match the historical roots if leaves were updated.
+func (t *DynTree) ConsistencyProof(oldSize, newSize int) ([][]byte, error) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return t.consistencyProof(oldSize, newSize)
+}
+
+func (t *DynTree) consistencyProof(oldSize, newSize int) ([][]byte, error) {
+	if t.paused {
+		return nil, errors.New("tree is paused")
+	}
+	if oldSize < 1 || oldSize > newSize || newSize > t.size {
+		return nil, types.ErrSizeOutOfRange
+	}
+
+	proof := t.subtreeHashes(types.ConsistencySubtrees(oldSize, newSize))
+	if err := t.firstErr(); err != nil {
+		return nil, err
+	}
+
+	return proof, nil
+}
+
+// InclusionProof returns a proof that the leaf at the given index is included
+// in the tree with the given size, which can be smaller than the current size
+// of the tree. The siblings are the audit path described in section 2.1.1 of
+// RFC 6962.
+//
+// Like consistency proofs, the proof is computed from the current leaves of
+// the tree.
+func (t *DynTree) InclusionProof(index, size int) (*types.InclusionProof, error) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	if t.paused {
+		return nil, errors.New("tree is paused")
+	}
+	if size < 1 || size > t.size {
+		return nil, types.ErrSizeOutOfRange
+	}
+	if index < 0 || index >= size {
+		return nil, types.ErrIndexOutOfRange
+	}
+
+	siblings := t.subtreeHashes(types.InclusionSubtrees(index, size))
+	if err := t.firstErr(); err != nil {
+		return nil, err
+	}
+
+	return &types.InclusionProof{
+		Index:    index,
+		TreeSize: size,
+		Siblings: siblings,
+	}, nil
+}
+
+// Returns the hashes of the given subtrees, computed from the stored nodes.
+func (t *DynTree) subtreeHashes(subtrees []types.Subtree) [][]byte {
+	var hashes [][]byte
+	for _, s := range subtrees {
+		hashes = append(hashes, t.subtreeHash(s.Start, s.End))
+	}
+	return hashes
+}
+
+// Computes the hash of the subtree containing the leaves from start to end
+// (exclusive). The complete subtrees it is made of are nodes of the tree.
+func (t *DynTree) subtreeHash(start, end int) []byte {
+	return types.Subtree{Start: start, End: end}.Hash(t.hasher, t.get)
+}
+
+// Returns the number of nodes stored at the given level, assuming they are
+// contiguous, using a binary search.
+func nodeStoreLevelLen(store NodeStore, level int) (int, error) {
+	has := func(index int) (bool, error) {
+		_, err := store.Get(level, index)
+		if err == ErrNodeNotFound {
+			return false, nil
+		}
+		return err == nil, err
+	}
 
+	// Find an upper bound by doubling, then search below it.
+	hi := 1
 	for {
-		if len(rows) < 1 {
+		ok, err := has(hi - 1)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
 			break
 		}
+		hi *= 2
+	}
 
-		top := make([]*DynTreeNode, 0, len(rows)/2)
-		height := rows[0].height
-
-		for i := 0; i < len(rows); i += 2 {
-			node := rows[i]
-			if node.parent != nil && node.parent.height == height+1 {
-				node.parent.rehash(t.hash, node.hash, node.right.hash, false)
-				top = append(top, node.parent)
-			}
+	lo := hi / 2
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		ok, err := has(mid)
+		if err != nil {
+			return 0, err
 		}
+		if ok {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
 
-		rows = top
+	return lo, nil
+}
+
+// Returns the number of levels above the leaves of a tree of the given size.
+func dynTreeHeight(size int) int {
+	if size < 2 {
+		return 0
 	}
+	return bits.Len(uint(size - 1))
+}
+
+// Returns the number of nodes at the given level of a tree of the given size.
+func dynTreeLevelLen(size, level int) int {
+	return (size + 1<<uint(level) - 1) >> uint(level)
 }
@@ -15,38 +15,93 @@
 package merkle_test
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	crand "crypto/rand"
 	"encoding/hex"
+	"math/rand"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stratumn/merkle"
 	"github.com/stratumn/merkle/testutil"
 	"github.com/stratumn/merkle/treetestcases"
+	"github.com/stratumn/merkle/types"
 )
 
 func TestDynTree(t *testing.T) {
-	treetestcases.Factory{
-		New: func(leaves [][]byte) (merkle.Tree, error) {
-			tree := merkle.NewDynTree(len(leaves))
-			for _, leaf := range leaves {
-				tree.Add(leaf)
-			}
-			return tree, nil
-		},
-	}.RunTests(t)
+	for _, h := range hashers {
+		hasher := h.hasher
+		t.Run(h.name, treetestcases.Factory{
+			New: func(leaves [][]byte) (merkle.Tree, error) {
+				tree := merkle.NewDynTree(len(leaves), merkle.WithHasher(hasher))
+				for _, leaf := range leaves {
+					tree.Add(leaf)
+				}
+				return tree, nil
+			},
+			Hasher: hasher,
+		}.RunTests)
+	}
 }
 
 func TestDynTreePause(t *testing.T) {
-	treetestcases.Factory{
-		New: func(leaves [][]byte) (merkle.Tree, error) {
-			tree := merkle.NewDynTree(len(leaves))
-			tree.Pause()
-			for _, leaf := range leaves {
-				tree.Add(leaf)
-			}
-			tree.Resume()
-			return tree, nil
-		},
-	}.RunTests(t)
+	for _, h := range hashers {
+		hasher := h.hasher
+		t.Run(h.name, treetestcases.Factory{
+			New: func(leaves [][]byte) (merkle.Tree, error) {
+				tree := merkle.NewDynTree(len(leaves), merkle.WithHasher(hasher))
+				tree.Pause()
+				for _, leaf := range leaves {
+					tree.Add(leaf)
+				}
+				tree.Resume()
+				return tree, nil
+			},
+			Hasher: hasher,
+		}.RunTests)
+	}
+}
+
+func TestDynTreeGrow(t *testing.T) {
+	for _, h := range hashers {
+		hasher := h.hasher
+		t.Run(h.name, treetestcases.Factory{
+			New: func(leaves [][]byte) (merkle.Tree, error) {
+				tree := merkle.NewDynTree(0, merkle.WithHasher(hasher))
+				for _, leaf := range leaves {
+					tree.Add(leaf)
+				}
+				return tree, nil
+			},
+			Hasher: hasher,
+		}.RunTests)
+	}
+}
+
+func TestDynTreeGrow_truncate(t *testing.T) {
+	var (
+		leaves = make([][]byte, 5000)
+		tree   = merkle.NewDynTree(1)
+	)
+
+	for i := range leaves {
+		leaves[i] = testutil.RandomHash()
+		tree.Add(leaves[i])
+	}
+	checkStaticTree(t, "SHA-256", types.SHA256, tree, leaves)
+
+	if err := tree.Truncate(3); err != nil {
+		t.Fatalf("tree.Truncate(): err: %s", err)
+	}
+	checkStaticTree(t, "SHA-256", types.SHA256, tree, leaves[:3])
+
+	for _, leaf := range leaves[3:] {
+		tree.Add(leaf)
+	}
+	checkStaticTree(t, "SHA-256", types.SHA256, tree, leaves)
 }
 
 func TestDynTreeUpdate(t *testing.T) {
@@ -109,3 +164,586 @@ func BenchmarkDynTreePause(b *testing.B) {
 		},
 	}.RunBenchmarks(b)
 }
+
+func BenchmarkDynTreeAdd(b *testing.B) {
+	benchmarkDynTreeAdd(b, b.N)
+}
+
+func BenchmarkDynTreeAdd_grow(b *testing.B) {
+	benchmarkDynTreeAdd(b, 0)
+}
+
+func benchmarkDynTreeAdd(b *testing.B, initialCap int) {
+	leaves := make([][]byte, b.N)
+	for i := range leaves {
+		leaves[i] = testutil.RandomHash()
+	}
+
+	tree := merkle.NewDynTree(initialCap)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		tree.Add(leaves[i])
+	}
+}
+
+func TestDynTreeConsistencyProof(t *testing.T) {
+	for _, h := range hashers {
+		var (
+			size  = 1 + rand.Intn(300)
+			tree  = merkle.NewDynTree(size, merkle.WithHasher(h.hasher))
+			roots = make([][]byte, size)
+		)
+
+		for i := range roots {
+			tree.Add(testutil.RandomHash())
+			roots[i] = tree.Root()
+		}
+
+		for i := 0; i < 100; i++ {
+			newSize := 1 + rand.Intn(size)
+			oldSize := 1 + rand.Intn(newSize)
+
+			for _, s := range []int{oldSize, newSize} {
+				root, err := tree.RootAt(s)
+				if err != nil {
+					t.Fatalf("%s: tree.RootAt(%d): err: %s", h.name, s, err)
+				}
+				if got, want := hex.EncodeToString(root), hex.EncodeToString(roots[s-1]); got != want {
+					t.Errorf("%s: tree.RootAt(%d) = %q want %q", h.name, s, got, want)
+				}
+			}
+
+			proof, err := tree.ConsistencyProof(oldSize, newSize)
+			if err != nil {
+				t.Fatalf("%s: tree.ConsistencyProof(%d, %d): err: %s", h.name, oldSize, newSize, err)
+			}
+
+			err = types.VerifyConsistencyWith(h.hasher, oldSize, newSize, roots[oldSize-1], roots[newSize-1], proof)
+			if err != nil {
+				t.Errorf("%s: types.VerifyConsistencyWith(%d, %d): err: %s", h.name, oldSize, newSize, err)
+			}
+		}
+	}
+}
+
+func TestDynTreeConsistencyProof_RFC6962(t *testing.T) {
+	tree := merkle.NewDynTree(len(rfc6962Leaves), merkle.WithHasher(types.RFC6962SHA256))
+	for _, leaf := range rfc6962Leaves {
+		tree.Add(leaf)
+	}
+
+	tests := []struct {
+		oldSize int
+		newSize int
+		proof   []string
+	}{
+		{1, 1, nil},
+		{1, 8, []string{
+			"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4",
+		}},
+		{6, 8, []string{
+			"0ebc5d3437fbe2db158b9f126a1d118e308181031d0a949f8dededebc558ef6a",
+			"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+			"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		}},
+		{2, 5, []string{
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+		}},
+	}
+
+	for _, test := range tests {
+		proof, err := tree.ConsistencyProof(test.oldSize, test.newSize)
+		if err != nil {
+			t.Fatalf("tree.ConsistencyProof(%d, %d): err: %s", test.oldSize, test.newSize, err)
+		}
+		got := make([]string, len(proof))
+		for i, h := range proof {
+			got[i] = hex.EncodeToString(h)
+		}
+		if !reflect.DeepEqual(got, append([]string{}, test.proof...)) {
+			t.Errorf("tree.ConsistencyProof(%d, %d) = %v want %v", test.oldSize, test.newSize, got, test.proof)
+		}
+	}
+}
+
+func TestDynTreeInclusionProof(t *testing.T) {
+	for _, h := range hashers {
+		var (
+			leaves = make([][]byte, 40)
+			tree   = merkle.NewDynTree(len(leaves), merkle.WithHasher(h.hasher))
+		)
+
+		for i := range leaves {
+			leaves[i] = testutil.RandomHash()
+			tree.Add(leaves[i])
+		}
+
+		for size := 1; size <= len(leaves); size++ {
+			root, err := tree.RootAt(size)
+			if err != nil {
+				t.Fatalf("%s: tree.RootAt(%d): err: %s", h.name, size, err)
+			}
+
+			for index := 0; index < size; index++ {
+				p, err := tree.InclusionProof(index, size)
+				if err != nil {
+					t.Fatalf("%s: tree.InclusionProof(%d, %d): err: %s", h.name, index, size, err)
+				}
+				if err := p.VerifyWith(h.hasher, tree.Leaf(index), root); err != nil {
+					t.Errorf("%s: tree.InclusionProof(%d, %d): p.VerifyWith(): err: %s", h.name, index, size, err)
+				}
+			}
+		}
+	}
+}
+
+func TestDynTreeInclusionProof_Error(t *testing.T) {
+	tree := merkle.NewDynTree(3)
+	for i := 0; i < 3; i++ {
+		tree.Add(testutil.RandomHash())
+	}
+
+	tests := []struct {
+		index, size int
+		want        error
+	}{
+		{0, 0, types.ErrSizeOutOfRange},
+		{0, 4, types.ErrSizeOutOfRange},
+		{2, 2, types.ErrIndexOutOfRange},
+		{-1, 3, types.ErrIndexOutOfRange},
+	}
+
+	for _, tt := range tests {
+		if _, err := tree.InclusionProof(tt.index, tt.size); err != tt.want {
+			t.Errorf("tree.InclusionProof(%d, %d): err = %v want %v", tt.index, tt.size, err, tt.want)
+		}
+	}
+}
+
+func TestDynTreeConsistencyProof_Error(t *testing.T) {
+	tree := merkle.NewDynTree(4)
+	for i := 0; i < 4; i++ {
+		tree.Add(testutil.RandomHash())
+	}
+
+	for _, sizes := range [][2]int{{0, 1}, {3, 2}, {1, 5}} {
+		if _, err := tree.ConsistencyProof(sizes[0], sizes[1]); err != types.ErrSizeOutOfRange {
+			t.Errorf("tree.ConsistencyProof(%d, %d): err = %v want %v", sizes[0], sizes[1], err, types.ErrSizeOutOfRange)
+		}
+	}
+
+	if _, err := tree.RootAt(5); err != types.ErrSizeOutOfRange {
+		t.Errorf("tree.RootAt(5): err = %v want %v", err, types.ErrSizeOutOfRange)
+	}
+}
+
+func TestDynTreeRootAt_pause(t *testing.T) {
+	var (
+		leaves = make([][]byte, 50)
+		roots  = make([][]byte, len(leaves))
+		tree   = merkle.NewDynTree(len(leaves))
+	)
+	for i := range leaves {
+		leaves[i] = testutil.RandomHash()
+		tree.Add(leaves[i])
+		roots[i] = tree.Root()
+	}
+
+	paused := merkle.NewDynTree(len(leaves))
+	paused.Pause()
+	for _, leaf := range leaves {
+		paused.Add(leaf)
+	}
+	paused.Resume()
+
+	for i := range leaves {
+		root, err := paused.RootAt(i + 1)
+		if err != nil {
+			t.Fatalf("paused.RootAt(%d): err: %s", i+1, err)
+		}
+		if got, want := hex.EncodeToString(root), hex.EncodeToString(roots[i]); got != want {
+			t.Errorf("paused.RootAt(%d) = %q want %q", i+1, got, want)
+		}
+	}
+}
+
+func TestDynTreeRootAt_update(t *testing.T) {
+	var (
+		leaves = make([][]byte, 20)
+		tree   = merkle.NewDynTree(len(leaves))
+	)
+	for i := range leaves {
+		leaves[i] = testutil.RandomHash()
+		tree.Add(leaves[i])
+	}
+
+	leaves[7] = testutil.RandomHash()
+	tree.Update(7, leaves[7])
+
+	root, err := tree.RootAt(len(leaves))
+	if err != nil {
+		t.Fatalf("tree.RootAt(): err: %s", err)
+	}
+	if got, want := hex.EncodeToString(root), hex.EncodeToString(tree.Root()); got != want {
+		t.Errorf("tree.RootAt(%d) = %q want %q", len(leaves), got, want)
+	}
+
+	for _, oldSize := range []int{3, 8, 12} {
+		oldRoot, err := tree.RootAt(oldSize)
+		if err != nil {
+			t.Fatalf("tree.RootAt(%d): err: %s", oldSize, err)
+		}
+		proof, err := tree.ConsistencyProof(oldSize, len(leaves))
+		if err != nil {
+			t.Fatalf("tree.ConsistencyProof(%d): err: %s", oldSize, err)
+		}
+		if err := types.VerifyConsistency(oldSize, len(leaves), oldRoot, root, proof); err != nil {
+			t.Errorf("types.VerifyConsistency(%d): err: %s", oldSize, err)
+		}
+	}
+
+	// Truncating restores the root of the updated leaves.
+	if err := tree.Truncate(10); err != nil {
+		t.Fatalf("tree.Truncate(): err: %s", err)
+	}
+	checkStaticTree(t, "truncate", types.SHA256, tree, leaves[:10])
+
+	root, err = tree.RootAt(10)
+	if err != nil {
+		t.Fatalf("tree.RootAt(): err: %s", err)
+	}
+	if got, want := hex.EncodeToString(root), hex.EncodeToString(tree.Root()); got != want {
+		t.Errorf("tree.RootAt(10) = %q want %q", got, want)
+	}
+}
+
+func TestDynTreeTruncate(t *testing.T) {
+	for _, h := range hashers {
+		for i := 0; i < 20; i++ {
+			var (
+				size   = 1 + rand.Intn(300)
+				leaves = make([][]byte, size)
+				tree   = merkle.NewDynTree(size, merkle.WithHasher(h.hasher))
+			)
+
+			for j := range leaves {
+				leaves[j] = testutil.RandomHash()
+				tree.Add(leaves[j])
+			}
+
+			newSize := 1 + rand.Intn(size)
+			if err := tree.Truncate(newSize); err != nil {
+				t.Fatalf("%s: tree.Truncate(%d): err: %s", h.name, newSize, err)
+			}
+			checkStaticTree(t, h.name, h.hasher, tree, leaves[:newSize])
+
+			// The tree must still grow correctly after being truncated.
+			for j := newSize; j < size; j++ {
+				tree.Add(leaves[j])
+			}
+			checkStaticTree(t, h.name, h.hasher, tree, leaves)
+		}
+	}
+}
+
+func TestDynTreeRemoveLast(t *testing.T) {
+	var (
+		leaves = make([][]byte, 40)
+		tree   = merkle.NewDynTree(len(leaves))
+	)
+
+	for i := range leaves {
+		leaves[i] = testutil.RandomHash()
+		tree.Add(leaves[i])
+	}
+
+	for i := len(leaves) - 1; i > 0; i-- {
+		if err := tree.RemoveLast(); err != nil {
+			t.Fatalf("tree.RemoveLast(): err: %s", err)
+		}
+		checkStaticTree(t, "SHA-256", types.SHA256, tree, leaves[:i])
+	}
+
+	if err := tree.RemoveLast(); err != nil {
+		t.Fatalf("tree.RemoveLast(): err: %s", err)
+	}
+	if got, want := tree.LeavesLen(), 0; got != want {
+		t.Errorf("tree.LeavesLen() = %d want %d", got, want)
+	}
+	if err := tree.RemoveLast(); err == nil {
+		t.Error("tree.RemoveLast(): err = nil want Error")
+	}
+
+	for _, leaf := range leaves {
+		tree.Add(leaf)
+	}
+	checkStaticTree(t, "SHA-256", types.SHA256, tree, leaves)
+}
+
+func TestDynTreeTruncate_pause(t *testing.T) {
+	var (
+		leaves = make([][]byte, 100)
+		tree   = merkle.NewDynTree(len(leaves))
+	)
+
+	for i := range leaves {
+		leaves[i] = testutil.RandomHash()
+		tree.Add(leaves[i])
+	}
+
+	tree.Pause()
+	if err := tree.Truncate(37); err != nil {
+		t.Fatalf("tree.Truncate(): err: %s", err)
+	}
+	tree.Resume()
+
+	checkStaticTree(t, "SHA-256", types.SHA256, tree, leaves[:37])
+}
+
+func TestDynTreeTruncate_Error(t *testing.T) {
+	tree := merkle.NewDynTree(2)
+	tree.Add(testutil.RandomHash())
+	tree.Add(testutil.RandomHash())
+
+	for _, size := range []int{-1, 3} {
+		if err := tree.Truncate(size); err != types.ErrSizeOutOfRange {
+			t.Errorf("tree.Truncate(%d): err = %v want %v", size, err, types.ErrSizeOutOfRange)
+		}
+	}
+}
+
+// Checks that a tree has the same root and paths as a StaticTree built
+// from the given leaves.
+func checkStaticTree(t *testing.T, name string, hasher types.Hasher, tree merkle.Tree, leaves [][]byte) {
+	static, err := merkle.NewStaticTree(leaves, merkle.WithHasher(hasher))
+	if err != nil {
+		t.Fatalf("%s: merkle.NewStaticTree(): err: %s", name, err)
+	}
+
+	if got, want := tree.LeavesLen(), static.LeavesLen(); got != want {
+		t.Errorf("%s: tree.LeavesLen() = %d want %d", name, got, want)
+	}
+	if got, want := hex.EncodeToString(tree.Root()), hex.EncodeToString(static.Root()); got != want {
+		t.Errorf("%s: %d leaves: tree.Root() = %q want %q", name, len(leaves), got, want)
+	}
+
+	for i := range leaves {
+		if got, want := tree.Path(i), static.Path(i); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: %d leaves: tree.Path(%d) = %v want %v", name, len(leaves), i, got, want)
+		}
+	}
+}
+
+func TestDynTreeView(t *testing.T) {
+	for _, h := range hashers {
+		var (
+			leaves = make([][]byte, 1+rand.Intn(300))
+			tree   = merkle.NewDynTree(len(leaves), merkle.WithHasher(h.hasher))
+		)
+
+		for i := range leaves {
+			leaves[i] = testutil.RandomHash()
+			tree.Add(leaves[i])
+		}
+
+		tree.View(func(s *merkle.DynTreeSnapshot) {
+			checkStaticTree(t, h.name, h.hasher, s, leaves)
+		})
+	}
+}
+
+func TestDynTreeView_consistency(t *testing.T) {
+	tree := merkle.NewDynTree(0, merkle.WithHasher(types.RFC6962SHA256))
+	for i := 0; i < 9; i++ {
+		tree.Add(testutil.RandomHash())
+	}
+
+	tree.View(func(s *merkle.DynTreeSnapshot) {
+		for first := 1; first <= s.LeavesLen(); first++ {
+			oldRoot, err := s.RootAt(first)
+			if err != nil {
+				t.Fatalf("s.RootAt(%d): err: %s", first, err)
+			}
+			proof, err := s.ConsistencyProof(first, s.LeavesLen())
+			if err != nil {
+				t.Fatalf("s.ConsistencyProof(%d): err: %s", first, err)
+			}
+			err = types.VerifyConsistencyWith(types.RFC6962SHA256, first, s.LeavesLen(), oldRoot, s.Root(), proof)
+			if err != nil {
+				t.Errorf("s.ConsistencyProof(%d): types.VerifyConsistencyWith(): err: %s", first, err)
+			}
+		}
+
+		if _, err := s.RootAt(10); err != types.ErrSizeOutOfRange {
+			t.Errorf("s.RootAt(10): err = %v want %v", err, types.ErrSizeOutOfRange)
+		}
+	})
+}
+
+func TestDynTreeView_race(t *testing.T) {
+	var (
+		tree = merkle.NewDynTree(0)
+		done = make(chan struct{})
+		wg   sync.WaitGroup
+	)
+
+	tree.Add(testutil.RandomHash())
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+
+		for i := 0; i < 2000; i++ {
+			tree.Add(testutil.RandomHash())
+			if i%100 == 0 {
+				tree.Update(rand.Intn(i+1), testutil.RandomHash())
+			}
+		}
+	}()
+
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				tree.View(func(s *merkle.DynTreeSnapshot) {
+					var (
+						size  = s.LeavesLen()
+						root  = s.Root()
+						index = rand.Intn(size)
+						leaf  = s.Leaf(index)
+						path  = s.Path(index)
+					)
+
+					if err := types.VerifyInclusion(leaf, root, index, size, path); err != nil {
+						t.Errorf("types.VerifyInclusion(%d, %d): err: %s", index, size, err)
+					}
+				})
+
+				// Reads outside of a view must be safe too.
+				tree.Root()
+				tree.Leaf(tree.LeavesLen() - 1)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestDynTreeAppend(t *testing.T) {
+	var (
+		tree = merkle.NewDynTree(0)
+		wg   sync.WaitGroup
+	)
+
+	leaves := make([][]byte, 100)
+	for i := range leaves {
+		leaves[i] = testutil.RandomHash()
+	}
+
+	indices := make([]int, len(leaves))
+	for i := range leaves {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			indices[i] = tree.Append(leaves[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for i, index := range indices {
+		if got, want := tree.Leaf(index), leaves[i]; !bytes.Equal(got, want) {
+			t.Errorf("tree.Leaf(%d) = %x want %x", index, got, want)
+		}
+	}
+}
+
+func TestDynTreeSignTreeHead(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(crand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey(): err: %s", err)
+	}
+	signer, err := types.NewEd25519Signer(key)
+	if err != nil {
+		t.Fatalf("types.NewEd25519Signer(): err: %s", err)
+	}
+	verifier, err := types.NewVerifier(signer.Public())
+	if err != nil {
+		t.Fatalf("types.NewVerifier(): err: %s", err)
+	}
+
+	tree := merkle.NewDynTree(0)
+	if _, err := tree.SignTreeHead(signer); err == nil {
+		t.Error("tree.SignTreeHead(): err = nil want Error")
+	}
+
+	for i := 0; i < 9; i++ {
+		tree.Add(testutil.RandomHash())
+	}
+
+	before := time.Now()
+	sth, err := tree.SignTreeHead(signer)
+	if err != nil {
+		t.Fatalf("tree.SignTreeHead(): err: %s", err)
+	}
+
+	if got, want := sth.TreeSize, 9; got != want {
+		t.Errorf("sth.TreeSize = %d want %d", got, want)
+	}
+	if got, want := sth.RootHash, tree.Root(); !bytes.Equal(got, want) {
+		t.Errorf("sth.RootHash = %x want %x", got, want)
+	}
+	if got := sth.Time(); got.Before(before.Truncate(time.Millisecond)) || got.After(time.Now()) {
+		t.Errorf("sth.Time() = %s want about %s", got, before)
+	}
+	if err := sth.Verify(verifier); err != nil {
+		t.Errorf("sth.Verify(): err: %s", err)
+	}
+
+	tree.Pause()
+	if _, err := tree.SignTreeHead(signer); err == nil {
+		t.Error("tree.SignTreeHead() when paused: err = nil want Error")
+	}
+}
+
+func TestDynTreeSignCheckpoint(t *testing.T) {
+	skey, vkey, err := types.GenerateNoteKey(crand.Reader, "example.com/log")
+	if err != nil {
+		t.Fatalf("types.GenerateNoteKey(): err: %s", err)
+	}
+	signer, _ := types.NewNoteSigner(skey)
+	verifier, _ := types.NewNoteVerifier(vkey)
+
+	tree := merkle.NewDynTree(0, merkle.WithHasher(types.RFC6962SHA256))
+	for i := 0; i < 5; i++ {
+		tree.Add(testutil.RandomHash())
+	}
+
+	msg, err := tree.SignCheckpoint(signer, "extension")
+	if err != nil {
+		t.Fatalf("tree.SignCheckpoint(): err: %s", err)
+	}
+
+	c, err := types.OpenCheckpoint(msg, verifier)
+	if err != nil {
+		t.Fatalf("types.OpenCheckpoint(): err: %s", err)
+	}
+	want := &types.Checkpoint{Origin: "example.com/log", TreeSize: 5, RootHash: tree.Root(), Extensions: []string{"extension"}}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("types.OpenCheckpoint() = %v want %v", c, want)
+	}
+}
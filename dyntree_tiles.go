@@ -0,0 +1,224 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/stratumn/merkle/types"
+)
+
+// TileData returns the data of a tile of the tree, which is the concatenation
+// of the hashes it contains. See types.Tile.
+//
+// Tiles are meant for trees that only grow. Updating or removing leaves
+// changes the content of tiles that may already have been published.
+func (t *DynTree) TileData(tile types.Tile) ([]byte, error) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return t.tileData(tile)
+}
+
+func (t *DynTree) tileData(tile types.Tile) ([]byte, error) {
+	if t.paused {
+		return nil, errors.New("tree is paused")
+	}
+
+	if tile.Height < 1 || tile.Height > types.MaxTileHeight || tile.Level < 0 || tile.Index < 0 ||
+		tile.Width < 1 || tile.Width > 1<<uint(tile.Height) {
+		return nil, types.ErrInvalidTile
+	}
+
+	var (
+		level = tile.Level * tile.Height
+		start = tile.Index << uint(tile.Height)
+	)
+
+	// Only the nodes of complete subtrees belong to tiles.
+	if level >= 63 || start+tile.Width > t.size>>uint(level) {
+		return nil, types.ErrIndexOutOfRange
+	}
+
+	data := make([]byte, 0, tile.Width*t.hasher.Size())
+	for i := start; i < start+tile.Width; i++ {
+		data = append(data, t.get(level, i)...)
+	}
+
+	return data, nil
+}
+
+// TileReader returns a reader of the tiles of the given height of the tree.
+func (t *DynTree) TileReader(height int) types.TileReader {
+	return &dynTreeTileReader{tree: t, height: height}
+}
+
+type dynTreeTileReader struct {
+	tree   *DynTree
+	height int
+}
+
+func (r *dynTreeTileReader) Height() int {
+	return r.height
+}
+
+func (r *dynTreeTileReader) ReadTiles(tiles []types.Tile) ([][]byte, error) {
+	r.tree.mutex.RLock()
+	defer r.tree.mutex.RUnlock()
+
+	data := make([][]byte, len(tiles))
+	for i, tile := range tiles {
+		if tile.Height != r.height {
+			return nil, types.ErrInvalidTile
+		}
+
+		var err error
+		if data[i], err = r.tree.tileData(tile); err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// WriteTiles writes the tiles of the given height that changed since the tree
+// had the given number of leaves to files in a directory, using the paths of
+// the tiles. The directory can then be served by any static file server.
+func (t *DynTree) WriteTiles(dir string, height, oldSize int) error {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	tiles, err := types.NewTiles(height, oldSize, t.size)
+	if err != nil {
+		return err
+	}
+
+	for _, tile := range tiles {
+		data, err := t.tileData(tile)
+		if err != nil {
+			return err
+		}
+
+		filename := filepath.Join(dir, filepath.FromSlash(tile.Path()))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(filename, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReadDynTreeTiles creates a DynTree with the given number of leaves from its
+// tiles. The leaves are read from the tiles at level zero, and the root of the
+// tree is checked against the other tiles.
+func ReadDynTreeTiles(r types.TileReader, treeSize int, opts ...Option) (*DynTree, error) {
+	if treeSize < 1 {
+		return nil, types.ErrSizeOutOfRange
+	}
+
+	tree := NewDynTree(treeSize, opts...)
+
+	all, err := types.NewTiles(r.Height(), 0, treeSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var tiles []types.Tile
+	for _, tile := range all {
+		if tile.Level == 0 {
+			tiles = append(tiles, tile)
+		}
+	}
+
+	data, err := r.ReadTiles(tiles)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != len(tiles) {
+		return nil, types.ErrInvalidTile
+	}
+
+	tree.Pause()
+	for i, tile := range tiles {
+		if err := types.CheckTileData(tree.hasher, tile, data[i]); err != nil {
+			return nil, err
+		}
+
+		size := len(data[i]) / tile.Width
+		if i > 0 && size != len(data[0])/tiles[0].Width {
+			return nil, fmt.Errorf("%w: %s has hashes of %d bytes", types.ErrInvalidTile, tile.Path(), size)
+		}
+
+		for j := 0; j < tile.Width; j++ {
+			tree.add(data[i][j*size : (j+1)*size : (j+1)*size])
+		}
+	}
+	tree.Resume()
+
+	if err := tree.Err(); err != nil {
+		return nil, err
+	}
+
+	root, err := types.NewTileTreeWith(tree.hasher, r, treeSize).Root()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(root, tree.Root()) {
+		return nil, types.ErrRootMismatch
+	}
+
+	return tree, nil
+}
+
+// DirTileReader reads tiles from the files of a directory written by
+// DynTree.WriteTiles.
+type DirTileReader struct {
+	dir    string
+	height int
+}
+
+// NewDirTileReader creates a DirTileReader for the tiles of the given height
+// stored in a directory.
+func NewDirTileReader(dir string, height int) *DirTileReader {
+	return &DirTileReader{dir: dir, height: height}
+}
+
+// Height implements types.TileReader.Height.
+func (r *DirTileReader) Height() int {
+	return r.height
+}
+
+// ReadTiles implements types.TileReader.ReadTiles.
+func (r *DirTileReader) ReadTiles(tiles []types.Tile) ([][]byte, error) {
+	data := make([][]byte, len(tiles))
+
+	for i, tile := range tiles {
+		var err error
+		data[i], err = ioutil.ReadFile(filepath.Join(r.dir, filepath.FromSlash(tile.Path())))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
@@ -0,0 +1,206 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkle_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stratumn/merkle"
+	"github.com/stratumn/merkle/testutil"
+	"github.com/stratumn/merkle/types"
+)
+
+func TestDynTreeTileReader(t *testing.T) {
+	for _, h := range hashers {
+		tree := merkle.NewDynTree(0, merkle.WithHasher(h.hasher))
+
+		for size := 1; size <= 70; size++ {
+			tree.Add(testutil.RandomHash())
+			root := tree.Root()
+
+			for _, height := range []int{1, 2, 3, 8} {
+				tiles := types.NewTileTreeWith(h.hasher, tree.TileReader(height), size)
+
+				got, err := tiles.Root()
+				if err != nil {
+					t.Fatalf("%s: tiles.Root(): err: %s", h.name, err)
+				}
+				if !bytes.Equal(got, root) {
+					t.Errorf("%s: size %d height %d: tiles.Root() = %x want %x", h.name, size, height, got, root)
+				}
+
+				for index := 0; index < size; index++ {
+					leaf, err := tiles.Leaf(index)
+					if err != nil {
+						t.Fatalf("%s: tiles.Leaf(): err: %s", h.name, err)
+					}
+					if want := tree.Leaf(index); !bytes.Equal(leaf, want) {
+						t.Errorf("%s: size %d height %d: tiles.Leaf(%d) = %x want %x", h.name, size, height, index, leaf, want)
+					}
+
+					proof, err := tiles.InclusionProof(index)
+					if err != nil {
+						t.Fatalf("%s: tiles.InclusionProof(): err: %s", h.name, err)
+					}
+					if err := proof.VerifyWith(h.hasher, leaf, root); err != nil {
+						t.Errorf("%s: size %d height %d: proof.VerifyWith(%d): err: %s", h.name, size, height, index, err)
+					}
+				}
+
+				for oldSize := 1; oldSize <= size; oldSize++ {
+					got, err := tiles.ConsistencyProof(oldSize)
+					if err != nil {
+						t.Fatalf("%s: tiles.ConsistencyProof(): err: %s", h.name, err)
+					}
+					want, _ := tree.ConsistencyProof(oldSize, size)
+					if !reflect.DeepEqual(got, want) {
+						t.Errorf("%s: size %d height %d: tiles.ConsistencyProof(%d) = %x want %x", h.name, size, height, oldSize, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestDynTreeTileReader_Error(t *testing.T) {
+	tree := merkle.NewDynTree(0)
+	for i := 0; i < 5; i++ {
+		tree.Add(testutil.RandomHash())
+	}
+
+	tests := []struct {
+		name string
+		tile types.Tile
+		err  error
+	}{
+		{"width", types.Tile{Height: 2, Level: 0, Index: 0, Width: 5}, types.ErrInvalidTile},
+		{"height", types.Tile{Height: 0, Level: 0, Index: 0, Width: 1}, types.ErrInvalidTile},
+		{"incomplete", types.Tile{Height: 2, Level: 0, Index: 1, Width: 2}, types.ErrIndexOutOfRange},
+		{"level", types.Tile{Height: 2, Level: 1, Index: 0, Width: 2}, types.ErrIndexOutOfRange},
+	}
+
+	for _, tt := range tests {
+		if _, err := tree.TileData(tt.tile); err != tt.err {
+			t.Errorf("%s: tree.TileData() err = %v want %v", tt.name, err, tt.err)
+		}
+	}
+
+	if _, err := types.NewTileTree(tree.TileReader(2), 6).Root(); err != types.ErrIndexOutOfRange {
+		t.Errorf("tiles.Root() err = %v want %v", err, types.ErrIndexOutOfRange)
+	}
+	if _, err := merkle.ReadDynTreeTiles(tree.TileReader(0), 5); !errors.Is(err, types.ErrInvalidTile) {
+		t.Errorf("merkle.ReadDynTreeTiles() err = %v want %v", err, types.ErrInvalidTile)
+	}
+	if err := tree.WriteTiles(t.TempDir(), types.MaxTileHeight+1, 0); !errors.Is(err, types.ErrInvalidTile) {
+		t.Errorf("tree.WriteTiles() err = %v want %v", err, types.ErrInvalidTile)
+	}
+}
+
+func TestDynTreeWriteTiles(t *testing.T) {
+	const height = 2
+
+	var (
+		dir    = t.TempDir()
+		tree   = merkle.NewDynTree(0, merkle.WithHasher(types.RFC6962SHA256))
+		reader = merkle.NewDirTileReader(dir, height)
+		sizes  = []int{3, 4, 9, 17, 17, 40}
+		roots  = map[int][]byte{}
+	)
+
+	for i, size := range sizes {
+		for tree.LeavesLen() < size {
+			tree.Add(testutil.RandomHash())
+		}
+
+		oldSize := 0
+		if i > 0 {
+			oldSize = sizes[i-1]
+		}
+		if err := tree.WriteTiles(dir, height, oldSize); err != nil {
+			t.Fatalf("tree.WriteTiles(): err: %s", err)
+		}
+		roots[size] = tree.Root()
+
+		read, err := merkle.ReadDynTreeTiles(reader, size, merkle.WithHasher(types.RFC6962SHA256))
+		if err != nil {
+			t.Fatalf("merkle.ReadDynTreeTiles(): err: %s", err)
+		}
+		if got, want := read.Root(), roots[size]; !bytes.Equal(got, want) {
+			t.Errorf("size %d: read.Root() = %x want %x", size, got, want)
+		}
+		for j := 0; j < size; j++ {
+			if got, want := read.Leaf(j), tree.Leaf(j); !bytes.Equal(got, want) {
+				t.Errorf("size %d: read.Leaf(%d) = %x want %x", size, j, got, want)
+			}
+		}
+	}
+
+	// The partial tiles of previous sizes are kept, so proofs can be computed
+	// for any size that was published.
+	for _, size := range sizes {
+		tiles := types.NewTileTreeWith(types.RFC6962SHA256, reader, size)
+		for _, oldSize := range sizes {
+			if oldSize > size {
+				break
+			}
+			proof, err := tiles.ConsistencyProof(oldSize)
+			if err != nil {
+				t.Fatalf("tiles.ConsistencyProof(): err: %s", err)
+			}
+			if err := types.VerifyConsistencyWith(types.RFC6962SHA256, oldSize, size, roots[oldSize], roots[size], proof); err != nil {
+				t.Errorf("types.VerifyConsistencyWith(%d, %d): err: %s", oldSize, size, err)
+			}
+		}
+	}
+}
+
+func TestReadDynTreeTiles_Error(t *testing.T) {
+	const height = 2
+
+	dir := t.TempDir()
+	tree := merkle.NewDynTree(0)
+	for i := 0; i < 20; i++ {
+		tree.Add(testutil.RandomHash())
+	}
+	if err := tree.WriteTiles(dir, height, 0); err != nil {
+		t.Fatalf("tree.WriteTiles(): err: %s", err)
+	}
+
+	reader := merkle.NewDirTileReader(dir, height)
+
+	if _, err := merkle.ReadDynTreeTiles(reader, 21); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("merkle.ReadDynTreeTiles(21) err = %v want %v", err, os.ErrNotExist)
+	}
+
+	// Corrupt the tile containing the hash of the first sixteen leaves.
+	filename := filepath.Join(dir, filepath.FromSlash(types.Tile{Height: height, Level: 2, Index: 0, Width: 1}.Path()))
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("os.ReadFile(): err: %s", err)
+	}
+	data[0] ^= 1
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		t.Fatalf("os.WriteFile(): err: %s", err)
+	}
+
+	if _, err := merkle.ReadDynTreeTiles(reader, 20); err != types.ErrRootMismatch {
+		t.Errorf("merkle.ReadDynTreeTiles() err = %v want %v", err, types.ErrRootMismatch)
+	}
+}
@@ -0,0 +1,604 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkle
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/stratumn/merkle/types"
+)
+
+// A DynTree opened with OpenDynTree is persisted in a directory containing:
+//
+//   - a snapshot of the hashes of its leaves, which is replaced atomically
+//     when the tree is compacted,
+//   - a write-ahead log of the changes made since the snapshot, to which each
+//     change is appended before the method that made it returns.
+//
+// Both files start with a generation number that is incremented when the
+// tree is compacted, so that a log that was not reset after a snapshot was
+// written is ignored.
+const (
+	dynTreeSnapshotFile = "snapshot"
+	dynTreeLogFile      = "wal"
+
+	dynTreeSnapshotMagic = "MKDS"
+	dynTreeLogMagic      = "MKDL"
+	dynTreeFileVersion   = 1
+)
+
+// Types of log records.
+const (
+	dynTreeRecordAdd byte = iota + 1
+	dynTreeRecordUpdate
+	dynTreeRecordTruncate
+)
+
+// ErrInvalidDynTreeFile is returned when a file of a persisted DynTree is
+// not valid.
+var ErrInvalidDynTreeFile = errors.New("invalid dynamic tree file")
+
+// dynTreeLog appends the changes of a DynTree to its log.
+type dynTreeLog struct {
+	dir      string
+	file     *os.File
+	gen      uint64
+	records  int
+	interval int
+
+	// size is the number of bytes of the log, and snapshotSize the number of
+	// bytes of the snapshot it follows.
+	size         int64
+	snapshotSize int64
+}
+
+// OpenDynTree opens the DynTree persisted in the given directory, creating it
+// if it does not exist. The snapshot and the log of the tree are replayed to
+// restore the tree as it was after the last change that was fully written to
+// the log. The last record of the log is discarded if it was not fully
+// written, for instance because of a crash. Any other invalid record makes
+// OpenDynTree fail with ErrInvalidDynTreeFile, since discarding it would also
+// discard the changes that follow it.
+//
+// The hasher of the tree must be registered using types.RegisterHasher, and
+// must be the one the tree was created with.
+//
+// Changes are written to the log without being synced to the disk, so they
+// survive a crash of the process but not necessarily of the system unless
+// Sync is called. If writing to the log fails, the changes are still made in
+// memory and the error is returned by Err, Sync and Close.
+func OpenDynTree(dir string, opts ...Option) (*DynTree, error) {
+	o := newOptions(opts)
+
+	name, err := types.HasherName(o.hasher)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	snapshotPath := filepath.Join(dir, dynTreeSnapshotFile)
+	if _, err := os.Stat(snapshotPath); os.IsNotExist(err) {
+		if _, err := writeDynTreeSnapshot(dir, 0, name, nil); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	gen, snapshotName, leaves, err := readDynTreeSnapshot(snapshotPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(snapshotPath)
+	if err != nil {
+		return nil, err
+	}
+	if snapshotName != name {
+		return nil, fmt.Errorf("tree was created with hasher %q", snapshotName)
+	}
+
+	tree := NewDynTree(len(leaves), opts...)
+	tree.Pause()
+	for _, leaf := range leaves {
+		tree.add(leaf)
+	}
+
+	log, err := tree.replayLog(dir, gen)
+	if err != nil {
+		return nil, err
+	}
+	log.interval = o.compactInterval
+	log.snapshotSize = info.Size()
+
+	tree.Resume()
+	tree.log = log
+
+	return tree, nil
+}
+
+// Compact writes a snapshot of the leaves of a tree opened with OpenDynTree
+// and resets its log. It does nothing for other trees.
+func (t *DynTree) Compact() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.log == nil {
+		return nil
+	}
+
+	return t.compact()
+}
+
+// Sync commits the log of a tree opened with OpenDynTree to stable storage.
+// It returns the first error that occurred while writing to the log, if any.
+func (t *DynTree) Sync() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.err != nil || t.log == nil {
+		return t.err
+	}
+
+	return t.log.file.Sync()
+}
+
+// Close syncs and closes the log of a tree opened with OpenDynTree. It
+// returns the first error that occurred while writing to the log, if any. The
+// tree must not be modified after it is closed.
+func (t *DynTree) Close() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.log == nil {
+		return t.err
+	}
+
+	err := t.log.file.Sync()
+	if closeErr := t.log.file.Close(); err == nil {
+		err = closeErr
+	}
+	t.log = nil
+
+	if t.err != nil {
+		return t.err
+	}
+
+	return err
+}
+
+// Err returns the first error that occurred while writing to the log of a
+// tree opened with OpenDynTree or while accessing its node store, if any.
+// Methods that cannot return errors, such as Root, Leaf and Path, return nil
+// for the nodes that cannot be read.
+func (t *DynTree) Err() error {
+	return t.firstErr()
+}
+
+// Appends a change to the log, if any, and compacts the tree when the log
+// has enough records and is larger than the snapshot. Since a snapshot is
+// only written once the log has grown by at least its size, the cost of
+// compactions remains proportional to the size of the changes. Errors are
+// recorded in t.err.
+func (t *DynTree) persist(write func(l *dynTreeLog) error) {
+	if t.log == nil || t.err != nil {
+		return
+	}
+
+	if t.err = write(t.log); t.err != nil {
+		return
+	}
+
+	t.log.records++
+	if t.log.interval > 0 && t.log.records >= t.log.interval && t.log.size >= t.log.snapshotSize {
+		t.err = t.compact()
+	}
+}
+
+// Writes a snapshot of the leaves with the next generation and resets the
+// log.
+func (t *DynTree) compact() error {
+	name, err := types.HasherName(t.hasher)
+	if err != nil {
+		return err
+	}
+
+	leaves := make([][]byte, t.size)
+	for i := range leaves {
+		leaves[i] = t.get(0, i)
+	}
+
+	gen := t.log.gen + 1
+	snapshotSize, err := writeDynTreeSnapshot(t.log.dir, gen, name, leaves)
+	if err != nil {
+		return err
+	}
+
+	// Once the snapshot is written, the old log is ignored even if it cannot
+	// be reset.
+	file, err := createDynTreeLog(t.log.dir, gen)
+	if err != nil {
+		return err
+	}
+
+	t.log.file.Close()
+	t.log.file, t.log.gen, t.log.records = file, gen, 0
+	t.log.size, t.log.snapshotSize = int64(len(dynTreeHeader(dynTreeLogMagic, gen))), snapshotSize
+
+	return nil
+}
+
+// Replays the log of the given generation, discarding the records that were
+// not fully written, and returns the log opened for appending.
+func (t *DynTree) replayLog(dir string, gen uint64) (*dynTreeLog, error) {
+	logPath := filepath.Join(dir, dynTreeLogFile)
+
+	data, err := ioutil.ReadFile(logPath)
+	if os.IsNotExist(err) {
+		data = nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	logGen, body, err := parseDynTreeHeader(data, dynTreeLogMagic)
+	if data == nil || err == nil && logGen < gen {
+		// The log is missing or was not reset after the last snapshot.
+		file, err := createDynTreeLog(dir, gen)
+		if err != nil {
+			return nil, err
+		}
+		return &dynTreeLog{dir: dir, file: file, gen: gen, size: int64(len(dynTreeHeader(dynTreeLogMagic, gen)))}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if logGen != gen {
+		return nil, ErrInvalidDynTreeFile
+	}
+
+	var (
+		offset  = len(data) - len(body)
+		records = 0
+	)
+
+	for len(body) > 0 {
+		n, err := t.replayRecord(body)
+		if err == errTornRecord || err == errRecordChecksum && n == len(body) {
+			// The last record was not fully written.
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid record at offset %d", ErrInvalidDynTreeFile, offset)
+		}
+		body, offset, records = body[n:], offset+n, records+1
+	}
+
+	file, err := os.OpenFile(logPath, os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	// Discard the torn records at the end of the log.
+	if err := file.Truncate(int64(offset)); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(int64(offset), 0); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &dynTreeLog{dir: dir, file: file, gen: gen, records: records, size: int64(offset)}, nil
+}
+
+var (
+	// errTornRecord is returned when a record of a log ends after the end of
+	// the log.
+	errTornRecord = errors.New("torn record")
+
+	// errRecordChecksum is returned when the checksum of a record of a log
+	// does not match.
+	errRecordChecksum = errors.New("record checksum mismatch")
+
+	// errInvalidRecord is returned when a record of a log cannot be decoded
+	// or applied.
+	errInvalidRecord = errors.New("invalid record")
+)
+
+// Applies the record at the beginning of data and returns its length. It
+// returns errTornRecord if the record is incomplete, and errRecordChecksum
+// along with the length of the record if its checksum does not match.
+func (t *DynTree) replayRecord(data []byte) (int, error) {
+	r := data
+	if len(r) < 1 {
+		return 0, errTornRecord
+	}
+	typ := r[0]
+	r = r[1:]
+
+	readUvarint := func() (uint64, error) {
+		v, n := binary.Uvarint(r)
+		if n == 0 {
+			return 0, errTornRecord
+		}
+		if n < 0 {
+			return 0, errInvalidRecord
+		}
+		r = r[n:]
+		return v, nil
+	}
+
+	readBytes := func() ([]byte, error) {
+		l, err := readUvarint()
+		if err != nil {
+			return nil, err
+		}
+		if l > uint64(len(r)) {
+			return nil, errTornRecord
+		}
+		b := append([]byte(nil), r[:l]...)
+		r = r[l:]
+		return b, nil
+	}
+
+	var apply func() bool
+
+	switch typ {
+	case dynTreeRecordAdd:
+		leaf, err := readBytes()
+		if err != nil {
+			return 0, err
+		}
+		apply = func() bool {
+			t.add(leaf)
+			return true
+		}
+	case dynTreeRecordUpdate:
+		index, err := readUvarint()
+		if err != nil {
+			return 0, err
+		}
+		leaf, err := readBytes()
+		if err != nil {
+			return 0, err
+		}
+		apply = func() bool {
+			if index >= uint64(t.size) {
+				return false
+			}
+			t.update(int(index), leaf)
+			return true
+		}
+	case dynTreeRecordTruncate:
+		size, err := readUvarint()
+		if err != nil {
+			return 0, err
+		}
+		apply = func() bool {
+			if size > uint64(t.size) {
+				return false
+			}
+			t.truncate(int(size))
+			return true
+		}
+	default:
+		return 0, errInvalidRecord
+	}
+
+	n := len(data) - len(r)
+	if len(r) < crc32.Size {
+		return 0, errTornRecord
+	}
+	if crc32.Checksum(data[:n], crc32c) != binary.BigEndian.Uint32(r) {
+		return n + crc32.Size, errRecordChecksum
+	}
+
+	if !apply() {
+		return 0, errInvalidRecord
+	}
+
+	return n + crc32.Size, nil
+}
+
+func (l *dynTreeLog) add(leaf []byte) error {
+	rec := []byte{dynTreeRecordAdd}
+	rec = binary.AppendUvarint(rec, uint64(len(leaf)))
+	rec = append(rec, leaf...)
+	return l.write(rec)
+}
+
+func (l *dynTreeLog) update(index int, leaf []byte) error {
+	rec := []byte{dynTreeRecordUpdate}
+	rec = binary.AppendUvarint(rec, uint64(index))
+	rec = binary.AppendUvarint(rec, uint64(len(leaf)))
+	rec = append(rec, leaf...)
+	return l.write(rec)
+}
+
+func (l *dynTreeLog) truncate(size int) error {
+	rec := []byte{dynTreeRecordTruncate}
+	rec = binary.AppendUvarint(rec, uint64(size))
+	return l.write(rec)
+}
+
+// Appends a record followed by its checksum using a single write.
+func (l *dynTreeLog) write(rec []byte) error {
+	rec = binary.BigEndian.AppendUint32(rec, crc32.Checksum(rec, crc32c))
+	n, err := l.file.Write(rec)
+	l.size += int64(n)
+	return err
+}
+
+// Returns the header of a file of a persisted DynTree.
+func dynTreeHeader(magic string, gen uint64) []byte {
+	header := append([]byte(magic), dynTreeFileVersion)
+	return binary.AppendUvarint(header, gen)
+}
+
+// Parses the header of a file of a persisted DynTree and returns its
+// generation and the rest of the file.
+func parseDynTreeHeader(data []byte, magic string) (uint64, []byte, error) {
+	if len(data) < len(magic)+1 || string(data[:len(magic)]) != magic {
+		return 0, nil, ErrInvalidDynTreeFile
+	}
+	if data[len(magic)] != dynTreeFileVersion {
+		return 0, nil, errors.New("unsupported dynamic tree file version")
+	}
+
+	data = data[len(magic)+1:]
+	gen, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, nil, ErrInvalidDynTreeFile
+	}
+
+	return gen, data[n:], nil
+}
+
+// Atomically replaces the log with an empty log of the given generation.
+func createDynTreeLog(dir string, gen uint64) (*os.File, error) {
+	path := filepath.Join(dir, dynTreeLogFile)
+	if err := writeFileAtomic(path, dynTreeHeader(dynTreeLogMagic, gen)); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// Atomically writes a snapshot of the given leaves and returns its size.
+func writeDynTreeSnapshot(dir string, gen uint64, name string, leaves [][]byte) (int64, error) {
+	buf := dynTreeHeader(dynTreeSnapshotMagic, gen)
+	buf = binary.AppendUvarint(buf, uint64(len(name)))
+	buf = append(buf, name...)
+	buf = binary.AppendUvarint(buf, uint64(len(leaves)))
+	for _, leaf := range leaves {
+		buf = binary.AppendUvarint(buf, uint64(len(leaf)))
+		buf = append(buf, leaf...)
+	}
+	buf = binary.BigEndian.AppendUint32(buf, crc32.Checksum(buf, crc32c))
+
+	return int64(len(buf)), writeFileAtomic(filepath.Join(dir, dynTreeSnapshotFile), buf)
+}
+
+// Reads a snapshot and returns its generation, the name of its hasher and its
+// leaves.
+func readDynTreeSnapshot(path string) (uint64, string, [][]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, "", nil, err
+	}
+
+	if len(data) < crc32.Size {
+		return 0, "", nil, ErrInvalidDynTreeFile
+	}
+	body := data[:len(data)-crc32.Size]
+	if crc32.Checksum(body, crc32c) != binary.BigEndian.Uint32(data[len(body):]) {
+		return 0, "", nil, ErrInvalidDynTreeFile
+	}
+
+	gen, body, err := parseDynTreeHeader(body, dynTreeSnapshotMagic)
+	if err != nil {
+		return 0, "", nil, err
+	}
+
+	readBytes := func() ([]byte, bool) {
+		l, n := binary.Uvarint(body)
+		if n <= 0 || l > uint64(len(body)-n) {
+			return nil, false
+		}
+		b := body[n : n+int(l)]
+		body = body[n+int(l):]
+		return b, true
+	}
+
+	name, ok := readBytes()
+	if !ok {
+		return 0, "", nil, ErrInvalidDynTreeFile
+	}
+
+	count, n := binary.Uvarint(body)
+	if n <= 0 || count > uint64(len(body)) {
+		return 0, "", nil, ErrInvalidDynTreeFile
+	}
+	body = body[n:]
+
+	leaves := make([][]byte, count)
+	for i := range leaves {
+		if leaves[i], ok = readBytes(); !ok {
+			return 0, "", nil, ErrInvalidDynTreeFile
+		}
+	}
+	if len(body) > 0 {
+		return 0, "", nil, ErrInvalidDynTreeFile
+	}
+
+	return gen, string(name), leaves, nil
+}
+
+// Writes a file by writing to a temporary file that is synced and renamed.
+// The directory is synced after the rename so that the new file survives a
+// crash of the system.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	return syncDir(filepath.Dir(path))
+}
+
+// Commits the entries of a directory to stable storage.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	err = d.Sync()
+	if closeErr := d.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
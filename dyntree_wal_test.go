@@ -0,0 +1,341 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkle_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stratumn/merkle"
+	"github.com/stratumn/merkle/testutil"
+	"github.com/stratumn/merkle/types"
+)
+
+// Applies random changes to a tree and returns its leaves.
+func randomChanges(tree *merkle.DynTree, leaves [][]byte, n int) [][]byte {
+	for i := 0; i < n; i++ {
+		switch r := rand.Intn(10); {
+		case r == 0 && len(leaves) > 0:
+			index := rand.Intn(len(leaves))
+			leaves[index] = testutil.RandomHash()
+			tree.Update(index, leaves[index])
+		case r == 1 && len(leaves) > 0:
+			size := len(leaves) - rand.Intn(5)
+			if size < 0 {
+				size = 0
+			}
+			tree.Truncate(size)
+			leaves = leaves[:size]
+		default:
+			leaf := testutil.RandomHash()
+			tree.Add(leaf)
+			leaves = append(leaves, leaf)
+		}
+	}
+	return leaves
+}
+
+func TestOpenDynTree(t *testing.T) {
+	for _, h := range hashers {
+		for _, interval := range []int{0, 7, 1000} {
+			dir := t.TempDir()
+			opts := []merkle.Option{merkle.WithHasher(h.hasher), merkle.WithCompactInterval(interval)}
+
+			tree, err := merkle.OpenDynTree(dir, opts...)
+			if err != nil {
+				t.Fatalf("%s: merkle.OpenDynTree(): err: %s", h.name, err)
+			}
+
+			var leaves [][]byte
+			for i := 0; i < 3; i++ {
+				leaves = randomChanges(tree, leaves, 100)
+				root := tree.Root()
+
+				if err := tree.Close(); err != nil {
+					t.Fatalf("%s: tree.Close(): err: %s", h.name, err)
+				}
+
+				if tree, err = merkle.OpenDynTree(dir, opts...); err != nil {
+					t.Fatalf("%s: merkle.OpenDynTree(): err: %s", h.name, err)
+				}
+
+				if got, want := hex.EncodeToString(tree.Root()), hex.EncodeToString(root); got != want {
+					t.Errorf("%s: interval %d: tree.Root() = %q want %q", h.name, interval, got, want)
+				}
+				if len(leaves) > 0 {
+					checkStaticTree(t, h.name, h.hasher, tree, leaves)
+				}
+			}
+
+			if err := tree.Close(); err != nil {
+				t.Fatalf("%s: tree.Close(): err: %s", h.name, err)
+			}
+		}
+	}
+}
+
+func TestOpenDynTree_crash(t *testing.T) {
+	dir := t.TempDir()
+
+	tree, err := merkle.OpenDynTree(dir)
+	if err != nil {
+		t.Fatalf("merkle.OpenDynTree(): err: %s", err)
+	}
+
+	// The tree is not closed to simulate a crash of the process.
+	leaves := randomChanges(tree, nil, 50)
+
+	reopened, err := merkle.OpenDynTree(dir)
+	if err != nil {
+		t.Fatalf("merkle.OpenDynTree(): err: %s", err)
+	}
+	defer reopened.Close()
+
+	checkStaticTree(t, "SHA-256", types.SHA256, reopened, leaves)
+}
+
+func TestOpenDynTree_compact(t *testing.T) {
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, "wal")
+
+	tree, err := merkle.OpenDynTree(dir, merkle.WithCompactInterval(1))
+	if err != nil {
+		t.Fatalf("merkle.OpenDynTree(): err: %s", err)
+	}
+	defer tree.Close()
+
+	// The log is only compacted once it is larger than the snapshot, so the
+	// number of compactions grows logarithmically with the number of leaves.
+	var size int64
+	compactions := 0
+	for i := 0; i < 1000; i++ {
+		tree.Add(testutil.RandomHash())
+		info, err := os.Stat(walPath)
+		if err != nil {
+			t.Fatalf("os.Stat(): err: %s", err)
+		}
+		if info.Size() < size {
+			compactions++
+		}
+		size = info.Size()
+	}
+	if err := tree.Err(); err != nil {
+		t.Fatalf("tree.Err(): err: %s", err)
+	}
+
+	if compactions == 0 || compactions > 20 {
+		t.Errorf("compactions = %d want between 1 and 20", compactions)
+	}
+}
+
+func TestOpenDynTree_tornWrite(t *testing.T) {
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, "wal")
+
+	tree, err := merkle.OpenDynTree(dir, merkle.WithCompactInterval(0))
+	if err != nil {
+		t.Fatalf("merkle.OpenDynTree(): err: %s", err)
+	}
+
+	leaves := make([][]byte, 20)
+	for i := range leaves {
+		leaves[i] = testutil.RandomHash()
+		tree.Add(leaves[i])
+	}
+	if err := tree.Close(); err != nil {
+		t.Fatalf("tree.Close(): err: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(walPath)
+	if err != nil {
+		t.Fatalf("ioutil.ReadFile(): err: %s", err)
+	}
+
+	// Each record of an addition is 1 + 1 + 32 + 4 bytes long.
+	const recordLen = 38
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected int
+	}{
+		{"complete", data, 20},
+		{"checksum", data[:len(data)-1], 19},
+		{"hash", data[:len(data)-10], 19},
+		{"type", data[:len(data)-recordLen+1], 19},
+		{"record", data[:len(data)-recordLen], 19},
+		{"records", data[:len(data)-3*recordLen-5], 16},
+		{"garbage", append(append([]byte(nil), data...), 0x01, 0x20, 0xff), 20},
+		{"corrupted", append(append([]byte(nil), data[:len(data)-1]...), data[len(data)-1]^0xff), 19},
+	}
+
+	for _, test := range tests {
+		if err := ioutil.WriteFile(walPath, test.data, 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile(): err: %s", err)
+		}
+
+		tree, err := merkle.OpenDynTree(dir)
+		if err != nil {
+			t.Fatalf("%s: merkle.OpenDynTree(): err: %s", test.name, err)
+		}
+		checkStaticTree(t, test.name, types.SHA256, tree, leaves[:test.expected])
+
+		// The torn record must be discarded so that new changes are not
+		// lost.
+		leaf := testutil.RandomHash()
+		tree.Add(leaf)
+		if err := tree.Close(); err != nil {
+			t.Fatalf("%s: tree.Close(): err: %s", test.name, err)
+		}
+
+		tree, err = merkle.OpenDynTree(dir)
+		if err != nil {
+			t.Fatalf("%s: merkle.OpenDynTree(): err: %s", test.name, err)
+		}
+		checkStaticTree(t, test.name, types.SHA256, tree, append(append([][]byte(nil), leaves[:test.expected]...), leaf))
+		if err := tree.Close(); err != nil {
+			t.Fatalf("%s: tree.Close(): err: %s", test.name, err)
+		}
+	}
+}
+
+func TestOpenDynTree_corruptedRecord(t *testing.T) {
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, "wal")
+
+	tree, err := merkle.OpenDynTree(dir, merkle.WithCompactInterval(0))
+	if err != nil {
+		t.Fatalf("merkle.OpenDynTree(): err: %s", err)
+	}
+	for i := 0; i < 20; i++ {
+		tree.Add(testutil.RandomHash())
+	}
+	if err := tree.Close(); err != nil {
+		t.Fatalf("tree.Close(): err: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(walPath)
+	if err != nil {
+		t.Fatalf("ioutil.ReadFile(): err: %s", err)
+	}
+
+	// Each record of an addition is 1 + 1 + 32 + 4 bytes long.
+	const recordLen = 38
+
+	// Records that are followed by other records were fully written, so they
+	// cannot be discarded.
+	for _, offset := range []int{
+		len(data) - 2*recordLen + 10,  // hash of the second to last record
+		len(data) - 15*recordLen + 10, // hash of a record in the middle
+		len(data) - 3*recordLen,       // type of a record
+		len(data) - recordLen - 1,     // checksum of the second to last record
+	} {
+		corrupted := append([]byte(nil), data...)
+		corrupted[offset] ^= 0xff
+		if err := ioutil.WriteFile(walPath, corrupted, 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile(): err: %s", err)
+		}
+
+		if _, err := merkle.OpenDynTree(dir); !errors.Is(err, merkle.ErrInvalidDynTreeFile) {
+			t.Errorf("%d: merkle.OpenDynTree(): err = %v want %v", offset, err, merkle.ErrInvalidDynTreeFile)
+		}
+
+		got, err := ioutil.ReadFile(walPath)
+		if err != nil {
+			t.Fatalf("ioutil.ReadFile(): err: %s", err)
+		}
+		if !bytes.Equal(got, corrupted) {
+			t.Errorf("%d: the log was modified", offset)
+		}
+	}
+}
+
+func TestOpenDynTree_staleLog(t *testing.T) {
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, "wal")
+
+	tree, err := merkle.OpenDynTree(dir, merkle.WithCompactInterval(0))
+	if err != nil {
+		t.Fatalf("merkle.OpenDynTree(): err: %s", err)
+	}
+	leaves := randomChanges(tree, nil, 30)
+
+	stale, err := ioutil.ReadFile(walPath)
+	if err != nil {
+		t.Fatalf("ioutil.ReadFile(): err: %s", err)
+	}
+
+	if err := tree.Compact(); err != nil {
+		t.Fatalf("tree.Compact(): err: %s", err)
+	}
+	if err := tree.Close(); err != nil {
+		t.Fatalf("tree.Close(): err: %s", err)
+	}
+
+	// Simulate a crash after the snapshot was written but before the log was
+	// reset.
+	if err := ioutil.WriteFile(walPath, stale, 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile(): err: %s", err)
+	}
+
+	tree, err = merkle.OpenDynTree(dir)
+	if err != nil {
+		t.Fatalf("merkle.OpenDynTree(): err: %s", err)
+	}
+	defer tree.Close()
+
+	checkStaticTree(t, "SHA-256", types.SHA256, tree, leaves)
+}
+
+func TestOpenDynTree_Error(t *testing.T) {
+	dir := t.TempDir()
+
+	tree, err := merkle.OpenDynTree(dir)
+	if err != nil {
+		t.Fatalf("merkle.OpenDynTree(): err: %s", err)
+	}
+	tree.Add(testutil.RandomHash())
+	if err := tree.Close(); err != nil {
+		t.Fatalf("tree.Close(): err: %s", err)
+	}
+
+	if _, err := merkle.OpenDynTree(dir, merkle.WithHasher(types.SHA3_256)); err == nil {
+		t.Error("merkle.OpenDynTree(SHA3_256): err = nil want Error")
+	}
+
+	if _, err := merkle.OpenDynTree(t.TempDir(), merkle.WithHash(sha256.New)); err == nil {
+		t.Error("merkle.OpenDynTree(unregistered): err = nil want Error")
+	}
+
+	snapshotPath := filepath.Join(dir, "snapshot")
+	data, err := ioutil.ReadFile(snapshotPath)
+	if err != nil {
+		t.Fatalf("ioutil.ReadFile(): err: %s", err)
+	}
+	data[len(data)-5] ^= 0xff
+	if err := ioutil.WriteFile(snapshotPath, data, 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile(): err: %s", err)
+	}
+	if _, err := merkle.OpenDynTree(dir); err != merkle.ErrInvalidDynTreeFile {
+		t.Errorf("merkle.OpenDynTree(): err = %v want %v", err, merkle.ErrInvalidDynTreeFile)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package httpapi contains the plumbing shared by the HTTP handlers of the
+// repository: routing, JSON responses and the status codes of errors.
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/stratumn/merkle/types"
+)
+
+// Route is an endpoint of a Router. Handle returns the status code and the
+// body of a successful response.
+type Route struct {
+	Method string
+	Handle func(r *http.Request) (int, interface{}, error)
+}
+
+// Router dispatches requests to routes by path. Bodies are written in JSON,
+// and errors as an ErrorResponse with the status code given by Status.
+type Router map[string]Route
+
+// ErrorResponse is the body of the response to a failed request.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// ServeHTTP implements net/http.Handler.ServeHTTP.
+func (rt Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	route, ok := rt[r.URL.Path]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "unknown endpoint"})
+		return
+	}
+	if r.Method != route.Method {
+		w.Header().Set("Allow", route.Method)
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+
+	status, body, err := route.Handle(r)
+	if err != nil {
+		writeJSON(w, Status(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, status, body)
+}
+
+// statusError is an error with the status code of the response.
+type statusError struct {
+	status int
+	err    error
+}
+
+// BadRequest wraps an error caused by a malformed request, which is
+// reported with status 400.
+func BadRequest(err error) error {
+	return &statusError{status: http.StatusBadRequest, err: err}
+}
+
+// NotFound wraps an error caused by a missing resource, which is reported
+// with status 404.
+func NotFound(err error) error {
+	return &statusError{status: http.StatusNotFound, err: err}
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
+// Status returns the status code of the response to a failed request: the
+// status of errors wrapped by BadRequest and NotFound, 413 for bodies larger
+// than the limit of http.MaxBytesReader, 400 for types.ErrSizeOutOfRange, 404
+// for types.ErrIndexOutOfRange and 500 otherwise.
+func Status(err error) int {
+	var (
+		statusErr *statusError
+		sizeErr   *http.MaxBytesError
+	)
+
+	switch {
+	case errors.As(err, &sizeErr):
+		return http.StatusRequestEntityTooLarge
+	case errors.As(err, &statusErr):
+		return statusErr.status
+	case errors.Is(err, types.ErrSizeOutOfRange):
+		return http.StatusBadRequest
+	case errors.Is(err, types.ErrIndexOutOfRange):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	// The status has been sent, so an error cannot be reported.
+	json.NewEncoder(w).Encode(body)
+}
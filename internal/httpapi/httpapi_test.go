@@ -0,0 +1,92 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpapi_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stratumn/merkle/internal/httpapi"
+	"github.com/stratumn/merkle/types"
+)
+
+func TestStatus(t *testing.T) {
+	errTest := errors.New("test")
+
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{httpapi.BadRequest(errTest), http.StatusBadRequest},
+		{httpapi.NotFound(errTest), http.StatusNotFound},
+		{fmt.Errorf("wrapped: %w", httpapi.NotFound(errTest)), http.StatusNotFound},
+		{&http.MaxBytesError{Limit: 1}, http.StatusRequestEntityTooLarge},
+		{httpapi.BadRequest(&http.MaxBytesError{Limit: 1}), http.StatusRequestEntityTooLarge},
+		{types.ErrSizeOutOfRange, http.StatusBadRequest},
+		{types.ErrIndexOutOfRange, http.StatusNotFound},
+		{errTest, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		if got := httpapi.Status(tt.err); got != tt.want {
+			t.Errorf("httpapi.Status(%v) = %d want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRouter(t *testing.T) {
+	router := httpapi.Router{
+		"/ok": {Method: http.MethodGet, Handle: func(r *http.Request) (int, interface{}, error) {
+			return http.StatusCreated, map[string]int{"n": 1}, nil
+		}},
+		"/fail": {Method: http.MethodGet, Handle: func(r *http.Request) (int, interface{}, error) {
+			return 0, nil, httpapi.NotFound(errors.New("missing"))
+		}},
+	}
+
+	tests := []struct {
+		method, path string
+		status       int
+		body         string
+	}{
+		{http.MethodGet, "/ok", http.StatusCreated, `{"n":1}`},
+		{http.MethodGet, "/fail", http.StatusNotFound, `{"error":"missing"}`},
+		{http.MethodGet, "/unknown", http.StatusNotFound, `{"error":"unknown endpoint"}`},
+		{http.MethodPost, "/ok", http.StatusMethodNotAllowed, `{"error":"method not allowed"}`},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if got := w.Code; got != tt.status {
+			t.Errorf("%s %s: status = %d want %d", tt.method, tt.path, got, tt.status)
+		}
+		if got, want := w.Header().Get("Content-Type"), "application/json"; got != want {
+			t.Errorf("%s %s: Content-Type = %q want %q", tt.method, tt.path, got, want)
+		}
+
+		var got, want interface{}
+		json.Unmarshal(w.Body.Bytes(), &got)
+		json.Unmarshal([]byte(tt.body), &want)
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s %s: body = %s want %s", tt.method, tt.path, w.Body, tt.body)
+		}
+	}
+}
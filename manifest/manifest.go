@@ -0,0 +1,401 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package manifest seals directories with Merkle trees.
+//
+// A manifest lists the files of a directory with the hash of each file and a
+// proof that the hash is included in the tree of all the files, so that a
+// single root identifies the content of the directory and each file can be
+// checked on its own.
+package manifest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/stratumn/merkle"
+	"github.com/stratumn/merkle/types"
+)
+
+// Version is the version of the manifest format.
+const Version = 1
+
+var (
+	// ErrInvalidManifest is returned when the files of a manifest do not
+	// match its root.
+	ErrInvalidManifest = errors.New("manifest does not match its root")
+
+	// ErrNoFiles is returned when sealing a directory without files.
+	ErrNoFiles = errors.New("directory has no files")
+)
+
+// Manifest lists the files of a directory sorted by path.
+type Manifest struct {
+	// Hash is the name of the hasher of the tree, as registered in the
+	// types package. The hasher must implement types.DataHasher, whose hash
+	// function hashes the files.
+	Hash string
+
+	Root  []byte
+	Files []File
+}
+
+// File is a file of a manifest.
+type File struct {
+	// Path is the slash separated path of the file relative to the
+	// directory.
+	Path string
+	Size int64
+
+	// Hash is the hash of the length of the path as a big-endian 64-bit
+	// integer, the path and the content of the file. It is the leaf of the
+	// file in the tree.
+	Hash []byte
+
+	Proof *types.InclusionProof
+}
+
+// Option configures how a directory is scanned.
+type Option func(*options)
+
+type options struct {
+	hash    string
+	exclude map[string]bool
+}
+
+// WithHash sets the name of the hasher used to seal a directory. The default
+// is sha256. It is ignored when checking a directory, which uses the hasher
+// of the manifest.
+func WithHash(name string) Option {
+	return func(o *options) {
+		o.hash = name
+	}
+}
+
+// WithExclude excludes the files with the given slash separated paths,
+// relative to the directory.
+func WithExclude(paths ...string) Option {
+	return func(o *options) {
+		for _, path := range paths {
+			o.exclude[path] = true
+		}
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{hash: types.NameSHA256, exclude: map[string]bool{}}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Build seals a directory. The files are walked recursively without
+// following symbolic links, which are not supported.
+func Build(dir string, opts ...Option) (*Manifest, error) {
+	o := newOptions(opts)
+
+	hasher, newHash, err := hashByName(o.hash)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := scan(dir, newHash, o.exclude)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) < 1 {
+		return nil, ErrNoFiles
+	}
+
+	tree, err := merkle.NewStaticTree(fileHashes(files), merkle.WithHasher(hasher))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range files {
+		if files[i].Proof, err = types.NewInclusionProof(i, len(files), tree.Path(i)); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Manifest{Hash: o.hash, Root: tree.Root(), Files: files}, nil
+}
+
+// Verify checks that the root of the manifest is the root of the tree of its
+// files and that the proof of each file is valid.
+func (m *Manifest) Verify() error {
+	hasher, _, err := hashByName(m.Hash)
+	if err != nil {
+		return err
+	}
+	if len(m.Files) < 1 {
+		return ErrNoFiles
+	}
+
+	for i, f := range m.Files {
+		if i > 0 && f.Path <= m.Files[i-1].Path {
+			return fmt.Errorf("%w: files are not sorted", ErrInvalidManifest)
+		}
+	}
+
+	tree, err := merkle.NewStaticTree(fileHashes(m.Files), merkle.WithHasher(hasher))
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(tree.Root(), m.Root) {
+		return ErrInvalidManifest
+	}
+
+	for i, f := range m.Files {
+		if err := f.Verify(hasher, m.Root); err != nil {
+			return fmt.Errorf("%s: %w", f.Path, err)
+		}
+		if f.Proof.Index != i || f.Proof.TreeSize != len(m.Files) {
+			return fmt.Errorf("%s: %w", f.Path, types.ErrIndexMismatch)
+		}
+	}
+
+	return nil
+}
+
+// Verify checks that the proof of the file proves that its hash is included
+// in the tree with the given root.
+func (f *File) Verify(hasher types.Hasher, root []byte) error {
+	if f.Proof == nil {
+		return errors.New("file has no proof")
+	}
+	return f.Proof.VerifyWith(hasher, hasher.HashLeaf(f.Hash), root)
+}
+
+// Report lists the differences between a directory and a manifest. The paths
+// are sorted.
+type Report struct {
+	Added    []string
+	Removed  []string
+	Modified []string
+}
+
+// OK returns whether the directory matches the manifest.
+func (r *Report) OK() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 && len(r.Modified) == 0
+}
+
+// Check scans a directory again and compares it to a manifest, which is
+// verified first.
+func Check(dir string, m *Manifest, opts ...Option) (*Report, error) {
+	if err := m.Verify(); err != nil {
+		return nil, err
+	}
+
+	o := newOptions(opts)
+
+	_, newHash, err := hashByName(m.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := scan(dir, newHash, o.exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	// Both lists are sorted by path.
+	var (
+		r      = &Report{}
+		i, j   int
+		sealed = m.Files
+	)
+
+	for i < len(sealed) || j < len(files) {
+		switch {
+		case j == len(files) || i < len(sealed) && sealed[i].Path < files[j].Path:
+			r.Removed = append(r.Removed, sealed[i].Path)
+			i++
+		case i == len(sealed) || files[j].Path < sealed[i].Path:
+			r.Added = append(r.Added, files[j].Path)
+			j++
+		default:
+			if !bytes.Equal(sealed[i].Hash, files[j].Hash) {
+				r.Modified = append(r.Modified, files[j].Path)
+			}
+			i++
+			j++
+		}
+	}
+
+	return r, nil
+}
+
+// Returns the hasher registered under the given name and its hash function,
+// which is used to hash the files.
+func hashByName(name string) (types.Hasher, func() hash.Hash, error) {
+	hasher, err := types.HasherByName(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dh, ok := hasher.(types.DataHasher)
+	if !ok {
+		return nil, nil, fmt.Errorf("cannot hash files with %q", name)
+	}
+
+	return hasher, dh.HashFunc(), nil
+}
+
+// Returns the regular files of a directory sorted by path, with their hashes.
+func scan(dir string, newHash func() hash.Hash, exclude map[string]bool) ([]File, error) {
+	var files []File
+
+	err := filepath.WalkDir(dir, func(filename string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, filename)
+		if err != nil {
+			return err
+		}
+		path := filepath.ToSlash(rel)
+
+		if exclude[path] {
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return fmt.Errorf("%s: unsupported file type %s", path, d.Type())
+		}
+
+		f, err := hashFile(filename, path, newHash)
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The walk is lexical within each directory, which is not the order of
+	// the full paths.
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	return files, nil
+}
+
+func hashFile(filename, path string, newHash func() hash.Hash) (File, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return File{}, err
+	}
+	defer file.Close()
+
+	h := newHash()
+
+	var size [8]byte
+	binary.BigEndian.PutUint64(size[:], uint64(len(path)))
+	h.Write(size[:])
+	h.Write([]byte(path))
+
+	n, err := io.Copy(h, file)
+	if err != nil {
+		return File{}, err
+	}
+
+	return File{Path: path, Size: n, Hash: h.Sum(nil)}, nil
+}
+
+func fileHashes(files []File) [][]byte {
+	hashes := make([][]byte, len(files))
+	for i, f := range files {
+		hashes[i] = f.Hash
+	}
+	return hashes
+}
+
+// JSONManifest is used to Marshal/Unmarshal Manifest type with hex
+// representation.
+type JSONManifest struct {
+	Version int        `json:"version"`
+	Hash    string     `json:"hash"`
+	Root    string     `json:"root"`
+	Files   []JSONFile `json:"files"`
+}
+
+// JSONFile is used to Marshal/Unmarshal File type with hex representation.
+type JSONFile struct {
+	Path  string                `json:"path"`
+	Size  int64                 `json:"size"`
+	Hash  string                `json:"hash"`
+	Proof *types.InclusionProof `json:"proof"`
+}
+
+// MarshalJSON implements encoding/json.Marshaler.MarshalJSON.
+func (m *Manifest) MarshalJSON() ([]byte, error) {
+	j := JSONManifest{
+		Version: Version,
+		Hash:    m.Hash,
+		Root:    hex.EncodeToString(m.Root),
+		Files:   make([]JSONFile, len(m.Files)),
+	}
+	for i, f := range m.Files {
+		j.Files[i] = JSONFile{Path: f.Path, Size: f.Size, Hash: hex.EncodeToString(f.Hash), Proof: f.Proof}
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON implements encoding/json.Unmarshaler.UnmarshalJSON.
+func (m *Manifest) UnmarshalJSON(data []byte) error {
+	var j JSONManifest
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.Version != Version {
+		return fmt.Errorf("unsupported manifest version %d", j.Version)
+	}
+
+	root, err := hex.DecodeString(j.Root)
+	if err != nil {
+		return err
+	}
+
+	files := make([]File, len(j.Files))
+	for i, f := range j.Files {
+		h, err := hex.DecodeString(f.Hash)
+		if err != nil {
+			return err
+		}
+		files[i] = File{Path: f.Path, Size: f.Size, Hash: h, Proof: f.Proof}
+	}
+
+	m.Hash = j.Hash
+	m.Root = root
+	m.Files = files
+
+	return nil
+}
@@ -0,0 +1,295 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifest_test
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stratumn/merkle/manifest"
+	"github.com/stratumn/merkle/types"
+)
+
+var hashNames = []string{
+	types.NameSHA256,
+	types.NameSHA512_256,
+	types.NameSHA3_256,
+	types.NameSHA1,
+	types.NameRFC6962SHA256,
+}
+
+// writeTestDir creates a directory with the given files and contents.
+func writeTestDir(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for path, content := range files {
+		writeTestFile(t, dir, path, content)
+	}
+	return dir
+}
+
+func writeTestFile(t *testing.T, dir, path, content string) {
+	filename := filepath.Join(dir, filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		t.Fatalf("os.MkdirAll(): err: %s", err)
+	}
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatalf("os.WriteFile(): err: %s", err)
+	}
+}
+
+var testFiles = map[string]string{
+	"a.txt":     "a",
+	"a/b.txt":   "b",
+	"a/c/d.txt": "d",
+	"b":         "",
+	"z.txt":     "z",
+}
+
+func TestBuild(t *testing.T) {
+	dir := writeTestDir(t, testFiles)
+
+	for _, name := range hashNames {
+		m, err := manifest.Build(dir, manifest.WithHash(name))
+		if err != nil {
+			t.Fatalf("%s: manifest.Build(): err: %s", name, err)
+		}
+
+		var paths []string
+		for _, f := range m.Files {
+			paths = append(paths, f.Path)
+		}
+		if got, want := paths, []string{"a.txt", "a/b.txt", "a/c/d.txt", "b", "z.txt"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: paths = %q want %q", name, got, want)
+		}
+		if got, want := m.Files[1].Size, int64(1); got != want {
+			t.Errorf("%s: size = %d want %d", name, got, want)
+		}
+
+		if err := m.Verify(); err != nil {
+			t.Errorf("%s: m.Verify(): err: %s", name, err)
+		}
+	}
+}
+
+func TestBuild_deterministic(t *testing.T) {
+	m1, err := manifest.Build(writeTestDir(t, testFiles))
+	if err != nil {
+		t.Fatalf("manifest.Build(): err: %s", err)
+	}
+	m2, err := manifest.Build(writeTestDir(t, testFiles))
+	if err != nil {
+		t.Fatalf("manifest.Build(): err: %s", err)
+	}
+	if !reflect.DeepEqual(m1, m2) {
+		t.Errorf("manifests of identical directories differ")
+	}
+
+	// The path is part of the hash of a file.
+	renamed := map[string]string{}
+	for path, content := range testFiles {
+		renamed[path] = content
+	}
+	delete(renamed, "z.txt")
+	renamed["y.txt"] = "z"
+
+	m3, err := manifest.Build(writeTestDir(t, renamed))
+	if err != nil {
+		t.Fatalf("manifest.Build(): err: %s", err)
+	}
+	if reflect.DeepEqual(m1.Root, m3.Root) {
+		t.Errorf("renaming a file did not change the root")
+	}
+}
+
+func TestBuild_registeredHasher(t *testing.T) {
+	if err := types.RegisterHasher("test_sha224", types.NewHasher(sha256.New224)); err != nil {
+		t.Fatalf("types.RegisterHasher(): err: %s", err)
+	}
+
+	m, err := manifest.Build(writeTestDir(t, testFiles), manifest.WithHash("test_sha224"))
+	if err != nil {
+		t.Fatalf("manifest.Build(): err: %s", err)
+	}
+	if got, want := len(m.Files[0].Hash), sha256.Size224; got != want {
+		t.Errorf("len(m.Files[0].Hash) = %d want %d", got, want)
+	}
+	if err := m.Verify(); err != nil {
+		t.Errorf("m.Verify(): err: %s", err)
+	}
+}
+
+func TestBuild_exclude(t *testing.T) {
+	m, err := manifest.Build(writeTestDir(t, testFiles), manifest.WithExclude("a/b.txt", "z.txt"))
+	if err != nil {
+		t.Fatalf("manifest.Build(): err: %s", err)
+	}
+	if got, want := len(m.Files), 3; got != want {
+		t.Errorf("len(m.Files) = %d want %d", got, want)
+	}
+}
+
+func TestBuild_errors(t *testing.T) {
+	if _, err := manifest.Build(t.TempDir()); err != manifest.ErrNoFiles {
+		t.Errorf("empty: err = %v want %v", err, manifest.ErrNoFiles)
+	}
+	if _, err := manifest.Build(writeTestDir(t, testFiles), manifest.WithHash("md5")); err == nil {
+		t.Errorf("unknown hash: err = nil want error")
+	}
+
+	dir := writeTestDir(t, testFiles)
+	if err := os.Symlink("a.txt", filepath.Join(dir, "link")); err != nil {
+		t.Skipf("os.Symlink(): err: %s", err)
+	}
+	if _, err := manifest.Build(dir); err == nil {
+		t.Errorf("symlink: err = nil want error")
+	}
+}
+
+func TestManifest_JSON(t *testing.T) {
+	m, err := manifest.Build(writeTestDir(t, testFiles), manifest.WithHash(types.NameRFC6962SHA256))
+	if err != nil {
+		t.Fatalf("manifest.Build(): err: %s", err)
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("json.Marshal(): err: %s", err)
+	}
+
+	var got manifest.Manifest
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("json.Unmarshal(): err: %s", err)
+	}
+	if !reflect.DeepEqual(&got, m) {
+		t.Errorf("json.Unmarshal() = %#v want %#v", got, m)
+	}
+	if err := got.Verify(); err != nil {
+		t.Errorf("got.Verify(): err: %s", err)
+	}
+
+	if err := json.Unmarshal([]byte(`{"version":2}`), &got); err == nil {
+		t.Errorf("json.Unmarshal(): version 2: err = nil want error")
+	}
+}
+
+func TestManifest_Verify(t *testing.T) {
+	dir := writeTestDir(t, testFiles)
+
+	tests := []struct {
+		name   string
+		mutate func(m *manifest.Manifest)
+		want   error
+	}{
+		{"root", func(m *manifest.Manifest) { m.Root[0] ^= 1 }, manifest.ErrInvalidManifest},
+		{"hash", func(m *manifest.Manifest) { m.Files[2].Hash[0] ^= 1 }, manifest.ErrInvalidManifest},
+		{"sibling", func(m *manifest.Manifest) { m.Files[2].Proof.Siblings[0][0] ^= 1 }, types.ErrRootMismatch},
+		{"index", func(m *manifest.Manifest) { m.Files[0].Proof = m.Files[1].Proof }, types.ErrRootMismatch},
+		{"order", func(m *manifest.Manifest) { m.Files[0], m.Files[1] = m.Files[1], m.Files[0] }, manifest.ErrInvalidManifest},
+	}
+
+	for _, tt := range tests {
+		m, err := manifest.Build(dir)
+		if err != nil {
+			t.Fatalf("manifest.Build(): err: %s", err)
+		}
+		tt.mutate(m)
+		if err := m.Verify(); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestFile_Verify(t *testing.T) {
+	m, err := manifest.Build(writeTestDir(t, testFiles), manifest.WithHash(types.NameRFC6962SHA256))
+	if err != nil {
+		t.Fatalf("manifest.Build(): err: %s", err)
+	}
+
+	for _, f := range m.Files {
+		if err := f.Verify(types.RFC6962SHA256, m.Root); err != nil {
+			t.Errorf("%s: f.Verify(): err: %s", f.Path, err)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := writeTestDir(t, testFiles)
+
+	m, err := manifest.Build(dir)
+	if err != nil {
+		t.Fatalf("manifest.Build(): err: %s", err)
+	}
+
+	r, err := manifest.Check(dir, m)
+	if err != nil {
+		t.Fatalf("manifest.Check(): err: %s", err)
+	}
+	if !r.OK() {
+		t.Errorf("unchanged: r = %#v want OK", r)
+	}
+
+	writeTestFile(t, dir, "a/b.txt", "B")
+	writeTestFile(t, dir, "a/c/e.txt", "e")
+	writeTestFile(t, dir, "zz.txt", "zz")
+	if err := os.Remove(filepath.Join(dir, "a.txt")); err != nil {
+		t.Fatalf("os.Remove(): err: %s", err)
+	}
+	if err := os.Remove(filepath.Join(dir, "z.txt")); err != nil {
+		t.Fatalf("os.Remove(): err: %s", err)
+	}
+
+	r, err = manifest.Check(dir, m)
+	if err != nil {
+		t.Fatalf("manifest.Check(): err: %s", err)
+	}
+	want := &manifest.Report{
+		Added:    []string{"a/c/e.txt", "zz.txt"},
+		Removed:  []string{"a.txt", "z.txt"},
+		Modified: []string{"a/b.txt"},
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("manifest.Check() = %#v want %#v", r, want)
+	}
+	if r.OK() {
+		t.Errorf("r.OK() = true want false")
+	}
+
+	r, err = manifest.Check(dir, m, manifest.WithExclude("a/c/e.txt", "zz.txt"))
+	if err != nil {
+		t.Fatalf("manifest.Check(): err: %s", err)
+	}
+	if r.Added != nil {
+		t.Errorf("excluded: r.Added = %q want nil", r.Added)
+	}
+}
+
+func TestCheck_invalidManifest(t *testing.T) {
+	dir := writeTestDir(t, testFiles)
+
+	m, err := manifest.Build(dir)
+	if err != nil {
+		t.Fatalf("manifest.Build(): err: %s", err)
+	}
+	m.Files[0].Hash[0] ^= 1
+
+	if _, err := manifest.Check(dir, m); err != manifest.ErrInvalidManifest {
+		t.Errorf("err = %v want %v", err, manifest.ErrInvalidManifest)
+	}
+}
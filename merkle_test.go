@@ -23,6 +23,7 @@ import (
 
 	"github.com/stratumn/merkle"
 	"github.com/stratumn/merkle/testutil"
+	"github.com/stratumn/merkle/types"
 )
 
 func TestTreeConsistency(t *testing.T) {
@@ -58,3 +59,84 @@ func TestTreeConsistency(t *testing.T) {
 		}
 	}
 }
+
+func TestVerifyInclusion(t *testing.T) {
+	for _, h := range hashers {
+		for i := 0; i < 10; i++ {
+			tests := make([][]byte, 1+rand.Intn(1000))
+			for j := range tests {
+				tests[j] = testutil.RandomHash()
+			}
+
+			static, err := merkle.NewStaticTree(tests, merkle.WithHasher(h.hasher))
+			if err != nil {
+				t.Fatalf("merkle.NewStaticTree(): err: %s", err)
+			}
+
+			dyn := merkle.NewDynTree(len(tests), merkle.WithHasher(h.hasher))
+			for _, leaf := range tests {
+				dyn.Add(leaf)
+			}
+
+			for j := range tests {
+				for _, tree := range []merkle.Tree{static, dyn} {
+					err := types.VerifyInclusionWith(h.hasher, tree.Leaf(j), tree.Root(), j, len(tests), tree.Path(j))
+					if err != nil {
+						t.Errorf("%s: types.VerifyInclusionWith(%d, %d): err: %s", h.name, j, len(tests), err)
+					}
+				}
+			}
+		}
+	}
+}
+
+// Test vectors from the Certificate Transparency reference implementation.
+var rfc6962Leaves = [][]byte{
+	{},
+	{0x00},
+	{0x10},
+	{0x20, 0x21},
+	{0x30, 0x31},
+	{0x40, 0x41, 0x42, 0x43},
+	{0x50, 0x51, 0x52, 0x53, 0x54, 0x55, 0x56, 0x57},
+	{0x60, 0x61, 0x62, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69, 0x6a, 0x6b, 0x6c, 0x6d, 0x6e, 0x6f},
+}
+
+var rfc6962Roots = []string{
+	"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+	"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+	"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+	"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+	"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+	"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+	"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+	"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+}
+
+func TestRFC6962Roots(t *testing.T) {
+	for i, want := range rfc6962Roots {
+		leaves := rfc6962Leaves[:i+1]
+
+		static, err := merkle.NewStaticTree(leaves, merkle.WithHasher(types.RFC6962SHA256))
+		if err != nil {
+			t.Fatalf("merkle.NewStaticTree(): err: %s", err)
+		}
+		if got := hex.EncodeToString(static.Root()); got != want {
+			t.Errorf("test#%d: static.Root() = %q want %q", i, got, want)
+		}
+
+		dyn := merkle.NewDynTree(len(leaves), merkle.WithHasher(types.RFC6962SHA256))
+		for _, leaf := range leaves {
+			dyn.Add(leaf)
+		}
+		if got := hex.EncodeToString(dyn.Root()); got != want {
+			t.Errorf("test#%d: dyn.Root() = %q want %q", i, got, want)
+		}
+
+		for j := range leaves {
+			if err := static.Path(j).ValidateWith(types.RFC6962SHA256); err != nil {
+				t.Errorf("test#%d: static.Path(%d).ValidateWith(): err: %s", i, j, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,320 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package merklehttp exposes a DynTree over HTTP.
+//
+// The handler serves the following endpoints, relative to where it is
+// mounted, with JSON bodies using hex encoded hashes:
+//
+//	POST /leaves                        appends the leaf {"leaf": hex}
+//	GET  /root                          returns the size and the root
+//	GET  /proof?index=i                 returns the inclusion proof of a leaf
+//	GET  /proof?hash=hex                same, given the hash of the leaf
+//	GET  /consistency?first=m&second=n  returns a consistency proof
+//
+// The second size of a consistency proof defaults to the size of the tree.
+//
+// Errors are returned as {"error": message} with a status code: 400 for
+// malformed requests and invalid tree sizes, 404 for indices out of range and
+// unknown leaf hashes, 405 for unsupported methods, 413 for bodies larger than
+// MaxBodySize and 500 otherwise.
+package merklehttp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/stratumn/merkle"
+	"github.com/stratumn/merkle/internal/httpapi"
+	"github.com/stratumn/merkle/types"
+)
+
+// MaxBodySize is the maximum size of the body of a request.
+const MaxBodySize = 1 << 20
+
+// ErrLeafNotFound is returned when no leaf has the requested hash.
+var ErrLeafNotFound = errors.New("leaf not found")
+
+// AddLeafRequest is the body of a request to append a leaf.
+type AddLeafRequest struct {
+	// Leaf is the hex encoded data of the leaf, which is hashed with the
+	// hasher of the tree.
+	Leaf string `json:"leaf"`
+}
+
+// AddLeafResponse is the body of the response to a request to append a leaf.
+type AddLeafResponse struct {
+	Index    int    `json:"index"`
+	LeafHash string `json:"leafHash"`
+}
+
+// RootResponse is the body of the response to a request for the root. The
+// root is empty if the tree has no leaves.
+type RootResponse struct {
+	TreeSize int    `json:"treeSize"`
+	Root     string `json:"root,omitempty"`
+}
+
+// ProofResponse is the body of the response to a request for an inclusion
+// proof. The proof is for the tree with the given root.
+type ProofResponse struct {
+	LeafHash string                `json:"leafHash"`
+	Root     string                `json:"root"`
+	Proof    *types.InclusionProof `json:"proof"`
+}
+
+// ConsistencyResponse is the body of the response to a request for a
+// consistency proof. The root of an empty tree is empty, and so is the proof
+// when the first size is zero.
+type ConsistencyResponse struct {
+	First      int      `json:"first"`
+	Second     int      `json:"second"`
+	FirstRoot  string   `json:"firstRoot,omitempty"`
+	SecondRoot string   `json:"secondRoot,omitempty"`
+	Proof      []string `json:"proof"`
+}
+
+// ErrorResponse is the body of the response to a failed request.
+type ErrorResponse = httpapi.ErrorResponse
+
+// Handler serves a DynTree over HTTP. The tree can be modified outside of
+// the handler.
+//
+// Leaves are found by hash using an index of the leaves that is extended as
+// the tree grows, so a leaf that was changed with Update may not be found by
+// its new hash. The index is only built once a proof is requested by hash. It
+// is kept in memory and is not bounded: with 32-byte leaves, it takes about
+// 100 bytes per distinct leaf, so about 1 GB for ten million leaves.
+type Handler struct {
+	tree   *merkle.DynTree
+	routes httpapi.Router
+
+	// indices maps the leaves to their first index, for the first indexed
+	// leaves of the tree.
+	mutex   sync.Mutex
+	indices map[string]int
+	indexed int
+}
+
+// NewHandler creates a handler for a tree.
+func NewHandler(tree *merkle.DynTree) *Handler {
+	h := &Handler{tree: tree, indices: map[string]int{}}
+
+	h.routes = httpapi.Router{
+		"/leaves":      {Method: http.MethodPost, Handle: h.addLeaf},
+		"/root":        {Method: http.MethodGet, Handle: h.root},
+		"/proof":       {Method: http.MethodGet, Handle: h.proof},
+		"/consistency": {Method: http.MethodGet, Handle: h.consistency},
+	}
+
+	return h
+}
+
+// ServeHTTP implements net/http.Handler.ServeHTTP.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.routes.ServeHTTP(w, r)
+}
+
+func (h *Handler) addLeaf(r *http.Request) (int, interface{}, error) {
+	var req AddLeafRequest
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, MaxBodySize)).Decode(&req); err != nil {
+		return 0, nil, httpapi.BadRequest(err)
+	}
+
+	leaf, err := hex.DecodeString(req.Leaf)
+	if err != nil {
+		return 0, nil, httpapi.BadRequest(err)
+	}
+	if len(leaf) < 1 {
+		return 0, nil, httpapi.BadRequest(errors.New("leaf is empty"))
+	}
+
+	index := h.tree.Append(leaf)
+	if err := h.tree.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusCreated, AddLeafResponse{
+		Index:    index,
+		LeafHash: hex.EncodeToString(h.tree.Leaf(index)),
+	}, nil
+}
+
+func (h *Handler) root(r *http.Request) (int, interface{}, error) {
+	var res RootResponse
+
+	h.tree.View(func(s *merkle.DynTreeSnapshot) {
+		res.TreeSize = s.LeavesLen()
+		res.Root = hex.EncodeToString(s.Root())
+	})
+
+	return http.StatusOK, res, nil
+}
+
+func (h *Handler) proof(r *http.Request) (int, interface{}, error) {
+	var (
+		query = r.URL.Query()
+		index = -1
+		hash  []byte
+		err   error
+	)
+
+	switch {
+	case query.Get("index") != "" && query.Get("hash") != "":
+		return 0, nil, httpapi.BadRequest(errors.New("index and hash are mutually exclusive"))
+	case query.Get("index") != "":
+		if index, err = strconv.Atoi(query.Get("index")); err != nil {
+			return 0, nil, httpapi.BadRequest(err)
+		}
+	case query.Get("hash") != "":
+		if hash, err = hex.DecodeString(query.Get("hash")); err != nil {
+			return 0, nil, httpapi.BadRequest(err)
+		}
+	default:
+		return 0, nil, httpapi.BadRequest(errors.New("index or hash is required"))
+	}
+
+	var res ProofResponse
+
+	h.tree.View(func(s *merkle.DynTreeSnapshot) {
+		size := s.LeavesLen()
+
+		if hash != nil {
+			var ok bool
+			if index, ok = h.lookup(s, hash); !ok {
+				err = httpapi.NotFound(ErrLeafNotFound)
+				return
+			}
+		}
+		if index < 0 || index >= size {
+			err = types.ErrIndexOutOfRange
+			return
+		}
+
+		res.LeafHash = hex.EncodeToString(s.Leaf(index))
+		res.Root = hex.EncodeToString(s.Root())
+		res.Proof, err = types.NewInclusionProof(index, size, s.Path(index))
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, res, nil
+}
+
+func (h *Handler) consistency(r *http.Request) (int, interface{}, error) {
+	query := r.URL.Query()
+
+	first, err := strconv.Atoi(query.Get("first"))
+	if err != nil {
+		return 0, nil, httpapi.BadRequest(fmt.Errorf("first: %w", err))
+	}
+
+	// The second size defaults to the current size of the tree.
+	second := -1
+	if query.Get("second") != "" {
+		if second, err = strconv.Atoi(query.Get("second")); err != nil {
+			return 0, nil, httpapi.BadRequest(fmt.Errorf("second: %w", err))
+		}
+	}
+
+	var (
+		res   = ConsistencyResponse{First: first, Proof: []string{}}
+		proof [][]byte
+	)
+
+	// Read the roots and the proof from the same state of the tree.
+	h.tree.View(func(s *merkle.DynTreeSnapshot) {
+		if second < 0 {
+			second = s.LeavesLen()
+		}
+		res.Second = second
+
+		if first < 0 || first > second || second > s.LeavesLen() {
+			err = types.ErrSizeOutOfRange
+			return
+		}
+
+		// The empty tree is a prefix of any tree, which is proved by an empty
+		// proof as in RFC 6962.
+		if first > 0 {
+			var firstRoot []byte
+			if firstRoot, err = s.RootAt(first); err != nil {
+				return
+			}
+			res.FirstRoot = hex.EncodeToString(firstRoot)
+
+			if proof, err = s.ConsistencyProof(first, second); err != nil {
+				return
+			}
+		}
+
+		// The current root is already computed, while RootAt computes the
+		// roots of older trees from their subtrees.
+		switch {
+		case second == s.LeavesLen() && second > 0:
+			res.SecondRoot = hex.EncodeToString(s.Root())
+		case second > 0:
+			var secondRoot []byte
+			if secondRoot, err = s.RootAt(second); err != nil {
+				return
+			}
+			res.SecondRoot = hex.EncodeToString(secondRoot)
+		}
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	for _, p := range proof {
+		res.Proof = append(res.Proof, hex.EncodeToString(p))
+	}
+
+	return http.StatusOK, res, nil
+}
+
+// Returns the first index of a leaf of the snapshot, indexing the leaves that
+// were added since the last lookup.
+func (h *Handler) lookup(s *merkle.DynTreeSnapshot, hash []byte) (int, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	size := s.LeavesLen()
+
+	// Start over if the tree was truncated.
+	if size < h.indexed {
+		h.indices = map[string]int{}
+		h.indexed = 0
+	}
+
+	for ; h.indexed < size; h.indexed++ {
+		key := string(s.Leaf(h.indexed))
+		if _, ok := h.indices[key]; !ok {
+			h.indices[key] = h.indexed
+		}
+	}
+
+	index, ok := h.indices[string(hash)]
+	if !ok || !bytes.Equal(s.Leaf(index), hash) {
+		return 0, false
+	}
+
+	return index, true
+}
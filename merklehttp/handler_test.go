@@ -0,0 +1,313 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merklehttp_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stratumn/merkle"
+	"github.com/stratumn/merkle/merklehttp"
+	"github.com/stratumn/merkle/types"
+)
+
+func newTestServer(t *testing.T, leaves int) (*merkle.DynTree, *httptest.Server) {
+	tree := merkle.NewDynTree(leaves, merkle.WithHasher(types.RFC6962SHA256))
+	for i := 0; i < leaves; i++ {
+		tree.Add([]byte(fmt.Sprintf("leaf %d", i)))
+	}
+
+	s := httptest.NewServer(merklehttp.NewHandler(tree))
+	t.Cleanup(s.Close)
+
+	return tree, s
+}
+
+// do sends a request and decodes the body of the response, returning the
+// status code.
+func do(t *testing.T, method, url, body string, res interface{}) int {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("http.NewRequest(): err: %s", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("http.Do(): err: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if got, want := resp.Header.Get("Content-Type"), "application/json"; got != want {
+		t.Errorf("%s %s: Content-Type = %q want %q", method, url, got, want)
+	}
+
+	if res != nil {
+		if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+			t.Fatalf("json.Decode(): err: %s", err)
+		}
+	}
+
+	return resp.StatusCode
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("hex.DecodeString(): err: %s", err)
+	}
+	return b
+}
+
+func TestHandler_addLeaf(t *testing.T) {
+	tree, s := newTestServer(t, 3)
+
+	var res merklehttp.AddLeafResponse
+	status := do(t, http.MethodPost, s.URL+"/leaves", `{"leaf":"616263"}`, &res)
+	if status != http.StatusCreated {
+		t.Fatalf("status = %d want %d", status, http.StatusCreated)
+	}
+	if got, want := res.Index, 3; got != want {
+		t.Errorf("res.Index = %d want %d", got, want)
+	}
+	if got, want := decodeHex(t, res.LeafHash), types.RFC6962SHA256.HashLeaf([]byte("abc")); !bytes.Equal(got, want) {
+		t.Errorf("res.LeafHash = %x want %x", got, want)
+	}
+	if got, want := tree.LeavesLen(), 4; got != want {
+		t.Errorf("tree.LeavesLen() = %d want %d", got, want)
+	}
+}
+
+func TestHandler_addLeaf_error(t *testing.T) {
+	_, s := newTestServer(t, 1)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"malformed JSON", `{"leaf":`, http.StatusBadRequest},
+		{"malformed hex", `{"leaf":"zz"}`, http.StatusBadRequest},
+		{"empty leaf", `{}`, http.StatusBadRequest},
+		{"too large", `{"leaf":"` + strings.Repeat("00", merklehttp.MaxBodySize) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		var res merklehttp.ErrorResponse
+		if got := do(t, http.MethodPost, s.URL+"/leaves", tt.body, &res); got != tt.status {
+			t.Errorf("%s: status = %d want %d", tt.name, got, tt.status)
+		}
+		if res.Error == "" {
+			t.Errorf("%s: res.Error is empty", tt.name)
+		}
+	}
+}
+
+func TestHandler_root(t *testing.T) {
+	tree, s := newTestServer(t, 0)
+
+	var res merklehttp.RootResponse
+	if status := do(t, http.MethodGet, s.URL+"/root", "", &res); status != http.StatusOK {
+		t.Fatalf("empty: status = %d want %d", status, http.StatusOK)
+	}
+	if res != (merklehttp.RootResponse{}) {
+		t.Errorf("empty: res = %#v want zero", res)
+	}
+
+	tree.Add([]byte("a"))
+	tree.Add([]byte("b"))
+
+	do(t, http.MethodGet, s.URL+"/root", "", &res)
+	if got, want := res.TreeSize, 2; got != want {
+		t.Errorf("res.TreeSize = %d want %d", got, want)
+	}
+	if got, want := decodeHex(t, res.Root), tree.Root(); !bytes.Equal(got, want) {
+		t.Errorf("res.Root = %x want %x", got, want)
+	}
+}
+
+func TestHandler_proof(t *testing.T) {
+	tree, s := newTestServer(t, 7)
+
+	for i := 0; i < tree.LeavesLen(); i++ {
+		for _, query := range []string{
+			fmt.Sprintf("index=%d", i),
+			"hash=" + hex.EncodeToString(tree.Leaf(i)),
+		} {
+			var res merklehttp.ProofResponse
+			if status := do(t, http.MethodGet, s.URL+"/proof?"+query, "", &res); status != http.StatusOK {
+				t.Fatalf("%s: status = %d want %d", query, status, http.StatusOK)
+			}
+			if got, want := res.Proof.Index, i; got != want {
+				t.Errorf("%s: res.Proof.Index = %d want %d", query, got, want)
+			}
+			err := res.Proof.VerifyWith(types.RFC6962SHA256, decodeHex(t, res.LeafHash), decodeHex(t, res.Root))
+			if err != nil {
+				t.Errorf("%s: res.Proof.VerifyWith(): err: %s", query, err)
+			}
+		}
+	}
+}
+
+func TestHandler_proof_grow(t *testing.T) {
+	tree, s := newTestServer(t, 2)
+
+	// Leaves added after a lookup are indexed by the next one.
+	do(t, http.MethodGet, s.URL+"/proof?hash="+hex.EncodeToString(tree.Leaf(0)), "", nil)
+	index := tree.Append([]byte("new"))
+
+	var res merklehttp.ProofResponse
+	status := do(t, http.MethodGet, s.URL+"/proof?hash="+hex.EncodeToString(tree.Leaf(index)), "", &res)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d want %d", status, http.StatusOK)
+	}
+	if got, want := res.Proof.Index, index; got != want {
+		t.Errorf("res.Proof.Index = %d want %d", got, want)
+	}
+
+	// Truncated leaves are not found.
+	if err := tree.Truncate(2); err != nil {
+		t.Fatalf("tree.Truncate(): err: %s", err)
+	}
+	status = do(t, http.MethodGet, s.URL+"/proof?hash="+res.LeafHash, "", nil)
+	if status != http.StatusNotFound {
+		t.Errorf("truncated: status = %d want %d", status, http.StatusNotFound)
+	}
+}
+
+func TestHandler_proof_error(t *testing.T) {
+	_, s := newTestServer(t, 3)
+
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{"", http.StatusBadRequest},
+		{"index=a", http.StatusBadRequest},
+		{"hash=zz", http.StatusBadRequest},
+		{"index=0&hash=00", http.StatusBadRequest},
+		{"index=3", http.StatusNotFound},
+		{"index=-1", http.StatusNotFound},
+		{"hash=" + strings.Repeat("00", 32), http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		if got := do(t, http.MethodGet, s.URL+"/proof?"+tt.query, "", nil); got != tt.status {
+			t.Errorf("%q: status = %d want %d", tt.query, got, tt.status)
+		}
+	}
+}
+
+func TestHandler_consistency(t *testing.T) {
+	tree, s := newTestServer(t, 9)
+
+	for first := 1; first <= 9; first++ {
+		for second := first; second <= 9; second++ {
+			var res merklehttp.ConsistencyResponse
+			url := fmt.Sprintf("%s/consistency?first=%d&second=%d", s.URL, first, second)
+			if status := do(t, http.MethodGet, url, "", &res); status != http.StatusOK {
+				t.Fatalf("%d, %d: status = %d want %d", first, second, status, http.StatusOK)
+			}
+
+			proof := make([][]byte, len(res.Proof))
+			for i, p := range res.Proof {
+				proof[i] = decodeHex(t, p)
+			}
+			firstRoot, secondRoot := decodeHex(t, res.FirstRoot), decodeHex(t, res.SecondRoot)
+
+			if err := types.VerifyConsistencyWith(types.RFC6962SHA256, first, second, firstRoot, secondRoot, proof); err != nil {
+				t.Errorf("%d, %d: types.VerifyConsistencyWith(): err: %s", first, second, err)
+			}
+		}
+	}
+
+	var res merklehttp.ConsistencyResponse
+	do(t, http.MethodGet, s.URL+"/consistency?first=4", "", &res)
+	if got, want := res.Second, 9; got != want {
+		t.Errorf("default: res.Second = %d want %d", got, want)
+	}
+	if got, want := decodeHex(t, res.SecondRoot), tree.Root(); !bytes.Equal(got, want) {
+		t.Errorf("default: res.SecondRoot = %x want %x", got, want)
+	}
+}
+
+func TestHandler_consistency_empty(t *testing.T) {
+	tree, s := newTestServer(t, 0)
+
+	var res merklehttp.ConsistencyResponse
+	if status := do(t, http.MethodGet, s.URL+"/consistency?first=0", "", &res); status != http.StatusOK {
+		t.Fatalf("empty tree: status = %d want %d", status, http.StatusOK)
+	}
+	if res.Second != 0 || res.FirstRoot != "" || res.SecondRoot != "" || res.Proof == nil || len(res.Proof) != 0 {
+		t.Errorf("empty tree: res = %#v want an empty proof", res)
+	}
+
+	for i := 0; i < 3; i++ {
+		tree.Add([]byte{byte(i)})
+	}
+	root, err := tree.RootAt(2)
+	if err != nil {
+		t.Fatalf("tree.RootAt(): err: %s", err)
+	}
+
+	res = merklehttp.ConsistencyResponse{}
+	if status := do(t, http.MethodGet, s.URL+"/consistency?first=0&second=2", "", &res); status != http.StatusOK {
+		t.Fatalf("status = %d want %d", status, http.StatusOK)
+	}
+	if res.Proof == nil || len(res.Proof) != 0 {
+		t.Errorf("res.Proof = %#v want empty", res.Proof)
+	}
+	if res.FirstRoot != "" {
+		t.Errorf("res.FirstRoot = %q want empty", res.FirstRoot)
+	}
+	if got, want := decodeHex(t, res.SecondRoot), root; !bytes.Equal(got, want) {
+		t.Errorf("res.SecondRoot = %x want %x", got, want)
+	}
+}
+
+func TestHandler_consistency_error(t *testing.T) {
+	_, s := newTestServer(t, 3)
+
+	for _, query := range []string{"", "first=a", "first=1&second=a", "first=-1", "first=3&second=2", "first=1&second=4"} {
+		if got, want := do(t, http.MethodGet, s.URL+"/consistency?"+query, "", nil), http.StatusBadRequest; got != want {
+			t.Errorf("%q: status = %d want %d", query, got, want)
+		}
+	}
+}
+
+func TestHandler_routes(t *testing.T) {
+	_, s := newTestServer(t, 1)
+
+	if got, want := do(t, http.MethodGet, s.URL+"/unknown", "", nil), http.StatusNotFound; got != want {
+		t.Errorf("unknown: status = %d want %d", got, want)
+	}
+
+	resp, err := http.Get(s.URL + "/leaves")
+	if err != nil {
+		t.Fatalf("http.Get(): err: %s", err)
+	}
+	resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusMethodNotAllowed; got != want {
+		t.Errorf("method: status = %d want %d", got, want)
+	}
+	if got, want := resp.Header.Get("Allow"), http.MethodPost; got != want {
+		t.Errorf("method: Allow = %q want %q", got, want)
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !unix
+
+package merkle
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// Reads a file to memory since memory mapping is not supported on this
+// platform.
+func mmapFile(f *os.File) ([]byte, error) {
+	return ioutil.ReadAll(f)
+}
+
+// Does nothing since memory mapping is not supported on this platform.
+func munmap(data []byte) error {
+	return nil
+}
@@ -0,0 +1,259 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkle
+
+import (
+	"bytes"
+	"math/bits"
+	"sync"
+
+	"github.com/stratumn/merkle/types"
+)
+
+// MMR is a Merkle Mountain Range, an append-only accumulator made of perfect
+// binary trees, called mountains, whose roots are the peaks of the range.
+//
+// Appending a leaf only creates new nodes, so existing nodes and the proofs
+// made of them never change. The root of the range is obtained by bagging
+// the peaks from right to left, which gives the same root as the other trees
+// of this package for the same leaves.
+type MMR struct {
+	mutex  sync.RWMutex
+	hasher types.Hasher
+
+	// nodes contains the nodes of all the mountains in post-order.
+	nodes  [][]byte
+	leaves int
+}
+
+// NewMMR creates an MMR.
+func NewMMR(initialCap int, opts ...Option) *MMR {
+	return &MMR{
+		hasher: newOptions(opts).hasher,
+		nodes:  make([][]byte, 0, initialCap*2),
+	}
+}
+
+// Hasher returns the hasher used to compute the nodes.
+func (m *MMR) Hasher() types.Hasher {
+	return m.hasher
+}
+
+// LeavesLen returns the number of leaves. Implements Tree.LeavesLen.
+func (m *MMR) LeavesLen() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.leaves
+}
+
+// Root returns the Merkle root, which is the bagging of the peaks. Implements
+// Tree.Root.
+func (m *MMR) Root() []byte {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return types.BagPeaksWith(m.hasher, m.peaks())
+}
+
+// Leaf returns the leaf at the specified index. Implements Tree.Leaf.
+func (m *MMR) Leaf(index int) []byte {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.nodes[mmrLeafPos(index)]
+}
+
+// Peaks returns the peaks of the range from left to right.
+func (m *MMR) Peaks() [][]byte {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.peaks()
+}
+
+// Append appends a leaf to the range. The leaf is hashed using the hasher of
+// the range, which by default leaves it unchanged.
+func (m *MMR) Append(leaf []byte) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.nodes = append(m.nodes, m.hasher.HashLeaf(leaf))
+
+	// Each trailing one of the previous number of leaves is a mountain of
+	// the same height as the new one that must be merged with it.
+	for n, height := m.leaves, uint(0); n&1 == 1; n, height = n>>1, height+1 {
+		var (
+			right = m.nodes[len(m.nodes)-1]
+			left  = m.nodes[len(m.nodes)-1-(2<<height-1)]
+		)
+		m.nodes = append(m.nodes, m.hasher.HashNode(left, right))
+	}
+
+	m.leaves++
+}
+
+// Path returns the path of a leaf to the Merkle root. Implements Tree.Path.
+func (m *MMR) Path(index int) types.Path {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.path(index)
+}
+
+// MultiProof returns a proof of the inclusion of the leaves at the specified
+// indices, without the hashes that can be computed from the leaves.
+func (m *MMR) MultiProof(indices []int) (*types.MultiProof, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return types.NewMultiProof(indices, m.leaves, m.path)
+}
+
+// Proof returns a proof of the inclusion of the leaf at the specified index
+// made of the siblings within its mountain and the peaks of the range.
+func (m *MMR) Proof(index int) (*types.MMRProof, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if index < 0 || index >= m.leaves {
+		return nil, types.ErrIndexOutOfRange
+	}
+
+	return &types.MMRProof{
+		Index:    index,
+		TreeSize: m.leaves,
+		Siblings: m.siblings(index),
+		Peaks:    m.peaks(),
+	}, nil
+}
+
+// ExtendProof updates a proof made when the range had fewer leaves so that it
+// can be verified against the current root. The siblings of the proof are
+// kept and only the siblings above the old peak of the leaf are added.
+func (m *MMR) ExtendProof(proof *types.MMRProof) (*types.MMRProof, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if proof.TreeSize > m.leaves || proof.Index < 0 || proof.Index >= proof.TreeSize {
+		return nil, types.ErrIndexOutOfRange
+	}
+
+	siblings := m.siblings(proof.Index)
+	if len(siblings) < len(proof.Siblings) {
+		return nil, types.ErrIndexMismatch
+	}
+	for i, s := range proof.Siblings {
+		if !bytes.Equal(s, siblings[i]) {
+			return nil, types.ErrInconsistentProof
+		}
+	}
+
+	return &types.MMRProof{
+		Index:    proof.Index,
+		TreeSize: m.leaves,
+		Siblings: append(append([][]byte(nil), proof.Siblings...), siblings[len(proof.Siblings):]...),
+		Peaks:    m.peaks(),
+	}, nil
+}
+
+func (m *MMR) peaks() [][]byte {
+	var (
+		peaks [][]byte
+		pos   int
+	)
+
+	for height := bits.Len(uint(m.leaves)) - 1; height >= 0; height-- {
+		if m.leaves&(1<<uint(height)) != 0 {
+			pos += 2<<uint(height) - 1
+			peaks = append(peaks, m.nodes[pos-1])
+		}
+	}
+
+	return peaks
+}
+
+// Returns the siblings of the nodes from the leaf at the given index to the
+// peak of its mountain.
+func (m *MMR) siblings(index int) [][]byte {
+	mountain, local, height := types.MMRMountain(index, m.leaves)
+
+	// Skip the mountains on the left.
+	pos := 0
+	for h := bits.Len(uint(m.leaves)) - 1; mountain > 0; h-- {
+		if m.leaves&(1<<uint(h)) != 0 {
+			pos += 2<<uint(h) - 1
+			mountain--
+		}
+	}
+
+	siblings := make([][]byte, height)
+	for h := height; h > 0; h-- {
+		var (
+			half     = 1 << uint(h-1)
+			leftSize = 2*half - 1
+		)
+		if local < half {
+			siblings[h-1] = m.nodes[pos+2*leftSize-1]
+		} else {
+			siblings[h-1] = m.nodes[pos+leftSize-1]
+			pos += leftSize
+			local -= half
+		}
+	}
+
+	return siblings
+}
+
+func (m *MMR) path(index int) types.Path {
+	if m.leaves < 2 {
+		return types.Path{}
+	}
+
+	var (
+		mountain, local, _ = types.MMRMountain(index, m.leaves)
+		peaks              = m.peaks()
+		path               = types.Path{}
+		node               = m.nodes[mmrLeafPos(index)]
+	)
+
+	add := func(left, right []byte) {
+		node = m.hasher.HashNode(left, right)
+		path = append(path, types.MerkleNodeHashes{Left: left, Right: right, Parent: node})
+	}
+
+	for i, sibling := range m.siblings(index) {
+		if local&(1<<uint(i)) == 0 {
+			add(node, sibling)
+		} else {
+			add(sibling, node)
+		}
+	}
+
+	if mountain < len(peaks)-1 {
+		add(node, types.BagPeaksWith(m.hasher, peaks[mountain+1:]))
+	}
+	for i := mountain - 1; i >= 0; i-- {
+		add(peaks[i], node)
+	}
+
+	return path
+}
+
+// Returns the position of the leaf at the given index in the post-order
+// sequence of nodes.
+func mmrLeafPos(index int) int {
+	return 2*index - bits.OnesCount(uint(index))
+}
@@ -0,0 +1,160 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkle_test
+
+import (
+	"encoding/hex"
+	"math/bits"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/stratumn/merkle"
+	"github.com/stratumn/merkle/testutil"
+	"github.com/stratumn/merkle/treetestcases"
+	"github.com/stratumn/merkle/types"
+)
+
+func TestMMR(t *testing.T) {
+	for _, h := range hashers {
+		hasher := h.hasher
+		t.Run(h.name, treetestcases.Factory{
+			New: func(leaves [][]byte) (merkle.Tree, error) {
+				mmr := merkle.NewMMR(len(leaves), merkle.WithHasher(hasher))
+				for _, leaf := range leaves {
+					mmr.Append(leaf)
+				}
+				return mmr, nil
+			},
+			Hasher: hasher,
+		}.RunTests)
+	}
+}
+
+func TestMMRPeaks(t *testing.T) {
+	mmr := merkle.NewMMR(0)
+
+	for i := 1; i < 100; i++ {
+		mmr.Append(testutil.RandomHash())
+
+		peaks := mmr.Peaks()
+		if got, want := len(peaks), bits.OnesCount(uint(i)); got != want {
+			t.Errorf("test#%d: len(mmr.Peaks()) = %d want %d", i, got, want)
+		}
+		if got, want := hex.EncodeToString(types.BagPeaks(peaks)), hex.EncodeToString(mmr.Root()); got != want {
+			t.Errorf("test#%d: types.BagPeaks() = %q want %q", i, got, want)
+		}
+	}
+}
+
+func TestMMRProof(t *testing.T) {
+	for _, h := range hashers {
+		for i := 1; i < 70; i++ {
+			mmr := merkle.NewMMR(i, merkle.WithHasher(h.hasher))
+			for j := 0; j < i; j++ {
+				mmr.Append(testutil.RandomHash())
+			}
+
+			root := mmr.Root()
+
+			for j := 0; j < i; j++ {
+				proof, err := mmr.Proof(j)
+				if err != nil {
+					t.Fatalf("%s: mmr.Proof(): err: %s", h.name, err)
+				}
+				if err := proof.VerifyWith(h.hasher, mmr.Leaf(j), root); err != nil {
+					t.Errorf("%s: test#%d: proof.VerifyWith(%d): err: %s", h.name, i, j, err)
+				}
+				if err := proof.VerifyWith(h.hasher, testutil.RandomHash(), root); err == nil {
+					t.Errorf("%s: test#%d: proof.VerifyWith(%d): err = nil want Error", h.name, i, j)
+				}
+			}
+		}
+	}
+}
+
+func TestMMRProof_append(t *testing.T) {
+	mmr := merkle.NewMMR(0)
+
+	for i := 0; i < 200; i++ {
+		mmr.Append(testutil.RandomHash())
+	}
+
+	for i := 0; i < 100; i++ {
+		var (
+			index    = rand.Intn(mmr.LeavesLen())
+			leaf     = mmr.Leaf(index)
+			oldRoot  = mmr.Root()
+			proof, _ = mmr.Proof(index)
+		)
+
+		for j := rand.Intn(100); j >= 0; j-- {
+			mmr.Append(testutil.RandomHash())
+		}
+
+		extended, err := mmr.ExtendProof(proof)
+		if err != nil {
+			t.Fatalf("mmr.ExtendProof(): err: %s", err)
+		}
+		if got, want := extended.Siblings[:len(proof.Siblings)], proof.Siblings; !reflect.DeepEqual(got, want) {
+			t.Errorf("test#%d: extended.Siblings = %x want prefix %x", i, got, want)
+		}
+		if err := extended.Verify(leaf, mmr.Root()); err != nil {
+			t.Errorf("test#%d: extended.Verify(): err: %s", i, err)
+		}
+		if err := proof.Verify(leaf, oldRoot); err != nil {
+			t.Errorf("test#%d: proof.Verify(): err: %s", i, err)
+		}
+	}
+}
+
+func TestMMRProof_Error(t *testing.T) {
+	mmr := merkle.NewMMR(0)
+
+	for i := 0; i < 10; i++ {
+		mmr.Append(testutil.RandomHash())
+	}
+
+	if _, err := mmr.Proof(10); err != types.ErrIndexOutOfRange {
+		t.Errorf("mmr.Proof(): err = %v want %v", err, types.ErrIndexOutOfRange)
+	}
+
+	proof, _ := mmr.Proof(3)
+	proof.TreeSize = 11
+	if _, err := mmr.ExtendProof(proof); err != types.ErrIndexOutOfRange {
+		t.Errorf("mmr.ExtendProof(): err = %v want %v", err, types.ErrIndexOutOfRange)
+	}
+
+	proof, _ = mmr.Proof(3)
+	proof.Siblings[0] = testutil.RandomHash()
+	if _, err := mmr.ExtendProof(proof); err != types.ErrInconsistentProof {
+		t.Errorf("mmr.ExtendProof(): err = %v want %v", err, types.ErrInconsistentProof)
+	}
+}
+
+func BenchmarkMMRAppend(b *testing.B) {
+	mmr := merkle.NewMMR(b.N)
+
+	leaves := make([][]byte, b.N)
+	for i := range leaves {
+		leaves[i] = testutil.RandomHash()
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		mmr.Append(leaves[i])
+	}
+}
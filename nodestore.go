@@ -0,0 +1,146 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkle
+
+import (
+	"errors"
+)
+
+// ErrNodeNotFound is returned by a NodeStore when a node does not exist.
+var ErrNodeNotFound = errors.New("node not found")
+
+// NodeStore stores the hashes of the nodes of a tree by level and index.
+// Level zero contains the leaves, and the node at a given level and index is
+// the parent of the nodes at the level below with twice the index and twice
+// the index plus one. When a level has an odd number of nodes, the last one
+// is promoted to the level above, so it is stored at both levels.
+//
+// Trees never write to a store concurrently with other calls, so
+// implementations used by a single tree only need to support concurrent
+// reads. If a node cannot be read, a DynTree records the error, which is
+// returned by its methods that return errors and by DynTree.Err, while a
+// StaticTree panics since it cannot report errors.
+type NodeStore interface {
+	// Get returns the hash of the node at the given level and index.
+	Get(level, index int) ([]byte, error)
+
+	// Put stores the hash of the node at the given level and index,
+	// replacing the previous one, if any. The store may keep a reference to
+	// the hash, so it must not be modified afterwards.
+	Put(level, index int, hash []byte) error
+
+	// PutBatch stores the hashes of several nodes at once.
+	PutBatch(nodes []StoredNode) error
+}
+
+// NodeStoreTruncater is an optional interface implemented by node stores
+// that can discard nodes.
+type NodeStoreTruncater interface {
+	// Truncate discards the nodes of a level from the given index.
+	Truncate(level, size int) error
+}
+
+// StoredNode is a node written to a NodeStore.
+type StoredNode struct {
+	Level int
+	Index int
+	Hash  []byte
+}
+
+// MemNodeStore is a NodeStore that keeps the nodes in memory. It does not
+// support concurrent writes.
+type MemNodeStore struct {
+	levels [][][]byte
+}
+
+// NewMemNodeStore creates a MemNodeStore with room for the nodes of a tree
+// with the given number of leaves.
+func NewMemNodeStore(initialCap int) *MemNodeStore {
+	s := &MemNodeStore{}
+	for n := initialCap; n > 0; n = (n + 1) / 2 {
+		s.levels = append(s.levels, make([][]byte, 0, n))
+		if n == 1 {
+			break
+		}
+	}
+	return s
+}
+
+// Get implements NodeStore.Get.
+func (s *MemNodeStore) Get(level, index int) ([]byte, error) {
+	if level < 0 || level >= len(s.levels) || index < 0 || index >= len(s.levels[level]) {
+		return nil, ErrNodeNotFound
+	}
+
+	hash := s.levels[level][index]
+	if hash == nil {
+		return nil, ErrNodeNotFound
+	}
+
+	return hash, nil
+}
+
+// Put implements NodeStore.Put.
+func (s *MemNodeStore) Put(level, index int, hash []byte) error {
+	return s.put(level, index, hash)
+}
+
+// PutBatch implements NodeStore.PutBatch.
+func (s *MemNodeStore) PutBatch(nodes []StoredNode) error {
+	for _, node := range nodes {
+		if err := s.put(node.Level, node.Index, node.Hash); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Truncate implements NodeStoreTruncater.Truncate.
+func (s *MemNodeStore) Truncate(level, size int) error {
+	if level < 0 || size < 0 {
+		return errors.New("invalid node position")
+	}
+	if level >= len(s.levels) || size >= len(s.levels[level]) {
+		return nil
+	}
+
+	nodes := s.levels[level]
+	for i := size; i < len(nodes); i++ {
+		nodes[i] = nil
+	}
+	s.levels[level] = nodes[:size]
+
+	return nil
+}
+
+func (s *MemNodeStore) put(level, index int, hash []byte) error {
+	if level < 0 || index < 0 {
+		return errors.New("invalid node position")
+	}
+
+	for len(s.levels) <= level {
+		s.levels = append(s.levels, nil)
+	}
+
+	nodes := s.levels[level]
+	for len(nodes) <= index {
+		nodes = append(nodes, nil)
+	}
+	nodes[index] = hash
+	s.levels[level] = nodes
+
+	return nil
+}
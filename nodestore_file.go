@@ -0,0 +1,292 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkle
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+)
+
+// ErrInvalidNodeStoreFile is returned when the file of a FileNodeStore is
+// corrupted.
+var ErrInvalidNodeStoreFile = errors.New("invalid node store file")
+
+// maxFileNodeHashLen is the maximum length of a hash in a FileNodeStore. It
+// bounds the memory allocated when reading a corrupted file.
+const maxFileNodeHashLen = 1 << 16
+
+// FileNodeStore is a NodeStore that appends the nodes to a file. Replacing a
+// node appends its new hash, so the file only grows. The position of the last
+// hash of each node is kept in memory, which requires much less memory than
+// the hashes themselves.
+//
+// Each node is written as its level, its index and the length of its hash as
+// unsigned varints, followed by the hash and the big-endian CRC-32C of the
+// previous bytes. Truncating a level writes a record with an empty hash whose
+// index is the new size of the level.
+type FileNodeStore struct {
+	mutex sync.RWMutex
+	file  *os.File
+	size  int64
+	refs  [][]fileNodeRef
+}
+
+// fileNodeRef is the position of a hash in the file of a FileNodeStore.
+type fileNodeRef struct {
+	offset int64
+	size   int32
+}
+
+// OpenFileNodeStore opens the FileNodeStore stored in the given file,
+// creating it if it does not exist. The last node is discarded if it was only
+// partially written, for instance because of a crash, but an error wrapping
+// ErrInvalidNodeStoreFile is returned if any other node is invalid.
+func OpenFileNodeStore(filename string) (*FileNodeStore, error) {
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &FileNodeStore{file: file}
+	if err := s.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Get implements NodeStore.Get.
+func (s *FileNodeStore) Get(level, index int) ([]byte, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if level < 0 || level >= len(s.refs) || index < 0 || index >= len(s.refs[level]) {
+		return nil, ErrNodeNotFound
+	}
+
+	ref := s.refs[level][index]
+	if ref.size == 0 {
+		return nil, ErrNodeNotFound
+	}
+
+	hash := make([]byte, ref.size)
+	if _, err := s.file.ReadAt(hash, ref.offset); err != nil {
+		return nil, err
+	}
+
+	return hash, nil
+}
+
+// Put implements NodeStore.Put.
+func (s *FileNodeStore) Put(level, index int, hash []byte) error {
+	return s.PutBatch([]StoredNode{{Level: level, Index: index, Hash: hash}})
+}
+
+// PutBatch implements NodeStore.PutBatch. The nodes are written to the file
+// using a single write.
+func (s *FileNodeStore) PutBatch(nodes []StoredNode) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var (
+		buf  []byte
+		refs = make([]fileNodeRef, len(nodes))
+	)
+
+	for i, node := range nodes {
+		if node.Level < 0 || node.Index < 0 || len(node.Hash) < 1 || len(node.Hash) > maxFileNodeHashLen {
+			return errors.New("invalid node")
+		}
+
+		start := len(buf)
+		buf = binary.AppendUvarint(buf, uint64(node.Level))
+		buf = binary.AppendUvarint(buf, uint64(node.Index))
+		buf = binary.AppendUvarint(buf, uint64(len(node.Hash)))
+		refs[i] = fileNodeRef{offset: s.size + int64(len(buf)), size: int32(len(node.Hash))}
+		buf = append(buf, node.Hash...)
+		buf = binary.BigEndian.AppendUint32(buf, crc32.Checksum(buf[start:], crc32c))
+	}
+
+	if _, err := s.file.WriteAt(buf, s.size); err != nil {
+		return err
+	}
+	s.size += int64(len(buf))
+
+	for i, node := range nodes {
+		s.setRef(node.Level, node.Index, refs[i])
+	}
+
+	return nil
+}
+
+// Truncate implements NodeStoreTruncater.Truncate.
+func (s *FileNodeStore) Truncate(level, size int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if level < 0 || size < 0 {
+		return errors.New("invalid node position")
+	}
+	if level >= len(s.refs) || size >= len(s.refs[level]) {
+		return nil
+	}
+
+	buf := binary.AppendUvarint(nil, uint64(level))
+	buf = binary.AppendUvarint(buf, uint64(size))
+	buf = binary.AppendUvarint(buf, 0)
+	buf = binary.BigEndian.AppendUint32(buf, crc32.Checksum(buf, crc32c))
+
+	if _, err := s.file.WriteAt(buf, s.size); err != nil {
+		return err
+	}
+	s.size += int64(len(buf))
+	s.refs[level] = s.refs[level][:size]
+
+	return nil
+}
+
+// Sync commits the file to stable storage.
+func (s *FileNodeStore) Sync() error {
+	return s.file.Sync()
+}
+
+// Close closes the file.
+func (s *FileNodeStore) Close() error {
+	return s.file.Close()
+}
+
+func (s *FileNodeStore) setRef(level, index int, ref fileNodeRef) {
+	for len(s.refs) <= level {
+		s.refs = append(s.refs, nil)
+	}
+
+	refs := s.refs[level]
+	for len(refs) <= index {
+		refs = append(refs, fileNodeRef{})
+	}
+	refs[index] = ref
+	s.refs[level] = refs
+}
+
+// Reads the positions of the nodes from the file and truncates the file
+// after the last valid node.
+func (s *FileNodeStore) load() error {
+	r := bufio.NewReader(s.file)
+
+	for {
+		level, index, size, n, err := readFileNode(r)
+		if err == io.EOF || err == errTornNode {
+			break
+		}
+		if err == errNodeChecksum {
+			if _, err := r.Peek(1); err == io.EOF {
+				// The last node was not fully written.
+				break
+			}
+		}
+		if err == errNodeChecksum || err == errInvalidNode {
+			return fmt.Errorf("%w: invalid node at offset %d", ErrInvalidNodeStoreFile, s.size)
+		}
+		if err != nil {
+			return err
+		}
+
+		if size == 0 {
+			if level < len(s.refs) && index < len(s.refs[level]) {
+				s.refs[level] = s.refs[level][:index]
+			}
+		} else {
+			s.setRef(level, index, fileNodeRef{offset: s.size + int64(n-crc32.Size-size), size: int32(size)})
+		}
+		s.size += int64(n)
+	}
+
+	return s.file.Truncate(s.size)
+}
+
+var (
+	// errTornNode is returned when a node of a FileNodeStore ends after the
+	// end of the file.
+	errTornNode = errors.New("torn node")
+
+	// errNodeChecksum is returned when the checksum of a node of a
+	// FileNodeStore does not match.
+	errNodeChecksum = errors.New("node checksum mismatch")
+
+	// errInvalidNode is returned when a node of a FileNodeStore cannot be
+	// decoded.
+	errInvalidNode = errors.New("invalid node")
+)
+
+// Reads a node and returns its level, its index, the size of its hash and
+// the number of bytes read. It returns errTornNode if the node is
+// incomplete, errNodeChecksum if its checksum does not match and
+// errInvalidNode if it cannot be decoded.
+func readFileNode(r *bufio.Reader) (level, index, size, n int, err error) {
+	var fields [3]uint64
+	var buf []byte
+
+	for i := range fields {
+		start := len(buf)
+		for len(buf) == start || buf[len(buf)-1]&0x80 != 0 {
+			b, err := r.ReadByte()
+			if err == io.EOF && len(buf) == 0 {
+				return 0, 0, 0, 0, io.EOF
+			}
+			if err == io.EOF {
+				return 0, 0, 0, 0, errTornNode
+			}
+			if len(buf)-start == binary.MaxVarintLen64 {
+				return 0, 0, 0, 0, errInvalidNode
+			}
+			if err != nil {
+				return 0, 0, 0, 0, err
+			}
+			buf = append(buf, b)
+		}
+
+		v, n := binary.Uvarint(buf[start:])
+		if n <= 0 {
+			return 0, 0, 0, 0, errInvalidNode
+		}
+		fields[i] = v
+	}
+
+	const maxInt32 = 1<<31 - 1
+	if fields[0] > maxInt32 || fields[1] > maxInt32 || fields[2] > maxFileNodeHashLen {
+		return 0, 0, 0, 0, errInvalidNode
+	}
+
+	tail := make([]byte, int(fields[2])+crc32.Size)
+	if _, err := io.ReadFull(r, tail); err == io.EOF || err == io.ErrUnexpectedEOF {
+		return 0, 0, 0, 0, errTornNode
+	} else if err != nil {
+		return 0, 0, 0, 0, err
+	}
+
+	buf = append(buf, tail[:fields[2]]...)
+	if crc32.Checksum(buf, crc32c) != binary.BigEndian.Uint32(tail[fields[2]:]) {
+		return 0, 0, 0, 0, errNodeChecksum
+	}
+
+	return int(fields[0]), int(fields[1]), int(fields[2]), len(buf) + crc32.Size, nil
+}
@@ -0,0 +1,465 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkle_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stratumn/merkle"
+	"github.com/stratumn/merkle/testutil"
+	"github.com/stratumn/merkle/treetestcases"
+	"github.com/stratumn/merkle/types"
+)
+
+// stores contains the node stores the trees are tested with. Each function
+// returns a new empty store that is closed at the end of the test.
+var stores = []struct {
+	name string
+	new  func(t testing.TB) merkle.NodeStore
+}{
+	{"mem", func(t testing.TB) merkle.NodeStore {
+		return merkle.NewMemNodeStore(0)
+	}},
+	{"file", func(t testing.TB) merkle.NodeStore {
+		f, err := os.CreateTemp(t.TempDir(), "nodes")
+		if err != nil {
+			t.Fatalf("os.CreateTemp(): err: %s", err)
+		}
+		f.Close()
+
+		store, err := merkle.OpenFileNodeStore(f.Name())
+		if err != nil {
+			t.Fatalf("merkle.OpenFileNodeStore(): err: %s", err)
+		}
+		t.Cleanup(func() { store.Close() })
+
+		return store
+	}},
+}
+
+// The trees are tested with each store using the default hasher, and with
+// each hasher in TestNodeStore_hashers.
+func TestDynTree_nodeStore(t *testing.T) {
+	for _, s := range stores {
+		newStore := s.new
+		t.Run(s.name, func(t *testing.T) {
+			treetestcases.Factory{
+				New: func(leaves [][]byte) (merkle.Tree, error) {
+					tree := merkle.NewDynTree(0, merkle.WithNodeStore(newStore(t)))
+					for _, leaf := range leaves {
+						tree.Add(leaf)
+					}
+					return tree, nil
+				},
+			}.RunTests(t)
+		})
+	}
+}
+
+func TestStaticTree_nodeStore(t *testing.T) {
+	for _, s := range stores {
+		newStore := s.new
+		t.Run(s.name, func(t *testing.T) {
+			treetestcases.Factory{
+				New: func(leaves [][]byte) (merkle.Tree, error) {
+					return merkle.NewStaticTree(leaves, merkle.WithNodeStore(newStore(t)))
+				},
+			}.RunTests(t)
+		})
+	}
+}
+
+func TestNodeStore_hashers(t *testing.T) {
+	leaves := make([][]byte, 37)
+	for i := range leaves {
+		leaves[i] = testutil.RandomHash()
+	}
+
+	for _, s := range stores {
+		for _, h := range hashers {
+			name := s.name + "/" + h.name
+
+			static, err := merkle.NewStaticTree(leaves, merkle.WithHasher(h.hasher), merkle.WithNodeStore(s.new(t)))
+			if err != nil {
+				t.Fatalf("%s: merkle.NewStaticTree(): err: %s", name, err)
+			}
+			checkStaticTree(t, name, h.hasher, static, leaves)
+
+			dyn := merkle.NewDynTree(0, merkle.WithHasher(h.hasher), merkle.WithNodeStore(s.new(t)))
+			for _, leaf := range leaves {
+				dyn.Add(leaf)
+			}
+			checkStaticTree(t, name, h.hasher, dyn, leaves)
+		}
+	}
+}
+
+func TestNodeStore_roots(t *testing.T) {
+	leaves := make([][]byte, 1001)
+	for i := range leaves {
+		leaves[i] = testutil.RandomHash()
+	}
+
+	want, err := merkle.NewStaticTree(leaves, merkle.WithHasher(types.RFC6962SHA256))
+	if err != nil {
+		t.Fatalf("merkle.NewStaticTree(): err: %s", err)
+	}
+
+	for _, s := range stores {
+		static, err := merkle.NewStaticTreeParallel(leaves, 3, merkle.WithHasher(types.RFC6962SHA256), merkle.WithNodeStore(s.new(t)))
+		if err != nil {
+			t.Fatalf("%s: merkle.NewStaticTreeParallel(): err: %s", s.name, err)
+		}
+		checkStaticTree(t, s.name, types.RFC6962SHA256, static, leaves)
+
+		var buf bytes.Buffer
+		if _, err := static.WriteTo(&buf); err != nil {
+			t.Fatalf("%s: static.WriteTo(): err: %s", s.name, err)
+		}
+		data, _ := want.MarshalBinary()
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Errorf("%s: static.WriteTo() differs from want.MarshalBinary()", s.name)
+		}
+
+		dyn := merkle.NewDynTree(0, merkle.WithHasher(types.RFC6962SHA256), merkle.WithNodeStore(s.new(t)))
+		for _, leaf := range leaves {
+			dyn.Add(leaf)
+		}
+		dyn.Update(500, testutil.RandomHash())
+		dyn.Update(500, leaves[500])
+		if err := dyn.Truncate(700); err != nil {
+			t.Fatalf("%s: dyn.Truncate(): err: %s", s.name, err)
+		}
+		for _, leaf := range leaves[700:] {
+			dyn.Add(leaf)
+		}
+		checkStaticTree(t, s.name, types.RFC6962SHA256, dyn, leaves)
+
+		if got, want := dyn.Root(), want.Root(); !bytes.Equal(got, want) {
+			t.Errorf("%s: dyn.Root() = %x want %x", s.name, got, want)
+		}
+	}
+}
+
+// brokenStore is a node store that fails to read nodes once broken.
+type brokenStore struct {
+	*merkle.MemNodeStore
+	broken bool
+}
+
+var errBrokenStore = errors.New("broken store")
+
+func (s *brokenStore) Get(level, index int) ([]byte, error) {
+	if s.broken {
+		return nil, errBrokenStore
+	}
+	return s.MemNodeStore.Get(level, index)
+}
+
+func TestDynTree_readError(t *testing.T) {
+	store := &brokenStore{MemNodeStore: merkle.NewMemNodeStore(0)}
+	tree := merkle.NewDynTree(0, merkle.WithNodeStore(store))
+	for i := 0; i < 10; i++ {
+		tree.Add(testutil.RandomHash())
+	}
+
+	store.broken = true
+
+	if got := tree.Root(); got != nil {
+		t.Errorf("tree.Root() = %x want nil", got)
+	}
+	if err := tree.Err(); !errors.Is(err, errBrokenStore) {
+		t.Errorf("tree.Err() = %v want %v", err, errBrokenStore)
+	}
+	if _, err := tree.InclusionProof(3, 10); !errors.Is(err, errBrokenStore) {
+		t.Errorf("tree.InclusionProof() err = %v want %v", err, errBrokenStore)
+	}
+	if _, err := tree.ConsistencyProof(3, 10); !errors.Is(err, errBrokenStore) {
+		t.Errorf("tree.ConsistencyProof() err = %v want %v", err, errBrokenStore)
+	}
+	if _, err := tree.MultiProof([]int{1, 2}); !errors.Is(err, errBrokenStore) {
+		t.Errorf("tree.MultiProof() err = %v want %v", err, errBrokenStore)
+	}
+}
+
+func TestMemNodeStore(t *testing.T) {
+	store := merkle.NewMemNodeStore(4)
+
+	if _, err := store.Get(0, 0); err != merkle.ErrNodeNotFound {
+		t.Errorf("store.Get() err = %v want %v", err, merkle.ErrNodeNotFound)
+	}
+
+	nodes := []merkle.StoredNode{
+		{Level: 0, Index: 0, Hash: []byte("a")},
+		{Level: 0, Index: 2, Hash: []byte("c")},
+		{Level: 1, Index: 0, Hash: []byte("ab")},
+	}
+	if err := store.PutBatch(nodes); err != nil {
+		t.Fatalf("store.PutBatch(): err: %s", err)
+	}
+
+	for _, node := range nodes {
+		got, err := store.Get(node.Level, node.Index)
+		if err != nil {
+			t.Fatalf("store.Get(%d, %d): err: %s", node.Level, node.Index, err)
+		}
+		if !bytes.Equal(got, node.Hash) {
+			t.Errorf("store.Get(%d, %d) = %q want %q", node.Level, node.Index, got, node.Hash)
+		}
+	}
+
+	if _, err := store.Get(0, 1); err != merkle.ErrNodeNotFound {
+		t.Errorf("store.Get(0, 1) err = %v want %v", err, merkle.ErrNodeNotFound)
+	}
+
+	if err := store.Truncate(0, 1); err != nil {
+		t.Fatalf("store.Truncate(): err: %s", err)
+	}
+	if _, err := store.Get(0, 2); err != merkle.ErrNodeNotFound {
+		t.Errorf("store.Get(0, 2) err = %v want %v", err, merkle.ErrNodeNotFound)
+	}
+
+	if err := store.Put(-1, 0, []byte("a")); err == nil {
+		t.Error("store.Put(-1, 0): err = nil want Error")
+	}
+}
+
+func TestFileNodeStore_reopen(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "nodes")
+
+	store, err := merkle.OpenFileNodeStore(filename)
+	if err != nil {
+		t.Fatalf("merkle.OpenFileNodeStore(): err: %s", err)
+	}
+
+	hashes := make([][]byte, 10)
+	for i := range hashes {
+		hashes[i] = testutil.RandomHash()
+		if err := store.Put(0, i, hashes[i]); err != nil {
+			t.Fatalf("store.Put(): err: %s", err)
+		}
+	}
+	hashes[3] = testutil.RandomHash()
+	if err := store.Put(0, 3, hashes[3]); err != nil {
+		t.Fatalf("store.Put(): err: %s", err)
+	}
+	if err := store.Truncate(0, 8); err != nil {
+		t.Fatalf("store.Truncate(): err: %s", err)
+	}
+	hashes = hashes[:8]
+	if err := store.Close(); err != nil {
+		t.Fatalf("store.Close(): err: %s", err)
+	}
+
+	store, err = merkle.OpenFileNodeStore(filename)
+	if err != nil {
+		t.Fatalf("merkle.OpenFileNodeStore(): err: %s", err)
+	}
+	defer store.Close()
+
+	for i, want := range hashes {
+		got, err := store.Get(0, i)
+		if err != nil {
+			t.Fatalf("store.Get(0, %d): err: %s", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("store.Get(0, %d) = %x want %x", i, got, want)
+		}
+	}
+	if _, err := store.Get(0, 8); err != merkle.ErrNodeNotFound {
+		t.Errorf("store.Get(0, 8) err = %v want %v", err, merkle.ErrNodeNotFound)
+	}
+}
+
+func TestReopenDynTree(t *testing.T) {
+	for _, h := range hashers {
+		filename := filepath.Join(t.TempDir(), "nodes")
+		store, err := merkle.OpenFileNodeStore(filename)
+		if err != nil {
+			t.Fatalf("merkle.OpenFileNodeStore(): err: %s", err)
+		}
+
+		tree := merkle.NewDynTree(0, merkle.WithHasher(h.hasher), merkle.WithNodeStore(store))
+		leaves := randomChanges(tree, nil, 200)
+		if err := tree.Err(); err != nil {
+			t.Fatalf("%s: tree.Err(): err: %s", h.name, err)
+		}
+		root := tree.Root()
+		store.Close()
+
+		store, err = merkle.OpenFileNodeStore(filename)
+		if err != nil {
+			t.Fatalf("merkle.OpenFileNodeStore(): err: %s", err)
+		}
+		reopened, err := merkle.ReopenDynTree(store, merkle.WithHasher(h.hasher))
+		if err != nil {
+			t.Fatalf("%s: merkle.ReopenDynTree(): err: %s", h.name, err)
+		}
+
+		if got, want := reopened.Root(), root; !bytes.Equal(got, want) {
+			t.Errorf("%s: reopened.Root() = %x want %x", h.name, got, want)
+		}
+		checkStaticTree(t, h.name, h.hasher, reopened, leaves)
+
+		// The reopened tree can be modified like the original one.
+		leaves = randomChanges(reopened, leaves, 50)
+		checkStaticTree(t, h.name, h.hasher, reopened, leaves)
+		store.Close()
+	}
+}
+
+func TestReopenDynTree_repair(t *testing.T) {
+	store := merkle.NewMemNodeStore(0)
+	tree := merkle.NewDynTree(0, merkle.WithNodeStore(store))
+	leaves := randomChanges(tree, nil, 100)
+
+	// Simulate an update of a leaf whose ancestors were not written.
+	leaves[7] = testutil.RandomHash()
+	if err := store.Put(0, 7, leaves[7]); err != nil {
+		t.Fatalf("store.Put(): err: %s", err)
+	}
+
+	reopened, err := merkle.ReopenDynTree(store)
+	if err != nil {
+		t.Fatalf("merkle.ReopenDynTree(): err: %s", err)
+	}
+	checkStaticTree(t, "SHA-256", types.SHA256, reopened, leaves)
+}
+
+func TestFileNodeStore_tornWrite(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "nodes")
+
+	store, err := merkle.OpenFileNodeStore(filename)
+	if err != nil {
+		t.Fatalf("merkle.OpenFileNodeStore(): err: %s", err)
+	}
+
+	want := testutil.RandomHash()
+	if err := store.Put(0, 0, want); err != nil {
+		t.Fatalf("store.Put(): err: %s", err)
+	}
+	if err := store.Put(0, 1, testutil.RandomHash()); err != nil {
+		t.Fatalf("store.Put(): err: %s", err)
+	}
+	store.Close()
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatalf("os.Stat(): err: %s", err)
+	}
+	recordLen := info.Size() / 2
+
+	for cut := int64(1); cut < recordLen; cut++ {
+		t.Run(fmt.Sprint(cut), func(t *testing.T) {
+			if err := os.Truncate(filename, recordLen+cut); err != nil {
+				t.Fatalf("os.Truncate(): err: %s", err)
+			}
+
+			store, err := merkle.OpenFileNodeStore(filename)
+			if err != nil {
+				t.Fatalf("merkle.OpenFileNodeStore(): err: %s", err)
+			}
+			defer store.Close()
+
+			if got, err := store.Get(0, 0); err != nil || !bytes.Equal(got, want) {
+				t.Errorf("store.Get(0, 0) = %x, %v want %x, nil", got, err, want)
+			}
+			if _, err := store.Get(0, 1); err != merkle.ErrNodeNotFound {
+				t.Errorf("store.Get(0, 1) err = %v want %v", err, merkle.ErrNodeNotFound)
+			}
+
+			// The torn node must have been discarded so that new nodes can be
+			// read after reopening the store.
+			if err := store.Put(0, 1, want); err != nil {
+				t.Fatalf("store.Put(): err: %s", err)
+			}
+		})
+	}
+}
+
+func TestFileNodeStore_corruptedNode(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "nodes")
+
+	store, err := merkle.OpenFileNodeStore(filename)
+	if err != nil {
+		t.Fatalf("merkle.OpenFileNodeStore(): err: %s", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := store.Put(0, i, testutil.RandomHash()); err != nil {
+			t.Fatalf("store.Put(): err: %s", err)
+		}
+	}
+	store.Close()
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("ioutil.ReadFile(): err: %s", err)
+	}
+	recordLen := len(data) / 3
+
+	// Each node is made of three one-byte varints, a hash and a checksum.
+	tests := []struct {
+		name    string
+		offset  int
+		corrupt []byte
+	}{
+		{"checksum", recordLen + 10, []byte{data[recordLen+10] ^ 0xff}},
+		{"short hash", recordLen + 2, []byte{16}},
+		{"long hash", recordLen + 2, []byte{0xff, 0xff, 0x7f}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			corrupted := append([]byte(nil), data...)
+			copy(corrupted[tt.offset:], tt.corrupt)
+			if err := ioutil.WriteFile(filename, corrupted, 0644); err != nil {
+				t.Fatalf("ioutil.WriteFile(): err: %s", err)
+			}
+
+			if _, err := merkle.OpenFileNodeStore(filename); !errors.Is(err, merkle.ErrInvalidNodeStoreFile) {
+				t.Errorf("merkle.OpenFileNodeStore() err = %v want %v", err, merkle.ErrInvalidNodeStoreFile)
+			}
+
+			got, err := ioutil.ReadFile(filename)
+			if err != nil {
+				t.Fatalf("ioutil.ReadFile(): err: %s", err)
+			}
+			if !bytes.Equal(got, corrupted) {
+				t.Error("the file was modified")
+			}
+		})
+	}
+
+	// A corrupted last node is discarded like a torn one.
+	corrupted := append([]byte(nil), data...)
+	corrupted[len(corrupted)-1] ^= 0xff
+	if err := ioutil.WriteFile(filename, corrupted, 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile(): err: %s", err)
+	}
+	store, err = merkle.OpenFileNodeStore(filename)
+	if err != nil {
+		t.Fatalf("merkle.OpenFileNodeStore(): err: %s", err)
+	}
+	defer store.Close()
+	if _, err := store.Get(0, 2); err != merkle.ErrNodeNotFound {
+		t.Errorf("store.Get(0, 2) err = %v want %v", err, merkle.ErrNodeNotFound)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkle
+
+import (
+	"hash"
+
+	"github.com/stratumn/merkle/types"
+)
+
+// Option configures a Merkle tree.
+type Option func(*options)
+
+type options struct {
+	hasher          types.Hasher
+	compactInterval int
+	store           NodeStore
+}
+
+// Default minimum number of log records before a persistent DynTree is
+// compacted.
+const defaultCompactInterval = 10000
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		hasher:          types.DefaultHasher,
+		compactInterval: defaultCompactInterval,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithHash sets the hash function used to compute the nodes of the tree.
+// The default is SHA-256.
+func WithHash(newHash func() hash.Hash) Option {
+	return WithHasher(types.NewHasher(newHash))
+}
+
+// WithHasher sets the hasher used to compute the nodes of the tree.
+// The default is types.DefaultHasher.
+func WithHasher(hasher types.Hasher) Option {
+	return func(o *options) {
+		o.hasher = hasher
+	}
+}
+
+// WithCompactInterval sets the minimum number of changes after which a
+// DynTree opened with OpenDynTree is compacted. The tree is only compacted
+// once its log is also at least as large as its snapshot, so that the cost of
+// compacting stays proportional to the number of changes. If it is less than
+// one, the tree is only compacted by calling DynTree.Compact.
+func WithCompactInterval(n int) Option {
+	return func(o *options) {
+		o.compactInterval = n
+	}
+}
+
+// WithNodeStore sets the store of the nodes of the tree. By default, the
+// nodes are kept in memory.
+func WithNodeStore(store NodeStore) Option {
+	return func(o *options) {
+		o.store = store
+	}
+}
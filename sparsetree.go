@@ -0,0 +1,230 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkle
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+
+	"github.com/stratumn/merkle/types"
+)
+
+// sparseTreeHeight is the height of the root of a SparseTree.
+const sparseTreeHeight = types.SparseKeySize * 8
+
+// SparseTree is a sparse Merkle tree that maps keys to values. Unlike
+// StaticTree and DynTree, a leaf is identified by its key, which is a 32-byte
+// hash, instead of its index.
+//
+// The tree has a leaf for every possible key, most of which are empty. Only
+// the nodes that are not the root of an empty subtree are stored.
+type SparseTree struct {
+	mutex  sync.RWMutex
+	hasher types.Hasher
+
+	// defaults[h] is the hash of an empty subtree of height h.
+	defaults [][]byte
+
+	// nodes maps the position of a node to its hash. Nodes that are the root
+	// of an empty subtree are not stored.
+	nodes map[string][]byte
+
+	// values maps keys to values.
+	values map[string][]byte
+}
+
+// NewSparseTree creates an empty SparseTree.
+func NewSparseTree(opts ...Option) *SparseTree {
+	hasher := newOptions(opts).hasher
+
+	defaults := make([][]byte, sparseTreeHeight+1)
+	defaults[0] = types.SparseLeaf(hasher, nil, nil)
+	for h := 1; h <= sparseTreeHeight; h++ {
+		defaults[h] = hasher.HashNode(defaults[h-1], defaults[h-1])
+	}
+
+	return &SparseTree{
+		hasher:   hasher,
+		defaults: defaults,
+		nodes:    map[string][]byte{},
+		values:   map[string][]byte{},
+	}
+}
+
+// Hasher returns the hasher used to compute the nodes.
+func (t *SparseTree) Hasher() types.Hasher {
+	return t.hasher
+}
+
+// Len returns the number of keys in the tree.
+func (t *SparseTree) Len() int {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return len(t.values)
+}
+
+// Root returns the Merkle root.
+func (t *SparseTree) Root() []byte {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return t.node(sparseTreeHeight, nil)
+}
+
+// Get returns a copy of the value of a key, or nil if the key is not in the
+// tree.
+func (t *SparseTree) Get(key []byte) ([]byte, error) {
+	if len(key) != types.SparseKeySize {
+		return nil, types.ErrInvalidKey
+	}
+
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	value, ok := t.values[string(key)]
+	if !ok {
+		return nil, nil
+	}
+
+	return append([]byte{}, value...), nil
+}
+
+// Set sets the value of a key. The value must not be nil. The tree keeps a
+// copy of the value, so it can be modified afterwards.
+func (t *SparseTree) Set(key, value []byte) error {
+	if len(key) != types.SparseKeySize {
+		return types.ErrInvalidKey
+	}
+	if value == nil {
+		return errors.New("value should not be nil")
+	}
+
+	value = append([]byte{}, value...)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.values[string(key)] = value
+	t.update(key, types.SparseLeaf(t.hasher, key, value))
+
+	return nil
+}
+
+// Delete removes a key from the tree.
+func (t *SparseTree) Delete(key []byte) error {
+	if len(key) != types.SparseKeySize {
+		return types.ErrInvalidKey
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.values, string(key))
+	t.update(key, t.defaults[0])
+
+	return nil
+}
+
+// Path returns the path of the leaf of a key to the Merkle root. If the key
+// is in the tree, the path proves its membership, otherwise it proves its
+// non-membership. See types.VerifySparse.
+func (t *SparseTree) Path(key []byte) (types.Path, error) {
+	if len(key) != types.SparseKeySize {
+		return nil, types.ErrInvalidKey
+	}
+
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	path := make(types.Path, sparseTreeHeight)
+
+	for h := range path {
+		var (
+			node    = t.node(h, key)
+			sibling = t.node(h, siblingKey(key, h))
+		)
+		if types.SparseKeyBit(key, h) {
+			path[h].Left, path[h].Right = sibling, node
+		} else {
+			path[h].Left, path[h].Right = node, sibling
+		}
+		path[h].Parent = t.node(h+1, key)
+	}
+
+	return path, nil
+}
+
+// Sets the leaf of a key and recomputes the nodes above it.
+func (t *SparseTree) update(key, leaf []byte) {
+	node := leaf
+
+	for h := 0; h < sparseTreeHeight; h++ {
+		t.setNode(h, key, node)
+
+		sibling := t.node(h, siblingKey(key, h))
+		if types.SparseKeyBit(key, h) {
+			node = t.hasher.HashNode(sibling, node)
+		} else {
+			node = t.hasher.HashNode(node, sibling)
+		}
+	}
+
+	t.setNode(sparseTreeHeight, key, node)
+}
+
+// Returns the hash of the node at the given height on the path of the key.
+func (t *SparseTree) node(height int, key []byte) []byte {
+	if hash, ok := t.nodes[nodeKey(height, key)]; ok {
+		return hash
+	}
+	return t.defaults[height]
+}
+
+// Sets the hash of the node at the given height on the path of the key.
+func (t *SparseTree) setNode(height int, key, hash []byte) {
+	if bytes.Equal(hash, t.defaults[height]) {
+		delete(t.nodes, nodeKey(height, key))
+	} else {
+		t.nodes[nodeKey(height, key)] = hash
+	}
+}
+
+// Returns the map key of the node at the given height on the path of the key,
+// which is the height followed by the bits of the key above that height.
+func nodeKey(height int, key []byte) string {
+	var (
+		bits = sparseTreeHeight - height
+		buf  = make([]byte, 2+(bits+7)/8)
+	)
+
+	buf[0], buf[1] = byte(height>>8), byte(height)
+	copy(buf[2:], key)
+	if bits%8 > 0 {
+		buf[len(buf)-1] &= 0xff << uint(8-bits%8)
+	}
+
+	return string(buf)
+}
+
+// Returns a key whose path has the sibling of the node at the given height on
+// the path of the key.
+func siblingKey(key []byte, height int) []byte {
+	bit := sparseTreeHeight - 1 - height
+	sibling := append([]byte(nil), key...)
+	sibling[bit/8] ^= 0x80 >> uint(bit%8)
+	return sibling
+}
@@ -0,0 +1,186 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkle_test
+
+import (
+	"encoding/hex"
+	"math/rand"
+	"testing"
+
+	"github.com/stratumn/merkle"
+	"github.com/stratumn/merkle/testutil"
+	"github.com/stratumn/merkle/types"
+)
+
+func TestSparseTree(t *testing.T) {
+	for _, h := range hashers {
+		var (
+			tree   = merkle.NewSparseTree(merkle.WithHasher(h.hasher))
+			empty  = tree.Root()
+			values = map[string][]byte{}
+		)
+
+		for i := 0; i < 50; i++ {
+			key, value := testutil.RandomHash(), testutil.RandomHash()
+			values[string(key)] = value
+			if err := tree.Set(key, value); err != nil {
+				t.Fatalf("%s: tree.Set(): err: %s", h.name, err)
+			}
+		}
+
+		if got, want := tree.Len(), len(values); got != want {
+			t.Errorf("%s: tree.Len() = %d want %d", h.name, got, want)
+		}
+
+		root := tree.Root()
+
+		for key, value := range values {
+			got, err := tree.Get([]byte(key))
+			if err != nil {
+				t.Fatalf("%s: tree.Get(): err: %s", h.name, err)
+			}
+			if hex.EncodeToString(got) != hex.EncodeToString(value) {
+				t.Errorf("%s: tree.Get() = %x want %x", h.name, got, value)
+			}
+
+			path, err := tree.Path([]byte(key))
+			if err != nil {
+				t.Fatalf("%s: tree.Path(): err: %s", h.name, err)
+			}
+			if err := types.VerifySparseWith(h.hasher, []byte(key), value, root, path); err != nil {
+				t.Errorf("%s: types.VerifySparseWith(): err: %s", h.name, err)
+			}
+			if err := types.VerifySparseWith(h.hasher, []byte(key), nil, root, path); err != types.ErrLeafMismatch {
+				t.Errorf("%s: types.VerifySparseWith(nil): err = %v want %v", h.name, err, types.ErrLeafMismatch)
+			}
+		}
+
+		missing := testutil.RandomHash()
+		path, err := tree.Path(missing)
+		if err != nil {
+			t.Fatalf("%s: tree.Path(): err: %s", h.name, err)
+		}
+		if err := types.VerifySparseWith(h.hasher, missing, nil, root, path); err != nil {
+			t.Errorf("%s: types.VerifySparseWith(nil): err: %s", h.name, err)
+		}
+		if err := types.VerifySparseWith(h.hasher, missing, testutil.RandomHash(), root, path); err != types.ErrLeafMismatch {
+			t.Errorf("%s: types.VerifySparseWith(): err = %v want %v", h.name, err, types.ErrLeafMismatch)
+		}
+
+		for key := range values {
+			if err := tree.Delete([]byte(key)); err != nil {
+				t.Fatalf("%s: tree.Delete(): err: %s", h.name, err)
+			}
+		}
+
+		if got, want := hex.EncodeToString(tree.Root()), hex.EncodeToString(empty); got != want {
+			t.Errorf("%s: tree.Root() = %q want %q", h.name, got, want)
+		}
+		if got, want := tree.Len(), 0; got != want {
+			t.Errorf("%s: tree.Len() = %d want %d", h.name, got, want)
+		}
+	}
+}
+
+func TestSparseTree_order(t *testing.T) {
+	var (
+		keys   = make([][]byte, 100)
+		values = make([][]byte, len(keys))
+		t1     = merkle.NewSparseTree()
+		t2     = merkle.NewSparseTree()
+	)
+
+	for i := range keys {
+		keys[i], values[i] = testutil.RandomHash(), testutil.RandomHash()
+		if err := t1.Set(keys[i], values[i]); err != nil {
+			t.Fatalf("t1.Set(): err: %s", err)
+		}
+	}
+
+	for _, i := range rand.Perm(len(keys)) {
+		if err := t2.Set(keys[i], testutil.RandomHash()); err != nil {
+			t.Fatalf("t2.Set(): err: %s", err)
+		}
+		if err := t2.Set(keys[i], values[i]); err != nil {
+			t.Fatalf("t2.Set(): err: %s", err)
+		}
+	}
+
+	if got, want := hex.EncodeToString(t2.Root()), hex.EncodeToString(t1.Root()); got != want {
+		t.Errorf("t2.Root() = %q want %q", got, want)
+	}
+}
+
+func TestSparseTree_copy(t *testing.T) {
+	var (
+		tree  = merkle.NewSparseTree()
+		key   = testutil.RandomHash()
+		value = []byte("value")
+	)
+
+	if err := tree.Set(key, value); err != nil {
+		t.Fatalf("tree.Set(): err: %s", err)
+	}
+	value[0] = 'x'
+
+	got, err := tree.Get(key)
+	if err != nil {
+		t.Fatalf("tree.Get(): err: %s", err)
+	}
+	if string(got) != "value" {
+		t.Errorf("tree.Get() = %q want %q", got, "value")
+	}
+
+	got[0] = 'x'
+	if got, _ := tree.Get(key); string(got) != "value" {
+		t.Errorf("tree.Get() = %q want %q", got, "value")
+	}
+}
+
+func TestSparseTree_Error(t *testing.T) {
+	tree := merkle.NewSparseTree()
+	key := testutil.RandomHash()
+
+	if err := tree.Set(key[:31], key); err != types.ErrInvalidKey {
+		t.Errorf("tree.Set(): err = %v want %v", err, types.ErrInvalidKey)
+	}
+	if err := tree.Set(key, nil); err == nil {
+		t.Error("tree.Set(): err = nil want Error")
+	}
+	if _, err := tree.Get(key[:31]); err != types.ErrInvalidKey {
+		t.Errorf("tree.Get(): err = %v want %v", err, types.ErrInvalidKey)
+	}
+	if err := tree.Delete(key[:31]); err != types.ErrInvalidKey {
+		t.Errorf("tree.Delete(): err = %v want %v", err, types.ErrInvalidKey)
+	}
+	if _, err := tree.Path(key[:31]); err != types.ErrInvalidKey {
+		t.Errorf("tree.Path(): err = %v want %v", err, types.ErrInvalidKey)
+	}
+}
+
+func BenchmarkSparseTreeSet(b *testing.B) {
+	tree := merkle.NewSparseTree()
+
+	keys := make([][]byte, b.N)
+	for i := range keys {
+		keys[i] = testutil.RandomHash()
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		tree.Set(keys[i], keys[i])
+	}
+}
@@ -15,9 +15,11 @@
 package merkle
 
 import (
-	"crypto/sha256"
 	"errors"
+	"fmt"
 	"math"
+	"runtime"
+	"sync"
 
 	"github.com/stratumn/merkle/types"
 )
@@ -36,52 +38,162 @@ type StaticTree struct {
 	//     / \ / \   \
 	// 3   A B C D   E
 	//
-	// The buffer will contain {I,H,F,G,A,B,C,D,E}.
-	buffer [][]byte
+	// The buffer will contain the bytes of I,H,F,G,A,B,C,D,E.
+	buffer []byte
 
 	// These slices map rows to the buffer.
 	// For instance, given the tree above:
-	// rows[0] = {I}
-	// rows[1] = {H}
-	// rows[2] = {F,G}
-	// rows[3] = {A,B,C,D,E}
-	rows [][][]byte
+	// rows[0] = I
+	// rows[1] = H
+	// rows[2] = FG
+	// rows[3] = ABCDE
+	rows [][]byte
+
+	// The number of nodes of each row.
+	lens []int
+
+	// The size of a leaf and of the other nodes, which may differ if the
+	// leaves are not hashed. The size of a leaf is zero if the leaves do not
+	// all have the same size.
+	leafSize int
+	nodeSize int
+
+	// The leaves if they do not all have the same size, in which case the
+	// buffer only contains the other nodes.
+	leaves [][]byte
+
+	hasher types.Hasher
+
+	// The memory mapped file containing the buffer, if any.
+	mapping []byte
+
+	// The store containing the hashes instead of the buffer, if any.
+	store NodeStore
 }
 
 // NewStaticTree creates a static Merkle tree from a slice of leaves.
-func NewStaticTree(leaves [][]byte) (*StaticTree, error) {
+// Leaves are hashed using the hasher of the tree, which by default leaves
+// them unchanged. If the leaves do not all have the same size, they are kept
+// apart from the buffer of the other nodes, and the tree cannot be encoded
+// using WriteTo.
+//
+// If a node store is given, the hashes are written to the store instead of
+// being kept in memory.
+func NewStaticTree(leaves [][]byte, opts ...Option) (*StaticTree, error) {
+	tree, err := newStaticTree(leaves, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if tree.store != nil {
+		return tree, tree.computeStore(leaves, 1)
+	}
+
+	err = tree.copyLeaves(leaves)
+	if err == errLeafSize {
+		tree.separateLeaves(leaves)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := tree.compute(); err != nil {
+		return nil, err
+	}
+
+	return tree, nil
+}
+
+// NewStaticTreeParallel creates a static Merkle tree from a slice of leaves
+// like NewStaticTree, but shards the hashing of each row across the given
+// number of goroutines. If workers is less than one, the number of CPUs is
+// used. The resulting tree is identical to the one built by NewStaticTree.
+func NewStaticTreeParallel(leaves [][]byte, workers int, opts ...Option) (*StaticTree, error) {
+	tree, err := newStaticTree(leaves, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	if tree.store != nil {
+		return tree, tree.computeStore(leaves, workers)
+	}
+
+	err = parallelize(len(leaves), workers, func(start, end int) error {
+		return tree.copyLeavesRange(leaves, start, end)
+	})
+	if err == errLeafSize {
+		tree.separateLeaves(leaves)
+	} else if err != nil {
+		return nil, err
+	}
+
+	for row := len(tree.lens) - 2; row >= 0; row-- {
+		r := row
+		err := parallelize(tree.lens[r], workers, func(start, end int) error {
+			return tree.computeRow(r, start, end)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tree, nil
+}
+
+func newStaticTree(leaves [][]byte, opts []Option) (*StaticTree, error) {
 	numLeaves := len(leaves)
 	if numLeaves < 1 {
 		return nil, errors.New("tree should have at least one leaf")
 	}
 
-	tree := alloc(numLeaves)
-	tree.copyLeaves(leaves)
-	tree.compute()
+	var (
+		o        = newOptions(opts)
+		leafSize = len(o.hasher.HashLeaf(leaves[0]))
+		nodeSize = o.hasher.Size()
+	)
+
+	if o.store != nil {
+		return &StaticTree{
+			lens:     staticTreeRowsLen(numLeaves),
+			leafSize: leafSize,
+			nodeSize: nodeSize,
+			hasher:   o.hasher,
+			store:    o.store,
+		}, nil
+	}
+
+	tree := alloc(numLeaves, leafSize, nodeSize)
+	tree.hasher = o.hasher
 
 	return tree, nil
 }
 
+// Hasher returns the hasher used to compute the nodes.
+func (t *StaticTree) Hasher() types.Hasher {
+	return t.hasher
+}
+
 // LeavesLen returns the number of leaves. Implements Tree.LeavesLen.
 func (t *StaticTree) LeavesLen() int {
-	return len(t.rows[len(t.rows)-1])
+	return t.lens[len(t.lens)-1]
 }
 
 // Root returns the Merkle root. Implements Tree.Root.
 func (t *StaticTree) Root() []byte {
-	r := t.buffer[0]
-	return r
+	return t.node(0, 0)
 }
 
 // Leaf returns the leaf at the specified index. Implements Tree.Leaf.
 func (t *StaticTree) Leaf(index int) []byte {
-	l := t.rows[len(t.rows)-1][index]
-	return l
+	return t.node(len(t.lens)-1, index)
 }
 
 // Path returns the path of a leaf to the Merkle root. Implements Tree.Path.
 func (t *StaticTree) Path(index int) types.Path {
-	row := len(t.rows) - 1
+	row := len(t.lens) - 1
 	if row < 0 {
 		return types.Path{}
 	}
@@ -101,49 +213,243 @@ func (t *StaticTree) Path(index int) types.Path {
 	return path[:depth]
 }
 
+// MultiProof returns a proof of the inclusion of the leaves at the specified
+// indices, without the hashes that can be computed from the leaves.
+func (t *StaticTree) MultiProof(indices []int) (*types.MultiProof, error) {
+	return types.NewMultiProof(indices, t.LeavesLen(), t.Path)
+}
+
 // Allocates memory for the buffer and creates the row slices that map to the
 // buffer.
-func alloc(numLeaves int) *StaticTree {
+func alloc(numLeaves, leafSize, nodeSize int) *StaticTree {
+	tree := &StaticTree{
+		buffer:   make([]byte, staticTreeBufferLen(numLeaves, leafSize, nodeSize)),
+		lens:     staticTreeRowsLen(numLeaves),
+		leafSize: leafSize,
+		nodeSize: nodeSize,
+	}
+	tree.mapRows()
+
+	return tree
+}
+
+// Creates the row slices that map to the buffer.
+func (t *StaticTree) mapRows() {
 	var (
-		bufl    = numStaticTreeNodes(numLeaves)
-		buf     = make([][]byte, bufl)
-		rowsLen = staticTreeRowsLen(numLeaves)
-		depth   = len(rowsLen)
-		tree    = &StaticTree{buf, make([][][]byte, depth)}
-		start   = 0
-		end     = 0
+		start = 0
+		end   = 0
 	)
 
-	for i, l := range rowsLen {
-		end = start + l
-		tree.rows[i] = tree.buffer[start:end]
+	t.rows = make([][]byte, len(t.lens))
+	for i, l := range t.lens {
+		end = start + l*t.slotSize(i)
+		t.rows[i] = t.buffer[start:end]
 		start = end
 	}
+}
 
-	return tree
+// Copies the hashes of the leaves at the end of the buffer.
+func (t *StaticTree) copyLeaves(leaves [][]byte) error {
+	return t.copyLeavesRange(leaves, 0, len(leaves))
 }
 
-// Copies the leaves at the end of the buffer.
-func (t *StaticTree) copyLeaves(leaves [][]byte) {
-	copy(t.rows[len(t.rows)-1], leaves)
+// Copies the hashes of the leaves from start to end (exclusive). It returns
+// errLeafSize if a leaf does not have the size of the first one.
+func (t *StaticTree) copyLeavesRange(leaves [][]byte, start, end int) error {
+	row := len(t.lens) - 1
+	for i := start; i < end; i++ {
+		leaf := t.hasher.HashLeaf(leaves[i])
+		if len(leaf) != t.leafSize {
+			return errLeafSize
+		}
+		t.write(leaf, row, i)
+	}
+	return nil
+}
+
+// errLeafSize is returned when the leaves do not all have the same size.
+var errLeafSize = errors.New("leaves should have the same size")
+
+// Keeps the hashes of the leaves apart from the buffer, which then only
+// contains the other nodes, for leaves that do not all have the same size.
+func (t *StaticTree) separateLeaves(leaves [][]byte) {
+	t.leaves = make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		t.leaves[i] = append([]byte(nil), t.hasher.HashLeaf(leaf)...)
+	}
+
+	t.leafSize = 0
+	t.buffer = t.buffer[:staticTreeBufferLen(len(leaves), 0, t.nodeSize)]
+	t.mapRows()
 }
 
 // Computes all the hashes. Assumes that the leaves have been copied to the
 // buffer.
-func (t *StaticTree) compute() {
-	hash := sha256.New()
-	for row := len(t.rows) - 2; row >= 0; row-- {
-		rowLen := len(t.rows[row])
-		for col := 0; col < rowLen; col++ {
-			r, c := t.dleft(row, col)
-			// Write never returns an error.
-			hash.Write(t.rows[r][c])
-			r, c = t.dright(row, col)
-			hash.Write(t.rows[r][c])
-			t.write(hash.Sum(nil), row, col)
-			hash.Reset()
+func (t *StaticTree) compute() error {
+	for row := len(t.lens) - 2; row >= 0; row-- {
+		if err := t.computeRow(row, 0, t.lens[row]); err != nil {
+			return err
 		}
 	}
+	return nil
+}
+
+// Computes the hashes of a row from start to end (exclusive). Assumes that the
+// rows below have been computed. Hashes are written directly to the buffer if
+// the hasher supports it. It fails if the hasher returns a hash whose size is
+// not the size of the hasher.
+func (t *StaticTree) computeRow(row, start, end int) error {
+	appender, ok := t.hasher.(types.NodeAppender)
+
+	for col := start; col < end; col++ {
+		var (
+			lr, lc = t.dleft(row, col)
+			rr, rc = t.dright(row, col)
+			left   = t.node(lr, lc)
+			right  = t.node(rr, rc)
+			hash   []byte
+		)
+		if ok {
+			hash = appender.AppendNode(t.node(row, col)[:0], left, right)
+		} else {
+			hash = t.hasher.HashNode(left, right)
+		}
+		if err := t.checkNodeSize(hash); err != nil {
+			return err
+		}
+
+		// This is a no-op if the hash was appended to the buffer.
+		t.write(hash, row, col)
+	}
+
+	return nil
+}
+
+// Checks that a node computed by the hasher has the size of the hasher.
+func (t *StaticTree) checkNodeSize(hash []byte) error {
+	if len(hash) != t.nodeSize {
+		return fmt.Errorf("hasher returned a node of %d bytes instead of %d", len(hash), t.nodeSize)
+	}
+	return nil
+}
+
+// Computes all the hashes and writes them to the store one level at a time,
+// using the layout described by NodeStore.
+func (t *StaticTree) computeStore(leaves [][]byte, workers int) error {
+	level := make([][]byte, len(leaves))
+
+	parallelize(len(leaves), workers, func(start, end int) error {
+		for i := start; i < end; i++ {
+			level[i] = t.hasher.HashLeaf(leaves[i])
+		}
+		return nil
+	})
+
+	for _, leaf := range level {
+		if len(leaf) != t.leafSize {
+			t.leafSize = 0
+			break
+		}
+	}
+
+	if err := t.putLevel(0, level); err != nil {
+		return err
+	}
+
+	for height := 1; len(level) > 1; height++ {
+		var (
+			below = level
+			next  = make([][]byte, (len(below)+1)/2)
+		)
+
+		err := parallelize(len(next), workers, func(start, end int) error {
+			for i := start; i < end; i++ {
+				if 2*i+1 >= len(below) {
+					next[i] = below[2*i]
+					continue
+				}
+				next[i] = t.hasher.HashNode(below[2*i], below[2*i+1])
+				if err := t.checkNodeSize(next[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := t.putLevel(height, next); err != nil {
+			return err
+		}
+
+		level = next
+	}
+
+	return nil
+}
+
+// Writes the hashes of a level to the store in batches.
+func (t *StaticTree) putLevel(level int, hashes [][]byte) error {
+	nodes := make([]StoredNode, 0, dynTreeBatchLen)
+
+	for i, hash := range hashes {
+		nodes = append(nodes, StoredNode{Level: level, Index: i, Hash: hash})
+		if len(nodes) == cap(nodes) || i == len(hashes)-1 {
+			if err := t.store.PutBatch(nodes); err != nil {
+				return err
+			}
+			nodes = nodes[:0]
+		}
+	}
+
+	return nil
+}
+
+// Minimum number of nodes hashed by a goroutine when building a tree in
+// parallel, below which the cost of goroutines outweighs the gain.
+const minParallelNodes = 256
+
+// Splits the range from zero to n (exclusive) in at most the given number of
+// contiguous shards, calls fn concurrently for each shard and waits for all
+// the calls to return. It returns the first error returned by fn, if any.
+func parallelize(n, workers int, fn func(start, end int) error) error {
+	if shards := n / minParallelNodes; shards < workers {
+		workers = shards
+	}
+
+	if workers < 2 {
+		return fn(0, n)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		shardSize = (n + workers - 1) / workers
+		errs      = make([]error, workers)
+	)
+
+	for i := 0; i*shardSize < n; i++ {
+		start, end := i*shardSize, (i+1)*shardSize
+		if end > n {
+			end = n
+		}
+
+		wg.Add(1)
+		go func(i, start, end int) {
+			defer wg.Done()
+			errs[i] = fn(start, end)
+		}(i, start, end)
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // Computes the values of a hash triplet for given row and column.
@@ -166,13 +472,43 @@ func (t *StaticTree) triplet(triplet *types.MerkleNodeHashes, row, col int) {
 
 // Reads the hash for given row and column.
 func (t *StaticTree) read(dst *[]byte, row, col int) {
-	*dst = t.rows[row][col]
+	*dst = t.node(row, col)
 }
 
 // Writes the hash for given row and column.
 func (t *StaticTree) write(src []byte, row, col int) {
-	t.rows[row][col] = make([]byte, len(src), len(src))
-	copy(t.rows[row][col], src)
+	copy(t.node(row, col), src)
+}
+
+// Returns the slice of the buffer containing the hash for given row and
+// column. Its capacity is limited so that appending to it cannot overwrite
+// other hashes. If the tree uses a node store, the hash is read from the
+// store, whose levels are the rows in reverse order.
+func (t *StaticTree) node(row, col int) []byte {
+	if t.store != nil {
+		hash, err := t.store.Get(len(t.lens)-1-row, col)
+		if err != nil {
+			panic(fmt.Sprintf("merkle: cannot read node %d at level %d: %s", col, len(t.lens)-1-row, err))
+		}
+		return hash
+	}
+
+	if t.leaves != nil && row == len(t.lens)-1 {
+		leaf := t.leaves[col]
+		return leaf[:len(leaf):len(leaf)]
+	}
+
+	size := t.slotSize(row)
+	start := col * size
+	return t.rows[row][start : start+size : start+size]
+}
+
+// Returns the size of the hashes of given row.
+func (t *StaticTree) slotSize(row int) int {
+	if row == len(t.lens)-1 {
+		return t.leafSize
+	}
+	return t.nodeSize
 }
 
 // Returns the position of the node to the left of given row and column.
@@ -205,7 +541,7 @@ func (t *StaticTree) right(row, col int) (int, int) {
 func (t *StaticTree) parent(row, col int) (int, int) {
 	r, c := row-1, col/2
 	for r >= 0 {
-		if c < len(t.rows[r]) {
+		if c < t.lens[r] {
 			return r, c
 		}
 		r, c = r-1, c/2
@@ -215,7 +551,7 @@ func (t *StaticTree) parent(row, col int) (int, int) {
 
 // Returns the position of the left child node of given row and column.
 func (t *StaticTree) dleft(row, col int) (int, int) {
-	if row >= len(t.rows) {
+	if row >= len(t.lens) {
 		return -1, -1
 	}
 	return row + 1, col * 2
@@ -224,8 +560,8 @@ func (t *StaticTree) dleft(row, col int) (int, int) {
 // Returns the position of the right child node of given row and column.
 func (t *StaticTree) dright(row, col int) (int, int) {
 	r, c := row+1, col*2+1
-	for r < len(t.rows) {
-		if c < len(t.rows[r]) {
+	for r < len(t.lens) {
+		if c < t.lens[r] {
 			return r, c
 		}
 		r, c = r+1, c*2 // Note no plus one (orphan)!
@@ -238,6 +574,12 @@ func numStaticTreeNodes(numLeaves int) int {
 	return numLeaves*2 - 1
 }
 
+// Returns the number of bytes of the buffer needed for the given number of
+// leaves and sizes of hashes.
+func staticTreeBufferLen(numLeaves, leafSize, nodeSize int) int {
+	return (numStaticTreeNodes(numLeaves)-numLeaves)*nodeSize + numLeaves*leafSize
+}
+
 // Returns the length of each tree row needed for the given number of leaves.
 func staticTreeRowsLen(numLeaves int) []int {
 	var (
@@ -0,0 +1,254 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkle
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/stratumn/merkle/types"
+)
+
+// The binary encoding of a StaticTree is made of:
+//
+//   - the magic string "MKST",
+//   - a version byte,
+//   - the length of the name of the hasher as an unsigned varint, followed by
+//     the name under which the hasher is registered,
+//   - the number of leaves, the size of a leaf and the size of the other
+//     nodes as unsigned varints,
+//   - the hashes of the tree top down, as they are stored in its buffer,
+//   - the big-endian CRC-32C of all the previous bytes.
+const (
+	staticTreeMagic   = "MKST"
+	staticTreeVersion = 1
+)
+
+var (
+	// ErrInvalidStaticTree is returned when loading data that is not a valid
+	// encoding of a StaticTree.
+	ErrInvalidStaticTree = errors.New("invalid static tree encoding")
+
+	// ErrStaticTreeChecksum is returned when loading a StaticTree whose
+	// checksum does not match its content.
+	ErrStaticTreeChecksum = errors.New("static tree checksum mismatch")
+
+	crc32c = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// WriteTo writes the binary encoding of the tree to a writer. The hasher of
+// the tree must be registered using types.RegisterHasher, and the leaves must
+// all have the same size. Implements io.WriterTo.
+func (t *StaticTree) WriteTo(w io.Writer) (int64, error) {
+	name, err := types.HasherName(t.hasher)
+	if err != nil {
+		return 0, err
+	}
+	if t.leafSize == 0 {
+		return 0, errors.New("cannot encode leaves of different sizes")
+	}
+
+	header := append([]byte(staticTreeMagic), staticTreeVersion)
+	header = binary.AppendUvarint(header, uint64(len(name)))
+	header = append(header, name...)
+	header = binary.AppendUvarint(header, uint64(t.LeavesLen()))
+	header = binary.AppendUvarint(header, uint64(t.leafSize))
+	header = binary.AppendUvarint(header, uint64(t.nodeSize))
+
+	var (
+		crc   = crc32.New(crc32c)
+		mw    = io.MultiWriter(w, crc)
+		total int64
+	)
+
+	n, err := mw.Write(header)
+	total += int64(n)
+	if err != nil {
+		return total, err
+	}
+
+	if t.store == nil {
+		n, err = mw.Write(t.buffer)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	} else {
+		// Write the hashes from the store in the order of the buffer.
+		bw := bufio.NewWriter(mw)
+		for row, l := range t.lens {
+			for col := 0; col < l; col++ {
+				n, err := bw.Write(t.node(row, col))
+				total += int64(n)
+				if err != nil {
+					return total, err
+				}
+			}
+		}
+		if err := bw.Flush(); err != nil {
+			return total, err
+		}
+	}
+
+	n, err = w.Write(crc.Sum(nil))
+	total += int64(n)
+
+	return total, err
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.MarshalBinary.
+func (t *StaticTree) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := t.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.UnmarshalBinary.
+func (t *StaticTree) UnmarshalBinary(data []byte) error {
+	tree, err := decodeStaticTree(append([]byte(nil), data...))
+	if err != nil {
+		return err
+	}
+	*t = *tree
+	return nil
+}
+
+// ReadStaticTree reads the binary encoding of a tree from a reader.
+func ReadStaticTree(r io.Reader) (*StaticTree, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return decodeStaticTree(data)
+}
+
+// OpenStaticTree loads a tree from a file containing its binary encoding. If
+// mmap is true and the platform supports it, the file is memory mapped instead
+// of being copied to memory, and the hashes returned by the tree must not be
+// modified. The tree should be closed when it is no longer used.
+func OpenStaticTree(filename string, mmap bool) (*StaticTree, error) {
+	if !mmap {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		return decodeStaticTree(data)
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := mmapFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := decodeStaticTree(data)
+	if err != nil {
+		munmap(data)
+		return nil, err
+	}
+	tree.mapping = data
+
+	return tree, nil
+}
+
+// Close releases the memory mapped file of a tree loaded by OpenStaticTree.
+// It does nothing for other trees. The tree must not be used after it is
+// closed.
+func (t *StaticTree) Close() error {
+	if t.mapping == nil {
+		return nil
+	}
+
+	err := munmap(t.mapping)
+	t.mapping, t.buffer, t.rows = nil, nil, nil
+
+	return err
+}
+
+// Decodes a tree whose buffer is a slice of data.
+func decodeStaticTree(data []byte) (*StaticTree, error) {
+	if len(data) < len(staticTreeMagic)+1+crc32.Size || string(data[:len(staticTreeMagic)]) != staticTreeMagic {
+		return nil, ErrInvalidStaticTree
+	}
+	if data[len(staticTreeMagic)] != staticTreeVersion {
+		return nil, errors.New("unsupported static tree version")
+	}
+
+	body := data[:len(data)-crc32.Size]
+	if crc32.Checksum(body, crc32c) != binary.BigEndian.Uint32(data[len(body):]) {
+		return nil, ErrStaticTreeChecksum
+	}
+	body = body[len(staticTreeMagic)+1:]
+
+	nameLen, n := binary.Uvarint(body)
+	if n <= 0 || nameLen > uint64(len(body)-n) {
+		return nil, ErrInvalidStaticTree
+	}
+	name := string(body[n : n+int(nameLen)])
+	body = body[n+int(nameLen):]
+
+	var fields [3]uint64
+	for i := range fields {
+		v, n := binary.Uvarint(body)
+		if n <= 0 {
+			return nil, ErrInvalidStaticTree
+		}
+		fields[i], body = v, body[n:]
+	}
+
+	hasher, err := types.HasherByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	numLeaves, leafSize, nodeSize := fields[0], fields[1], fields[2]
+	if nodeSize != uint64(hasher.Size()) {
+		return nil, errors.New("node size does not match the hasher")
+	}
+
+	// Check the length of the buffer without overflowing.
+	remaining := uint64(len(body))
+	if numLeaves < 1 || leafSize < 1 || numLeaves > remaining/leafSize {
+		return nil, ErrInvalidStaticTree
+	}
+	remaining -= numLeaves * leafSize
+	if (numLeaves-1)*nodeSize != remaining || numLeaves-1 > remaining/nodeSize {
+		return nil, ErrInvalidStaticTree
+	}
+
+	tree := &StaticTree{
+		buffer:   body,
+		lens:     staticTreeRowsLen(int(numLeaves)),
+		leafSize: int(leafSize),
+		nodeSize: int(nodeSize),
+		hasher:   hasher,
+	}
+	tree.mapRows()
+
+	return tree, nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkle_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stratumn/merkle"
+	"github.com/stratumn/merkle/testutil"
+	"github.com/stratumn/merkle/treetestcases"
+	"github.com/stratumn/merkle/types"
+)
+
+func TestStaticTree_MarshalBinary(t *testing.T) {
+	for _, h := range hashers {
+		hasher := h.hasher
+		t.Run(h.name, treetestcases.Factory{
+			New: func(leaves [][]byte) (merkle.Tree, error) {
+				tree, err := merkle.NewStaticTree(leaves, merkle.WithHasher(hasher))
+				if err != nil {
+					return nil, err
+				}
+
+				data, err := tree.MarshalBinary()
+				if err != nil {
+					return nil, err
+				}
+
+				var loaded merkle.StaticTree
+				if err := loaded.UnmarshalBinary(data); err != nil {
+					return nil, err
+				}
+
+				return &loaded, nil
+			},
+			Hasher: hasher,
+		}.RunTests)
+	}
+}
+
+func TestOpenStaticTree(t *testing.T) {
+	leaves := make([][]byte, 1000)
+	for i := range leaves {
+		leaves[i] = testutil.RandomHash()
+	}
+
+	tree, err := merkle.NewStaticTree(leaves, merkle.WithHasher(types.RFC6962SHA256))
+	if err != nil {
+		t.Fatalf("merkle.NewStaticTree(): err: %s", err)
+	}
+
+	filename := filepath.Join(t.TempDir(), "tree")
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatalf("os.Create(): err: %s", err)
+	}
+	if _, err := tree.WriteTo(f); err != nil {
+		t.Fatalf("tree.WriteTo(): err: %s", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("f.Close(): err: %s", err)
+	}
+
+	for _, mmap := range []bool{false, true} {
+		loaded, err := merkle.OpenStaticTree(filename, mmap)
+		if err != nil {
+			t.Fatalf("merkle.OpenStaticTree(%v): err: %s", mmap, err)
+		}
+
+		if got, want := loaded.Hasher(), types.RFC6962SHA256; got != want {
+			t.Errorf("loaded.Hasher() = %v want %v", got, want)
+		}
+		checkStaticTree(t, "RFC6962-SHA-256", types.RFC6962SHA256, loaded, leaves)
+
+		if err := loaded.Close(); err != nil {
+			t.Errorf("loaded.Close(): err: %s", err)
+		}
+	}
+}
+
+func TestReadStaticTree(t *testing.T) {
+	tree, err := merkle.NewStaticTree([][]byte{testutil.RandomHash()})
+	if err != nil {
+		t.Fatalf("merkle.NewStaticTree(): err: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := tree.WriteTo(&buf); err != nil {
+		t.Fatalf("tree.WriteTo(): err: %s", err)
+	}
+
+	loaded, err := merkle.ReadStaticTree(&buf)
+	if err != nil {
+		t.Fatalf("merkle.ReadStaticTree(): err: %s", err)
+	}
+
+	if !reflect.DeepEqual(loaded, tree) {
+		t.Errorf("merkle.ReadStaticTree() = %#v want %#v", loaded, tree)
+	}
+}
+
+func TestStaticTree_UnmarshalBinary_Error(t *testing.T) {
+	tree, err := merkle.NewStaticTree([][]byte{testutil.RandomHash(), testutil.RandomHash(), testutil.RandomHash()})
+	if err != nil {
+		t.Fatalf("merkle.NewStaticTree(): err: %s", err)
+	}
+
+	data, err := tree.MarshalBinary()
+	if err != nil {
+		t.Fatalf("tree.MarshalBinary(): err: %s", err)
+	}
+
+	corrupt := func(i int) []byte {
+		c := append([]byte(nil), data...)
+		c[i] ^= 0xff
+		return c
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"empty", nil, merkle.ErrInvalidStaticTree},
+		{"magic", corrupt(0), merkle.ErrInvalidStaticTree},
+		{"hash", corrupt(len(data) - 10), merkle.ErrStaticTreeChecksum},
+		{"checksum", corrupt(len(data) - 1), merkle.ErrStaticTreeChecksum},
+		{"truncated", data[:len(data)-1], merkle.ErrStaticTreeChecksum},
+	}
+
+	for _, test := range tests {
+		var loaded merkle.StaticTree
+		if err := loaded.UnmarshalBinary(test.data); err != test.err {
+			t.Errorf("%s: loaded.UnmarshalBinary(): err = %v want %v", test.name, err, test.err)
+		}
+	}
+
+	var loaded merkle.StaticTree
+	if err := loaded.UnmarshalBinary(corrupt(4)); err == nil {
+		t.Error("version: loaded.UnmarshalBinary(): err = nil want Error")
+	}
+}
+
+func TestStaticTree_MarshalBinary_unregistered(t *testing.T) {
+	tree, err := merkle.NewStaticTree([][]byte{testutil.RandomHash()}, merkle.WithHash(sha256.New))
+	if err != nil {
+		t.Fatalf("merkle.NewStaticTree(): err: %s", err)
+	}
+
+	if _, err := tree.MarshalBinary(); err == nil {
+		t.Error("tree.MarshalBinary(): err = nil want Error")
+	}
+}
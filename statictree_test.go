@@ -15,10 +15,17 @@
 package merkle_test
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"reflect"
 	"testing"
 
 	"github.com/stratumn/merkle"
+	"github.com/stratumn/merkle/testutil"
 	"github.com/stratumn/merkle/treetestcases"
+	"github.com/stratumn/merkle/types"
 )
 
 func TestNewStaticTree_noLeaves(t *testing.T) {
@@ -31,14 +38,147 @@ func TestNewStaticTree_noLeaves(t *testing.T) {
 	}
 }
 
+func TestNewStaticTree_leafSize(t *testing.T) {
+	var (
+		leaves = [][]byte{testutil.RandomHash(), testutil.RandomHash()[:20], []byte("c")}
+		left   = sha256.Sum256(append(append([]byte{}, leaves[0]...), leaves[1]...))
+		root   = sha256.Sum256(append(left[:], leaves[2]...))
+	)
+
+	// Leaves of different sizes are kept apart from the other nodes.
+	trees := map[string]func() (*merkle.StaticTree, error){
+		"NewStaticTree": func() (*merkle.StaticTree, error) {
+			return merkle.NewStaticTree(leaves)
+		},
+		"NewStaticTreeParallel": func() (*merkle.StaticTree, error) {
+			return merkle.NewStaticTreeParallel(leaves, 2)
+		},
+		"store": func() (*merkle.StaticTree, error) {
+			return merkle.NewStaticTree(leaves, merkle.WithNodeStore(merkle.NewMemNodeStore(0)))
+		},
+	}
+
+	for name, newTree := range trees {
+		tree, err := newTree()
+		if err != nil {
+			t.Fatalf("%s: err: %s", name, err)
+		}
+		if got, want := hex.EncodeToString(tree.Root()), hex.EncodeToString(root[:]); got != want {
+			t.Errorf("%s: tree.Root() = %q want %q", name, got, want)
+		}
+		for i, leaf := range leaves {
+			if got := tree.Leaf(i); !bytes.Equal(got, leaf) {
+				t.Errorf("%s: tree.Leaf(%d) = %x want %x", name, i, got, leaf)
+			}
+		}
+		checkStaticTree(t, name, types.SHA256, tree, leaves)
+
+		if _, err := tree.MarshalBinary(); err == nil {
+			t.Errorf("%s: tree.MarshalBinary(): err = nil want Error", name)
+		}
+	}
+}
+
+// shortHasher is a hasher whose nodes are shorter than its size.
+type shortHasher struct {
+	types.Hasher
+}
+
+func (h shortHasher) HashNode(left, right []byte) []byte {
+	return h.Hasher.HashNode(left, right)[1:]
+}
+
+// shortAppender is a hasher that appends nodes shorter than its size.
+type shortAppender struct {
+	types.Hasher
+}
+
+func (h shortAppender) AppendNode(dst, left, right []byte) []byte {
+	return append(dst, h.Hasher.HashNode(left, right)[1:]...)
+}
+
+func TestNewStaticTree_nodeSize(t *testing.T) {
+	leaves := [][]byte{testutil.RandomHash(), testutil.RandomHash(), testutil.RandomHash()}
+
+	for _, hasher := range []types.Hasher{shortHasher{types.SHA256}, shortAppender{types.SHA256}} {
+		if _, err := merkle.NewStaticTree(leaves, merkle.WithHasher(hasher)); err == nil {
+			t.Errorf("%T: NewStaticTree(): err = nil want Error", hasher)
+		}
+		if _, err := merkle.NewStaticTreeParallel(leaves, 2, merkle.WithHasher(hasher)); err == nil {
+			t.Errorf("%T: NewStaticTreeParallel(): err = nil want Error", hasher)
+		}
+	}
+
+	store := merkle.WithNodeStore(merkle.NewMemNodeStore(0))
+	if _, err := merkle.NewStaticTree(leaves, merkle.WithHasher(shortHasher{types.SHA256}), store); err == nil {
+		t.Error("store: NewStaticTree(): err = nil want Error")
+	}
+}
+
 func TestStaticTree(t *testing.T) {
+	for _, h := range hashers {
+		hasher := h.hasher
+		t.Run(h.name, treetestcases.Factory{
+			New: func(leaves [][]byte) (merkle.Tree, error) {
+				return merkle.NewStaticTree(leaves, merkle.WithHasher(hasher))
+			},
+			Hasher: hasher,
+		}.RunTests)
+	}
+}
+
+func TestStaticTree_withHash(t *testing.T) {
 	treetestcases.Factory{
 		New: func(leaves [][]byte) (merkle.Tree, error) {
-			return merkle.NewStaticTree(leaves)
+			return merkle.NewStaticTree(leaves, merkle.WithHash(sha512.New512_256))
 		},
+		Hasher: types.SHA512_256,
 	}.RunTests(t)
 }
 
+func TestStaticTreeParallel(t *testing.T) {
+	for _, h := range hashers {
+		hasher := h.hasher
+		t.Run(h.name, treetestcases.Factory{
+			New: func(leaves [][]byte) (merkle.Tree, error) {
+				return merkle.NewStaticTreeParallel(leaves, 4, merkle.WithHasher(hasher))
+			},
+			Hasher: hasher,
+		}.RunTests)
+	}
+}
+
+func TestStaticTreeParallel_serial(t *testing.T) {
+	for _, size := range []int{1, 255, 256, 511, 1000, 4097, 20001} {
+		leaves := make([][]byte, size)
+		for i := range leaves {
+			leaves[i] = testutil.RandomHash()
+		}
+
+		serial, err := merkle.NewStaticTree(leaves, merkle.WithHasher(types.RFC6962SHA256))
+		if err != nil {
+			t.Fatalf("merkle.NewStaticTree(): err: %s", err)
+		}
+
+		for _, workers := range []int{0, 1, 3, 32} {
+			parallel, err := merkle.NewStaticTreeParallel(leaves, workers, merkle.WithHasher(types.RFC6962SHA256))
+			if err != nil {
+				t.Fatalf("merkle.NewStaticTreeParallel(): err: %s", err)
+			}
+
+			if !reflect.DeepEqual(parallel, serial) {
+				t.Errorf("merkle.NewStaticTreeParallel(%d leaves, %d workers) differs from merkle.NewStaticTree()", size, workers)
+			}
+		}
+	}
+}
+
+func TestNewStaticTreeParallel_noLeaves(t *testing.T) {
+	if _, err := merkle.NewStaticTreeParallel(nil, 4); err == nil {
+		t.Error("NewStaticTreeParallel(): err = nil want Error")
+	}
+}
+
 func BenchmarkStaticTree(b *testing.B) {
 	treetestcases.Factory{
 		New: func(leaves [][]byte) (merkle.Tree, error) {
@@ -46,3 +186,11 @@ func BenchmarkStaticTree(b *testing.B) {
 		},
 	}.RunBenchmarks(b)
 }
+
+func BenchmarkStaticTreeParallel(b *testing.B) {
+	treetestcases.Factory{
+		New: func(leaves [][]byte) (merkle.Tree, error) {
+			return merkle.NewStaticTreeParallel(leaves, 0)
+		},
+	}.RunBenchmarks(b)
+}
@@ -87,6 +87,10 @@ type Factory struct {
 
 	// Free is an optional function to free a Merkle tree.
 	Free func(tree merkle.Tree)
+
+	// Hasher is the hasher used by the trees created by New. It defaults to
+	// types.DefaultHasher.
+	Hasher types.Hasher
 }
 
 // RunTests runs all the tests.
@@ -96,11 +100,13 @@ func (f Factory) RunTests(t *testing.T) {
 	t.Run("Leaf", f.TestLeaf)
 	t.Run("Path", f.TestPath)
 	t.Run("PathRandom", f.TestPathRandom)
+	t.Run("MultiProof", f.TestMultiProof)
 }
 
 // RunBenchmarks runs all the benchmarks.
 func (f Factory) RunBenchmarks(b *testing.B) {
 	b.Run("Create", f.BenchmarkCreate)
+	b.Run("Alloc", f.BenchmarkAlloc)
 	b.Run("Path", f.BenchmarkPath)
 }
 
@@ -110,6 +116,71 @@ func (f Factory) free(tree merkle.Tree) {
 	}
 }
 
+func (f Factory) hasher() types.Hasher {
+	if f.Hasher != nil {
+		return f.Hasher
+	}
+	return types.DefaultHasher
+}
+
+// Computes the rows of a tree from the given leaves by hashing each row
+// pairwise and promoting orphans to the next row. It is used as a reference
+// for hashers that do not have fixtures.
+func (f Factory) expectedRows(leaves [][]byte) [][][]byte {
+	hashes := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		hashes[i] = f.hasher().HashLeaf(leaf)
+	}
+
+	rows := [][][]byte{hashes}
+	for row := hashes; len(row) > 1; row = rows[len(rows)-1] {
+		next := make([][]byte, 0, (len(row)+1)/2)
+		for i := 0; i < len(row); i += 2 {
+			if i+1 < len(row) {
+				next = append(next, f.hasher().HashNode(row[i], row[i+1]))
+			} else {
+				next = append(next, row[i])
+			}
+		}
+		rows = append(rows, next)
+	}
+	return rows
+}
+
+// Computes the expected root of the given leaves.
+func (f Factory) expectedRoot(leaves [][]byte) []byte {
+	rows := f.expectedRows(leaves)
+	return rows[len(rows)-1][0]
+}
+
+// Computes the expected path of the leaf at given index.
+func (f Factory) expectedPath(leaves [][]byte, index int) types.Path {
+	var (
+		rows = f.expectedRows(leaves)
+		path = types.Path{}
+	)
+	for r, row := range rows[:len(rows)-1] {
+		var left, right int
+		switch {
+		case index%2 == 1:
+			left, right = index-1, index
+		case index+1 < len(row):
+			left, right = index, index+1
+		default:
+			// Orphans are promoted without a triplet.
+			index /= 2
+			continue
+		}
+		index /= 2
+		path = append(path, types.MerkleNodeHashes{
+			Left:   row[left],
+			Right:  row[right],
+			Parent: rows[r+1][index],
+		})
+	}
+	return path
+}
+
 // TestNumLeaves tests that the implementation returns the correct number of
 // leaves.
 func (f Factory) TestNumLeaves(t *testing.T) {
@@ -165,13 +236,19 @@ func (f Factory) TestRoot(t *testing.T) {
 		}
 		defer f.free(tree)
 
-		if got, want := hex.EncodeToString(tree.Root()), test.expected; got != want {
+		want := test.expected
+		if f.hasher() != types.SHA256 {
+			want = hex.EncodeToString(f.expectedRoot(leaves))
+		}
+
+		if got := hex.EncodeToString(tree.Root()); got != want {
 			t.Errorf("test#%d: tree.Root() = %q want %q", i, got, want)
 		}
 	}
 }
 
-// TestLeaf tests that the implementation correctly returns leaves.
+// TestLeaf tests that the implementation correctly returns the hashes of the
+// leaves.
 func (f Factory) TestLeaf(t *testing.T) {
 	for i := 1; i < 128; i++ {
 		var leaves [][]byte
@@ -186,7 +263,7 @@ func (f Factory) TestLeaf(t *testing.T) {
 		defer f.free(tree)
 
 		for j := 0; j < i; j++ {
-			if got, want := hex.EncodeToString(tree.Leaf(j)), hex.EncodeToString(leaves[j]); got != want {
+			if got, want := hex.EncodeToString(tree.Leaf(j)), hex.EncodeToString(f.hasher().HashLeaf(leaves[j])); got != want {
 				t.Errorf("test#%d: tree.Leaf(%d) = %q want %q", i, j, got, want)
 			}
 		}
@@ -194,6 +271,8 @@ func (f Factory) TestLeaf(t *testing.T) {
 }
 
 // TestPath tests that the implementation correctly computes paths.
+// The fixtures use SHA-256, so paths are compared to a reference
+// implementation for other hashers.
 func (f Factory) TestPath(t *testing.T) {
 	tests := []struct {
 		leaves   []string
@@ -239,6 +318,9 @@ func (f Factory) TestPath(t *testing.T) {
 				got  = tree.Path(j)
 				want = test.expected[j]
 			)
+			if f.hasher() != types.SHA256 {
+				want = f.expectedPath(leaves, j)
+			}
 			if !reflect.DeepEqual(got, want) {
 				g, _ := json.MarshalIndent(got, "", "  ")
 				w, _ := json.MarshalIndent(want, "", "  ")
@@ -265,7 +347,7 @@ func (f Factory) TestPathRandom(t *testing.T) {
 
 		for j := range tests {
 			path := tree.Path(j)
-			if err := path.Validate(); err != nil {
+			if err := path.ValidateWith(f.hasher()); err != nil {
 				t.Errorf("path.Validate(): err: %s", err)
 			}
 
@@ -276,6 +358,50 @@ func (f Factory) TestPathRandom(t *testing.T) {
 	}
 }
 
+// TestMultiProof tests that the implementation correctly computes multiproofs
+// given random trees. It is skipped if the implementation does not support
+// multiproofs.
+func (f Factory) TestMultiProof(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		tests := make([][]byte, 1+rand.Intn(1000))
+		for j := range tests {
+			tests[j] = testutil.RandomHash()
+		}
+
+		tree, err := f.New(tests)
+		if err != nil {
+			t.Fatalf("f.New(): err: %s", err)
+		}
+		defer f.free(tree)
+
+		prover, ok := tree.(interface {
+			MultiProof(indices []int) (*types.MultiProof, error)
+		})
+		if !ok {
+			t.Skip("tree does not support multiproofs")
+		}
+
+		indices := make([]int, 1+rand.Intn(len(tests)))
+		for j := range indices {
+			indices[j] = rand.Intn(len(tests))
+		}
+
+		proof, err := prover.MultiProof(indices)
+		if err != nil {
+			t.Fatalf("tree.MultiProof(): err: %s", err)
+		}
+
+		leaves := make([][]byte, len(proof.Indices))
+		for j, index := range proof.Indices {
+			leaves[j] = tree.Leaf(index)
+		}
+
+		if err := proof.VerifyWith(f.hasher(), leaves, tree.Root()); err != nil {
+			t.Errorf("test#%d: proof.VerifyWith(): err: %s", i, err)
+		}
+	}
+}
+
 // BenchmarkCreateWithSize benchmarks creating trees of given size.
 func (f Factory) BenchmarkCreateWithSize(b *testing.B, size int) {
 	leaves := make([][]byte, size)
@@ -304,6 +430,19 @@ func (f Factory) BenchmarkCreate(b *testing.B) {
 	b.Run("100000-leaves", func(b *testing.B) { f.BenchmarkCreateWithSize(b, 100000) })
 }
 
+// BenchmarkAlloc benchmarks the memory allocated when creating trees of
+// different sizes.
+func (f Factory) BenchmarkAlloc(b *testing.B) {
+	b.Run("1000-leaves", func(b *testing.B) {
+		b.ReportAllocs()
+		f.BenchmarkCreateWithSize(b, 1000)
+	})
+	b.Run("100000-leaves", func(b *testing.B) {
+		b.ReportAllocs()
+		f.BenchmarkCreateWithSize(b, 100000)
+	})
+}
+
 // BenchmarkPathWithSize benchmarks computing paths for trees of given size.
 func (f Factory) BenchmarkPathWithSize(b *testing.B, size int) {
 	leaves := make([][]byte, size)
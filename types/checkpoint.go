@@ -0,0 +1,147 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrMalformedCheckpoint is returned when the text of a checkpoint is not
+// correctly formatted.
+var ErrMalformedCheckpoint = errors.New("malformed checkpoint")
+
+// Checkpoint is the state of a log that is signed as a note, as described by
+// the C2SP tlog-checkpoint specification. It is understood by the Go checksum
+// database tooling and by witnesses.
+//
+// The text of a checkpoint is the origin of the log, the tree size in decimal
+// and the base64 encoding of the root hash, each on its own line, followed by
+// optional extension lines.
+type Checkpoint struct {
+	// Origin uniquely identifies the log. It is usually the name of the key
+	// signing the checkpoints.
+	Origin string
+
+	TreeSize int
+	RootHash []byte
+
+	// Extensions are additional lines whose meaning is defined by the log.
+	Extensions []string
+}
+
+// MarshalText implements encoding.TextMarshaler.MarshalText. It returns the
+// unsigned text of the checkpoint.
+func (c *Checkpoint) MarshalText() ([]byte, error) {
+	if c.Origin == "" || strings.Contains(c.Origin, "\n") || c.TreeSize < 0 || len(c.RootHash) < 1 {
+		return nil, ErrMalformedCheckpoint
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s\n%d\n%s\n", c.Origin, c.TreeSize, base64.StdEncoding.EncodeToString(c.RootHash))
+
+	for _, ext := range c.Extensions {
+		if ext == "" || strings.Contains(ext, "\n") {
+			return nil, ErrMalformedCheckpoint
+		}
+		buf.WriteString(ext)
+		buf.WriteString("\n")
+	}
+
+	if !isValidNoteText(buf.String()) {
+		return nil, ErrMalformedCheckpoint
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.UnmarshalText. It parses
+// the unsigned text of a checkpoint.
+func (c *Checkpoint) UnmarshalText(text []byte) error {
+	if !isValidNoteText(string(text)) {
+		return ErrMalformedCheckpoint
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(text), "\n"), "\n")
+	if len(lines) < 3 || lines[0] == "" {
+		return ErrMalformedCheckpoint
+	}
+
+	size, err := strconv.ParseInt(lines[1], 10, 64)
+	if err != nil || size < 0 || lines[1] != strconv.FormatInt(size, 10) {
+		return ErrMalformedCheckpoint
+	}
+
+	root, err := base64.StdEncoding.DecodeString(lines[2])
+	if err != nil || len(root) < 1 {
+		return ErrMalformedCheckpoint
+	}
+
+	var extensions []string
+	for _, ext := range lines[3:] {
+		if ext == "" {
+			return ErrMalformedCheckpoint
+		}
+		extensions = append(extensions, ext)
+	}
+
+	*c = Checkpoint{Origin: lines[0], TreeSize: int(size), RootHash: root, Extensions: extensions}
+
+	return nil
+}
+
+// Sign returns the checkpoint as a note signed by the given signer.
+func (c *Checkpoint) Sign(signer *NoteSigner) ([]byte, error) {
+	text, err := c.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+
+	n := &Note{Text: string(text)}
+	if err := n.Sign(signer); err != nil {
+		return nil, err
+	}
+
+	return n.MarshalText()
+}
+
+// OpenCheckpoint parses a signed checkpoint and verifies the signature of the
+// log, whose key name must be the origin of the checkpoint. Signatures made
+// with other keys, such as the cosignatures of witnesses, are ignored.
+func OpenCheckpoint(msg []byte, verifier *NoteVerifier) (*Checkpoint, error) {
+	n, err := ParseNote(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Checkpoint{}
+	if err := c.UnmarshalText([]byte(n.Text)); err != nil {
+		return nil, err
+	}
+
+	if c.Origin != verifier.Name() {
+		return nil, fmt.Errorf("%w: origin %q does not match key %q", ErrUnverifiedNote, c.Origin, verifier.Name())
+	}
+
+	if _, err := n.Verify(verifier); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types_test
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stratumn/merkle/testutil"
+	"github.com/stratumn/merkle/types"
+)
+
+func TestCheckpointText(t *testing.T) {
+	// Published examples from the Go checksum database and the C2SP
+	// tlog-checkpoint specification.
+	tests := []struct {
+		text string
+		want types.Checkpoint
+	}{{
+		"go.sum database tree\n2\nnND/nri/U0xuHUrYSy0HtMeal2vzD9V4k/BO79C+QeI=\n",
+		types.Checkpoint{Origin: "go.sum database tree", TreeSize: 2, RootHash: decodeBase64(t, "nND/nri/U0xuHUrYSy0HtMeal2vzD9V4k/BO79C+QeI=")},
+	}, {
+		"example.com/behind-the-sofa\n20852163\nCsUYapGGPo4dkMgIAUqom/Xajj7h2fB2MPA3j2jxq2I=\n",
+		types.Checkpoint{Origin: "example.com/behind-the-sofa", TreeSize: 20852163, RootHash: decodeBase64(t, "CsUYapGGPo4dkMgIAUqom/Xajj7h2fB2MPA3j2jxq2I=")},
+	}, {
+		"example.com/log\n1\nCsUYapGGPo4dkMgIAUqom/Xajj7h2fB2MPA3j2jxq2I=\nfirst extension\nsecond\n",
+		types.Checkpoint{
+			Origin:     "example.com/log",
+			TreeSize:   1,
+			RootHash:   decodeBase64(t, "CsUYapGGPo4dkMgIAUqom/Xajj7h2fB2MPA3j2jxq2I="),
+			Extensions: []string{"first extension", "second"},
+		},
+	}}
+
+	for _, tt := range tests {
+		var c types.Checkpoint
+		if err := c.UnmarshalText([]byte(tt.text)); err != nil {
+			t.Errorf("UnmarshalText(%q): err: %s", tt.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(c, tt.want) {
+			t.Errorf("UnmarshalText(%q) = %v want %v", tt.text, c, tt.want)
+		}
+
+		got, err := tt.want.MarshalText()
+		if err != nil {
+			t.Errorf("MarshalText(): err: %s", err)
+			continue
+		}
+		if string(got) != tt.text {
+			t.Errorf("MarshalText() = %q want %q", got, tt.text)
+		}
+	}
+}
+
+func TestCheckpointText_Error(t *testing.T) {
+	texts := []string{
+		"",
+		"origin\n1\nCsUYapGGPo4dkMgIAUqom/Xajj7h2fB2MPA3j2jxq2I=",
+		"origin\n1\n",
+		"\n1\nCsUYapGGPo4dkMgIAUqom/Xajj7h2fB2MPA3j2jxq2I=\n",
+		"origin\n01\nCsUYapGGPo4dkMgIAUqom/Xajj7h2fB2MPA3j2jxq2I=\n",
+		"origin\n-1\nCsUYapGGPo4dkMgIAUqom/Xajj7h2fB2MPA3j2jxq2I=\n",
+		"origin\n1\nnot base64\n",
+		"origin\n1\nCsUYapGGPo4dkMgIAUqom/Xajj7h2fB2MPA3j2jxq2I=\n\n",
+	}
+
+	for _, text := range texts {
+		var c types.Checkpoint
+		if err := c.UnmarshalText([]byte(text)); err != types.ErrMalformedCheckpoint {
+			t.Errorf("UnmarshalText(%q) err = %v want %v", text, err, types.ErrMalformedCheckpoint)
+		}
+	}
+
+	invalid := []types.Checkpoint{
+		{Origin: "", TreeSize: 1, RootHash: []byte{1}},
+		{Origin: "a\nb", TreeSize: 1, RootHash: []byte{1}},
+		{Origin: "origin", TreeSize: 1},
+		{Origin: "origin", TreeSize: 1, RootHash: []byte{1}, Extensions: []string{""}},
+	}
+
+	for _, c := range invalid {
+		if _, err := c.MarshalText(); err != types.ErrMalformedCheckpoint {
+			t.Errorf("MarshalText(%v) err = %v want %v", c, err, types.ErrMalformedCheckpoint)
+		}
+	}
+}
+
+func TestOpenCheckpoint(t *testing.T) {
+	skey, vkey, err := types.GenerateNoteKey(rand.Reader, "example.com/log")
+	if err != nil {
+		t.Fatalf("types.GenerateNoteKey(): err: %s", err)
+	}
+	signer, _ := types.NewNoteSigner(skey)
+	verifier, _ := types.NewNoteVerifier(vkey)
+
+	want := &types.Checkpoint{Origin: "example.com/log", TreeSize: 7, RootHash: testutil.RandomHash()}
+	msg, err := want.Sign(signer)
+	if err != nil {
+		t.Fatalf("c.Sign(): err: %s", err)
+	}
+
+	// Cosignatures of witnesses are ignored.
+	wkey, _, _ := types.GenerateNoteKey(rand.Reader, "witness")
+	witness, _ := types.NewNoteSigner(wkey)
+	n, _ := types.ParseNote(msg)
+	if err := n.Sign(witness); err != nil {
+		t.Fatalf("n.Sign(): err: %s", err)
+	}
+	cosigned, _ := n.MarshalText()
+
+	for _, m := range [][]byte{msg, cosigned} {
+		got, err := types.OpenCheckpoint(m, verifier)
+		if err != nil {
+			t.Fatalf("types.OpenCheckpoint(): err: %s", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("types.OpenCheckpoint() = %v want %v", got, want)
+		}
+	}
+
+	// The origin must be the name of the key.
+	other := &types.Checkpoint{Origin: "example.com/other", TreeSize: 7, RootHash: want.RootHash}
+	msg, _ = other.Sign(signer)
+	if _, err := types.OpenCheckpoint(msg, verifier); !errors.Is(err, types.ErrUnverifiedNote) {
+		t.Errorf("types.OpenCheckpoint() err = %v want %v", err, types.ErrUnverifiedNote)
+	}
+
+	msg, _ = want.Sign(signer)
+	msg = []byte(strings.Replace(string(msg), "\n7\n", "\n8\n", 1))
+	if _, err := types.OpenCheckpoint(msg, verifier); err != types.ErrInvalidSignature {
+		t.Errorf("types.OpenCheckpoint() err = %v want %v", err, types.ErrInvalidSignature)
+	}
+}
+
+func decodeBase64(t *testing.T, s string) []byte {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatalf("base64.DecodeString(): err: %s", err)
+	}
+	return b
+}
@@ -0,0 +1,106 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"bytes"
+	"errors"
+)
+
+var (
+	// ErrSizeOutOfRange is returned when the sizes of a consistency proof are
+	// not valid.
+	ErrSizeOutOfRange = errors.New("tree size out of range")
+
+	// ErrInconsistentProof is returned when a consistency proof does not
+	// prove that a tree is a prefix of another.
+	ErrInconsistentProof = errors.New("consistency proof does not match the roots")
+)
+
+// VerifyConsistency checks that a consistency proof proves that the tree of
+// the given old size and root is a prefix of the tree of the given new size
+// and root, using the default hasher.
+func VerifyConsistency(oldSize, newSize int, oldRoot, newRoot []byte, proof [][]byte) error {
+	return VerifyConsistencyWith(DefaultHasher, oldSize, newSize, oldRoot, newRoot, proof)
+}
+
+// VerifyConsistencyWith checks that a consistency proof proves that the tree
+// of the given old size and root is a prefix of the tree of the given new size
+// and root, using the given hasher.
+//
+// The proof must be in the format described in section 2.1.2 of RFC 6962.
+func VerifyConsistencyWith(hasher Hasher, oldSize, newSize int, oldRoot, newRoot []byte, proof [][]byte) error {
+	if oldSize < 1 || oldSize > newSize {
+		return ErrSizeOutOfRange
+	}
+
+	if oldSize == newSize {
+		if len(proof) > 0 || !bytes.Equal(oldRoot, newRoot) {
+			return ErrInconsistentProof
+		}
+		return nil
+	}
+
+	if len(proof) < 1 {
+		return ErrInconsistentProof
+	}
+
+	// If the old tree is a complete subtree of the new one, its root is the
+	// first node of the proof.
+	if oldSize&(oldSize-1) == 0 {
+		proof = append([][]byte{oldRoot}, proof...)
+	}
+
+	var (
+		fn = oldSize - 1
+		sn = newSize - 1
+	)
+
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	var (
+		fr = proof[0]
+		sr = proof[0]
+	)
+
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return ErrInconsistentProof
+		}
+
+		if fn&1 == 1 || fn == sn {
+			fr = hasher.HashNode(c, fr)
+			sr = hasher.HashNode(c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = hasher.HashNode(sr, c)
+		}
+
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 || !bytes.Equal(fr, oldRoot) || !bytes.Equal(sr, newRoot) {
+		return ErrInconsistentProof
+	}
+
+	return nil
+}
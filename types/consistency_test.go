@@ -0,0 +1,107 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stratumn/merkle/testutil"
+	"github.com/stratumn/merkle/types"
+)
+
+// Test vectors from the Certificate Transparency reference implementation.
+var rfc6962Roots = map[int]string{
+	1: "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+	2: "fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+	5: "4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+	6: "76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+	8: "5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+}
+
+var rfc6962ConsistencyProofs = []struct {
+	oldSize int
+	newSize int
+	proof   []string
+}{
+	{1, 1, nil},
+	{1, 8, []string{
+		"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+		"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+		"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4",
+	}},
+	{6, 8, []string{
+		"0ebc5d3437fbe2db158b9f126a1d118e308181031d0a949f8dededebc558ef6a",
+		"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+		"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+	}},
+	{2, 5, []string{
+		"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+		"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+	}},
+}
+
+func decodeHashes(t *testing.T, hexes []string) [][]byte {
+	hashes := make([][]byte, len(hexes))
+	for i, h := range hexes {
+		var err error
+		if hashes[i], err = hex.DecodeString(h); err != nil {
+			t.Fatalf("hex.DecodeString(): err: %s", err)
+		}
+	}
+	return hashes
+}
+
+func TestVerifyConsistency(t *testing.T) {
+	for _, test := range rfc6962ConsistencyProofs {
+		var (
+			proof   = decodeHashes(t, test.proof)
+			roots   = decodeHashes(t, []string{rfc6962Roots[test.oldSize], rfc6962Roots[test.newSize]})
+			oldRoot = roots[0]
+			newRoot = roots[1]
+			hasher  = types.RFC6962SHA256
+			oldSize = test.oldSize
+			newSize = test.newSize
+		)
+
+		if err := types.VerifyConsistencyWith(hasher, oldSize, newSize, oldRoot, newRoot, proof); err != nil {
+			t.Errorf("VerifyConsistencyWith(%d, %d): err: %s", oldSize, newSize, err)
+		}
+
+		if err := types.VerifyConsistencyWith(hasher, oldSize, newSize, testutil.RandomHash(), newRoot, proof); err != types.ErrInconsistentProof {
+			t.Errorf("VerifyConsistencyWith(%d, %d) wrong old root: err = %v want %v", oldSize, newSize, err, types.ErrInconsistentProof)
+		}
+
+		if err := types.VerifyConsistencyWith(hasher, oldSize, newSize, oldRoot, testutil.RandomHash(), proof); err != types.ErrInconsistentProof {
+			t.Errorf("VerifyConsistencyWith(%d, %d) wrong new root: err = %v want %v", oldSize, newSize, err, types.ErrInconsistentProof)
+		}
+
+		if len(proof) > 0 {
+			if err := types.VerifyConsistencyWith(hasher, oldSize, newSize, oldRoot, newRoot, proof[1:]); err != types.ErrInconsistentProof {
+				t.Errorf("VerifyConsistencyWith(%d, %d) truncated proof: err = %v want %v", oldSize, newSize, err, types.ErrInconsistentProof)
+			}
+		}
+	}
+}
+
+func TestVerifyConsistency_sizeOutOfRange(t *testing.T) {
+	root := testutil.RandomHash()
+	if err := types.VerifyConsistency(0, 1, root, root, nil); err != types.ErrSizeOutOfRange {
+		t.Errorf("VerifyConsistency(0, 1): err = %v want %v", err, types.ErrSizeOutOfRange)
+	}
+	if err := types.VerifyConsistency(2, 1, root, root, nil); err != types.ErrSizeOutOfRange {
+		t.Errorf("VerifyConsistency(2, 1): err = %v want %v", err, types.ErrSizeOutOfRange)
+	}
+}
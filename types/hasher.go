@@ -0,0 +1,269 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha3"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// Hasher computes the hashes of the leaves and nodes of a Merkle tree.
+// Implementations must be safe for concurrent use.
+type Hasher interface {
+	// Size returns the number of bytes of a node hash.
+	Size() int
+
+	// HashLeaf returns the hash of a leaf given its data.
+	HashLeaf(data []byte) []byte
+
+	// HashNode returns the hash of a node given the hashes of its left and
+	// right children.
+	HashNode(left, right []byte) []byte
+}
+
+// NodeAppender is an optional interface implemented by hashers that can
+// append the hash of a node to a buffer, which avoids allocating memory when
+// the buffer has enough capacity.
+type NodeAppender interface {
+	// AppendNode appends the hash of a node given the hashes of its left and
+	// right children to dst and returns the resulting slice.
+	AppendNode(dst, left, right []byte) []byte
+}
+
+// DataHasher is an optional interface implemented by hashers built on a hash
+// function, such as the hashers created with NewHasher and NewRFC6962Hasher.
+// It gives access to the hash function to hash data into leaves.
+type DataHasher interface {
+	// HashFunc returns the hash function of the hasher.
+	HashFunc() func() hash.Hash
+
+	// HashesLeafData returns whether HashLeaf hashes the data of a leaf, as
+	// opposed to expecting a hash and returning it unchanged.
+	HashesLeafData() bool
+}
+
+var (
+	// SHA256 is a Hasher using SHA-256.
+	SHA256 = NewHasher(sha256.New)
+
+	// SHA512_256 is a Hasher using SHA-512/256.
+	SHA512_256 = NewHasher(sha512.New512_256)
+
+	// SHA3_256 is a Hasher using SHA3-256.
+	SHA3_256 = NewHasher(func() hash.Hash { return sha3.New256() })
+
+	// SHA1 is a Hasher using SHA-1. It should only be used to work with
+	// legacy trees.
+	SHA1 = NewHasher(sha1.New)
+
+	// RFC6962SHA256 is a Hasher using SHA-256 with the domain separation of
+	// RFC 6962.
+	RFC6962SHA256 = NewRFC6962Hasher(sha256.New)
+
+	// DefaultHasher is the Hasher used when none is specified.
+	DefaultHasher = SHA256
+)
+
+// Names of the hashers of this package.
+const (
+	NameSHA256        = "sha256"
+	NameSHA512_256    = "sha512_256"
+	NameSHA3_256      = "sha3_256"
+	NameSHA1          = "sha1"
+	NameRFC6962SHA256 = "rfc6962_sha256"
+)
+
+var (
+	namesMutex sync.RWMutex
+	names      = map[string]Hasher{
+		NameSHA256:        SHA256,
+		NameSHA512_256:    SHA512_256,
+		NameSHA3_256:      SHA3_256,
+		NameSHA1:          SHA1,
+		NameRFC6962SHA256: RFC6962SHA256,
+	}
+)
+
+// RegisterHasher registers a hasher under the given name, so that it can be
+// recorded in and loaded from serialized trees. The hasher must be comparable,
+// such as a pointer. It returns an error if the name is already used.
+func RegisterHasher(name string, hasher Hasher) error {
+	if !comparable(hasher) {
+		return fmt.Errorf("hasher %q is not comparable", name)
+	}
+
+	namesMutex.Lock()
+	defer namesMutex.Unlock()
+
+	if _, ok := names[name]; ok {
+		return fmt.Errorf("hasher %q is already registered", name)
+	}
+	names[name] = hasher
+
+	return nil
+}
+
+// HasherByName returns the hasher registered under the given name.
+func HasherByName(name string) (Hasher, error) {
+	namesMutex.RLock()
+	defer namesMutex.RUnlock()
+
+	hasher, ok := names[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown hasher %q", name)
+	}
+
+	return hasher, nil
+}
+
+// HasherNames returns the sorted names of the registered hashers.
+func HasherNames() []string {
+	namesMutex.RLock()
+	defer namesMutex.RUnlock()
+
+	list := make([]string, 0, len(names))
+	for name := range names {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+
+	return list
+}
+
+// HasherName returns the name under which the given hasher is registered.
+func HasherName(hasher Hasher) (string, error) {
+	// Comparing interfaces holding the same non-comparable type panics, and
+	// registered hashers are comparable.
+	if !comparable(hasher) {
+		return "", fmt.Errorf("hasher is not registered")
+	}
+
+	namesMutex.RLock()
+	defer namesMutex.RUnlock()
+
+	for name, h := range names {
+		if h == hasher {
+			return name, nil
+		}
+	}
+
+	return "", fmt.Errorf("hasher is not registered")
+}
+
+// Returns whether a hasher can be compared with ==.
+func comparable(hasher Hasher) bool {
+	t := reflect.TypeOf(hasher)
+	return t != nil && t.Comparable()
+}
+
+// Prefixes used by RFC 6962 to separate leaf hashes from node hashes.
+const (
+	rfc6962LeafPrefix = 0x00
+	rfc6962NodePrefix = 0x01
+)
+
+// hasher computes the hash of a node as H(left || right), or as
+// H(0x01 || left || right) when using domain separation.
+type hasher struct {
+	pool    sync.Pool
+	newHash func() hash.Hash
+	size    int
+	rfc6962 bool
+}
+
+// NewHasher creates a Hasher that computes the hash of a node as
+// H(left || right) using the given hash function. Leaves are expected to be
+// hashes already and are returned unchanged by HashLeaf.
+func NewHasher(newHash func() hash.Hash) Hasher {
+	return newHasher(newHash, false)
+}
+
+// NewRFC6962Hasher creates a Hasher that follows RFC 6962 using the given
+// hash function. The hash of a leaf is H(0x00 || data) and the hash of a node
+// is H(0x01 || left || right), so that a node cannot be passed off as a leaf.
+func NewRFC6962Hasher(newHash func() hash.Hash) Hasher {
+	return newHasher(newHash, true)
+}
+
+func newHasher(newHash func() hash.Hash, rfc6962 bool) *hasher {
+	return &hasher{
+		pool:    sync.Pool{New: func() interface{} { return newHash() }},
+		newHash: newHash,
+		size:    newHash().Size(),
+		rfc6962: rfc6962,
+	}
+}
+
+// Size implements Hasher.Size.
+func (h *hasher) Size() int {
+	return h.size
+}
+
+// HashFunc implements DataHasher.HashFunc.
+func (h *hasher) HashFunc() func() hash.Hash {
+	return h.newHash
+}
+
+// HashesLeafData implements DataHasher.HashesLeafData.
+func (h *hasher) HashesLeafData() bool {
+	return h.rfc6962
+}
+
+// HashLeaf implements Hasher.HashLeaf.
+func (h *hasher) HashLeaf(data []byte) []byte {
+	if !h.rfc6962 {
+		return data
+	}
+
+	hash := h.pool.Get().(hash.Hash)
+	defer h.pool.Put(hash)
+
+	hash.Reset()
+
+	// Write never returns an error.
+	hash.Write([]byte{rfc6962LeafPrefix})
+	hash.Write(data)
+
+	return hash.Sum(nil)
+}
+
+// HashNode implements Hasher.HashNode.
+func (h *hasher) HashNode(left, right []byte) []byte {
+	return h.AppendNode(nil, left, right)
+}
+
+// AppendNode implements NodeAppender.AppendNode.
+func (h *hasher) AppendNode(dst, left, right []byte) []byte {
+	hash := h.pool.Get().(hash.Hash)
+	defer h.pool.Put(hash)
+
+	hash.Reset()
+
+	// Write never returns an error.
+	if h.rfc6962 {
+		hash.Write([]byte{rfc6962NodePrefix})
+	}
+	hash.Write(left)
+	hash.Write(right)
+
+	return hash.Sum(dst)
+}
@@ -0,0 +1,184 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types_test
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha3"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"sort"
+	"testing"
+
+	"github.com/stratumn/merkle/testutil"
+	"github.com/stratumn/merkle/types"
+)
+
+func TestHasher(t *testing.T) {
+	var (
+		left  = testutil.RandomHash()
+		right = testutil.RandomHash()
+		data  = append(append([]byte{}, left...), right...)
+	)
+
+	sha256Sum := sha256.Sum256(data)
+	sha512Sum := sha512.Sum512_256(data)
+	sha3Sum := sha3.Sum256(data)
+	sha1Sum := sha1.Sum(data)
+
+	tests := []struct {
+		name     string
+		hasher   types.Hasher
+		expected []byte
+	}{
+		{"SHA-256", types.SHA256, sha256Sum[:]},
+		{"SHA-512/256", types.SHA512_256, sha512Sum[:]},
+		{"SHA3-256", types.SHA3_256, sha3Sum[:]},
+		{"SHA-1", types.SHA1, sha1Sum[:]},
+	}
+
+	for _, test := range tests {
+		got := test.hasher.HashNode(left, right)
+		if got, want := hex.EncodeToString(got), hex.EncodeToString(test.expected); got != want {
+			t.Errorf("%s: hasher.HashNode() = %q want %q", test.name, got, want)
+		}
+		if got, want := test.hasher.Size(), len(test.expected); got != want {
+			t.Errorf("%s: hasher.Size() = %d want %d", test.name, got, want)
+		}
+	}
+}
+
+func TestRFC6962Hasher(t *testing.T) {
+	var (
+		data  = []byte(testutil.RandomString(64))
+		left  = testutil.RandomHash()
+		right = testutil.RandomHash()
+	)
+
+	leaf := sha256.Sum256(append([]byte{0x00}, data...))
+	if got, want := hex.EncodeToString(types.RFC6962SHA256.HashLeaf(data)), hex.EncodeToString(leaf[:]); got != want {
+		t.Errorf("hasher.HashLeaf() = %q want %q", got, want)
+	}
+
+	node := sha256.Sum256(append(append([]byte{0x01}, left...), right...))
+	if got, want := hex.EncodeToString(types.RFC6962SHA256.HashNode(left, right)), hex.EncodeToString(node[:]); got != want {
+		t.Errorf("hasher.HashNode() = %q want %q", got, want)
+	}
+
+	if got, want := hex.EncodeToString(types.SHA256.HashLeaf(data)), hex.EncodeToString(data); got != want {
+		t.Errorf("types.SHA256.HashLeaf() = %q want %q", got, want)
+	}
+}
+
+func TestHasherByName(t *testing.T) {
+	for _, name := range []string{
+		types.NameSHA256,
+		types.NameSHA512_256,
+		types.NameSHA3_256,
+		types.NameSHA1,
+		types.NameRFC6962SHA256,
+	} {
+		hasher, err := types.HasherByName(name)
+		if err != nil {
+			t.Fatalf("types.HasherByName(%q): err: %s", name, err)
+		}
+		if got, err := types.HasherName(hasher); err != nil || got != name {
+			t.Errorf("types.HasherName() = %q, %v want %q", got, err, name)
+		}
+	}
+
+	if _, err := types.HasherByName("md5"); err == nil {
+		t.Error("types.HasherByName(md5): err = nil want Error")
+	}
+
+	custom := types.NewHasher(sha256.New224)
+	if _, err := types.HasherName(custom); err == nil {
+		t.Error("types.HasherName(custom): err = nil want Error")
+	}
+	if err := types.RegisterHasher("test_sha224", custom); err != nil {
+		t.Fatalf("types.RegisterHasher(): err: %s", err)
+	}
+	if got, err := types.HasherName(custom); err != nil || got != "test_sha224" {
+		t.Errorf("types.HasherName() = %q, %v want %q", got, err, "test_sha224")
+	}
+	if err := types.RegisterHasher(types.NameSHA256, custom); err == nil {
+		t.Error("types.RegisterHasher(sha256): err = nil want Error")
+	}
+}
+
+func TestHasherNames(t *testing.T) {
+	names := types.HasherNames()
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("types.HasherNames() = %q want sorted", names)
+	}
+
+	for _, name := range names {
+		if _, err := types.HasherByName(name); err != nil {
+			t.Errorf("types.HasherByName(%q): err: %s", name, err)
+		}
+	}
+}
+
+func TestDataHasher(t *testing.T) {
+	tests := []struct {
+		hasher     types.Hasher
+		hashFunc   func() hash.Hash
+		hashesLeaf bool
+	}{
+		{types.SHA256, sha256.New, false},
+		{types.SHA1, sha1.New, false},
+		{types.RFC6962SHA256, sha256.New, true},
+	}
+
+	for _, tt := range tests {
+		dh, ok := tt.hasher.(types.DataHasher)
+		if !ok {
+			t.Fatalf("%T does not implement types.DataHasher", tt.hasher)
+		}
+
+		got, want := dh.HashFunc()(), tt.hashFunc()
+		got.Write([]byte("data"))
+		want.Write([]byte("data"))
+		if !bytes.Equal(got.Sum(nil), want.Sum(nil)) {
+			t.Errorf("dh.HashFunc() = %x want %x", got.Sum(nil), want.Sum(nil))
+		}
+		if dh.HashesLeafData() != tt.hashesLeaf {
+			t.Errorf("dh.HashesLeafData() = %t want %t", dh.HashesLeafData(), tt.hashesLeaf)
+		}
+	}
+}
+
+// sliceHasher is a hasher that is not comparable.
+type sliceHasher struct {
+	types.Hasher
+	prefix []byte
+}
+
+func TestHasherName_notComparable(t *testing.T) {
+	hasher := sliceHasher{Hasher: types.SHA256}
+
+	if _, err := types.HasherName(hasher); err == nil {
+		t.Error("types.HasherName(): err = nil want Error")
+	}
+	if err := types.RegisterHasher("test_slice", hasher); err == nil {
+		t.Error("types.RegisterHasher(): err = nil want Error")
+	}
+	if _, err := types.HasherName(hasher); err == nil {
+		t.Error("types.HasherName(): err = nil want Error")
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"bytes"
+	"errors"
+)
+
+var (
+	// ErrIndexOutOfRange is returned when the index of a leaf is not within
+	// the tree.
+	ErrIndexOutOfRange = errors.New("index out of range")
+
+	// ErrLeafMismatch is returned when a path does not start at the
+	// expected leaf.
+	ErrLeafMismatch = errors.New("path does not start at the leaf")
+
+	// ErrIndexMismatch is returned when a path does not match the position
+	// of the leaf in the tree.
+	ErrIndexMismatch = errors.New("path does not match the index of the leaf")
+
+	// ErrRootMismatch is returned when a path does not end at the expected
+	// root.
+	ErrRootMismatch = errors.New("path does not end at the root")
+)
+
+// VerifyInclusion checks that a path proves the inclusion of a leaf hash at
+// the given index of a tree of the given size, using the default hasher.
+func VerifyInclusion(leaf, root []byte, index, treeSize int, path Path) error {
+	return VerifyInclusionWith(DefaultHasher, leaf, root, index, treeSize, path)
+}
+
+// VerifyInclusionWith checks that a path proves the inclusion of a leaf hash
+// at the given index of a tree of the given size, using the given hasher.
+//
+// The path must follow the layout of the trees of this package, where the
+// last node of a row that has an odd number of nodes is promoted to the row
+// above without being hashed.
+func VerifyInclusionWith(hasher Hasher, leaf, root []byte, index, treeSize int, path Path) error {
+	if index < 0 || index >= treeSize {
+		return ErrIndexOutOfRange
+	}
+
+	if err := path.ValidateWith(hasher); err != nil {
+		return err
+	}
+
+	layout := pathLayout(index, treeSize)
+	if len(layout) != len(path) {
+		return ErrIndexMismatch
+	}
+
+	node := leaf
+
+	for depth, isRight := range layout {
+		h := path[depth]
+		got, other := h.Left, h.Right
+		if isRight {
+			got, other = h.Right, h.Left
+		}
+
+		if !bytes.Equal(got, node) {
+			if depth == 0 && !bytes.Equal(other, node) {
+				return ErrLeafMismatch
+			}
+			return ErrIndexMismatch
+		}
+
+		node = h.Parent
+	}
+
+	if !bytes.Equal(node, root) {
+		return ErrRootMismatch
+	}
+
+	return nil
+}
+
+// Returns, for each triplet of the path of the leaf at given index, whether
+// the node on the path is the right child. Orphans are promoted to the row
+// above without a triplet.
+func pathLayout(index, treeSize int) []bool {
+	var layout []bool
+	for i, n := index, treeSize; n > 1; i, n = i/2, (n+1)/2 {
+		if i%2 == 0 && i == n-1 {
+			continue
+		}
+		layout = append(layout, i%2 == 1)
+	}
+	return layout
+}
@@ -0,0 +1,83 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stratumn/merkle/testutil"
+	"github.com/stratumn/merkle/types"
+)
+
+func TestVerifyInclusion(t *testing.T) {
+	var (
+		pathABCDE0 types.Path
+		pathABCDE4 types.Path
+		a          = sha256.Sum256([]byte("a"))
+		e          = sha256.Sum256([]byte("e"))
+		root, _    = hex.DecodeString("d71f8983ad4ee170f8129f1ebcdd7440be7798d8e1c80420bf11f1eced610dba")
+	)
+	if err := loadPath("testdata/path-abcde-0.json", &pathABCDE0); err != nil {
+		t.Fatalf("loadPath(): err: %s", err)
+	}
+	if err := loadPath("testdata/path-abcde-4.json", &pathABCDE4); err != nil {
+		t.Fatalf("loadPath(): err: %s", err)
+	}
+
+	tests := []struct {
+		name     string
+		leaf     []byte
+		root     []byte
+		index    int
+		treeSize int
+		path     types.Path
+		expected error
+	}{
+		{"first", a[:], root, 0, 5, pathABCDE0, nil},
+		{"orphan", e[:], root, 4, 5, pathABCDE4, nil},
+		{"wrong leaf", e[:], root, 0, 5, pathABCDE0, types.ErrLeafMismatch},
+		{"wrong root", a[:], testutil.RandomHash(), 0, 5, pathABCDE0, types.ErrRootMismatch},
+		{"wrong index", a[:], root, 1, 5, pathABCDE0, types.ErrIndexMismatch},
+		{"wrong size", a[:], root, 0, 2, pathABCDE0, types.ErrIndexMismatch},
+		{"orphan wrong index", e[:], root, 3, 5, pathABCDE4, types.ErrIndexMismatch},
+		{"orphan wrong size", e[:], root, 4, 6, pathABCDE4, types.ErrIndexMismatch},
+		{"negative index", a[:], root, -1, 5, pathABCDE0, types.ErrIndexOutOfRange},
+		{"index too big", e[:], root, 5, 5, pathABCDE4, types.ErrIndexOutOfRange},
+		{"single leaf", a[:], a[:], 0, 1, types.Path{}, nil},
+		{"single leaf wrong root", a[:], e[:], 0, 1, types.Path{}, types.ErrRootMismatch},
+	}
+
+	for _, test := range tests {
+		err := types.VerifyInclusion(test.leaf, test.root, test.index, test.treeSize, test.path)
+		if err != test.expected {
+			t.Errorf("%s: types.VerifyInclusion(): err = %v want %v", test.name, err, test.expected)
+		}
+	}
+}
+
+func TestVerifyInclusion_invalidPath(t *testing.T) {
+	var pathInvalid0 types.Path
+	if err := loadPath("testdata/path-invalid-0.json", &pathInvalid0); err != nil {
+		t.Fatalf("loadPath(): err: %s", err)
+	}
+
+	leaf := pathInvalid0[0].Left
+	root := pathInvalid0[len(pathInvalid0)-1].Parent
+	if err := types.VerifyInclusion(leaf, root, 0, 5, pathInvalid0); err == nil {
+		t.Error("types.VerifyInclusion(): err = nil want Error")
+	}
+}
@@ -16,7 +16,6 @@ package types
 
 import (
 	"bytes"
-	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -32,18 +31,16 @@ type MerkleNodeHashes struct {
 // Path contains the necessary hashes to go from a leaf to a Merkle root.
 type Path []MerkleNodeHashes
 
-// Validate validates the integrity of a hash triplet.
+// Validate validates the integrity of a hash triplet using the default
+// hasher.
 func (h MerkleNodeHashes) Validate() error {
-	hash := sha256.New()
-
-	if _, err := hash.Write(h.Left); err != nil {
-		return err
-	}
-	if _, err := hash.Write(h.Right); err != nil {
-		return err
-	}
+	return h.ValidateWith(DefaultHasher)
+}
 
-	expected := hash.Sum(nil)
+// ValidateWith validates the integrity of a hash triplet using the given
+// hasher.
+func (h MerkleNodeHashes) ValidateWith(hasher Hasher) error {
+	expected := hasher.HashNode(h.Left, h.Right)
 
 	if bytes.Compare(h.Parent, expected) != 0 {
 		var (
@@ -56,10 +53,16 @@ func (h MerkleNodeHashes) Validate() error {
 	return nil
 }
 
-// Validate validates the integrity of a Merkle path.
+// Validate validates the integrity of a Merkle path using the default hasher.
 func (p Path) Validate() error {
+	return p.ValidateWith(DefaultHasher)
+}
+
+// ValidateWith validates the integrity of a Merkle path using the given
+// hasher.
+func (p Path) ValidateWith(hasher Hasher) error {
 	for i, h := range p {
-		if err := h.Validate(); err != nil {
+		if err := h.ValidateWith(hasher); err != nil {
 			return err
 		}
 
@@ -57,6 +57,23 @@ func TestMerkleNodeHashesValidate_OK(t *testing.T) {
 	}
 }
 
+func TestMerkleNodeHashesValidateWith(t *testing.T) {
+	var (
+		left  = testutil.RandomHash()
+		right = testutil.RandomHash()
+		h     = types.MerkleNodeHashes{Left: left, Right: right}
+	)
+
+	h.Parent = types.SHA1.HashNode(left, right)
+
+	if err := h.ValidateWith(types.SHA1); err != nil {
+		t.Errorf("h.ValidateWith(): err: %s", err)
+	}
+	if err := h.Validate(); err == nil {
+		t.Error("h.Validate(): err = nil want Error")
+	}
+}
+
 func TestMerkleNodeHashesValidate_Error(t *testing.T) {
 	h := types.MerkleNodeHashes{
 		Left:   testutil.RandomHash(),
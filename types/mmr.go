@@ -0,0 +1,190 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math/bits"
+)
+
+// MMRProof is a proof that a leaf is included in a Merkle Mountain Range.
+//
+// Siblings contains the hashes of the siblings of the nodes from the leaf to
+// the peak of its mountain, ordered bottom up. Nodes of a Merkle Mountain
+// Range never change, so these hashes remain valid after more leaves are
+// appended, and only need to be extended when the mountain of the leaf is
+// merged into a bigger one.
+type MMRProof struct {
+	Index    int
+	TreeSize int
+	Siblings [][]byte
+
+	// Peaks are the peaks of the range when it had TreeSize leaves, from
+	// left to right.
+	Peaks [][]byte
+}
+
+// MMRMountain returns the position of the mountain that contains the leaf at
+// the given index in a Merkle Mountain Range of the given size, the index of
+// the leaf within the mountain, and the height of the mountain.
+func MMRMountain(index, treeSize int) (mountain, local, height int) {
+	if index < 0 || index >= treeSize {
+		return -1, -1, -1
+	}
+
+	offset := 0
+	for h := bits.Len(uint(treeSize)) - 1; h >= 0; h-- {
+		if treeSize&(1<<uint(h)) == 0 {
+			continue
+		}
+		if index < offset+1<<uint(h) {
+			return mountain, index - offset, h
+		}
+		offset += 1 << uint(h)
+		mountain++
+	}
+
+	return -1, -1, -1
+}
+
+// BagPeaks returns the root of a Merkle Mountain Range given its peaks, using
+// the default hasher.
+func BagPeaks(peaks [][]byte) []byte {
+	return BagPeaksWith(DefaultHasher, peaks)
+}
+
+// BagPeaksWith returns the root of a Merkle Mountain Range given its peaks,
+// using the given hasher.
+//
+// Peaks are bagged from right to left, so that the root is the same as the
+// root of a tree of this package with the same leaves.
+func BagPeaksWith(hasher Hasher, peaks [][]byte) []byte {
+	if len(peaks) < 1 {
+		return nil
+	}
+
+	root := peaks[len(peaks)-1]
+	for i := len(peaks) - 2; i >= 0; i-- {
+		root = hasher.HashNode(peaks[i], root)
+	}
+
+	return root
+}
+
+// Verify checks that the proof proves the inclusion of the leaf hash in the
+// range with the given root, using the default hasher.
+func (p *MMRProof) Verify(leaf, root []byte) error {
+	return p.VerifyWith(DefaultHasher, leaf, root)
+}
+
+// VerifyWith checks that the proof proves the inclusion of the leaf hash in
+// the range with the given root, using the given hasher.
+func (p *MMRProof) VerifyWith(hasher Hasher, leaf, root []byte) error {
+	mountain, local, height := MMRMountain(p.Index, p.TreeSize)
+	if mountain < 0 {
+		return ErrIndexOutOfRange
+	}
+
+	if len(p.Siblings) != height || len(p.Peaks) != bits.OnesCount(uint(p.TreeSize)) {
+		return ErrIndexMismatch
+	}
+
+	node := leaf
+	for i, sibling := range p.Siblings {
+		if local&(1<<uint(i)) == 0 {
+			node = hasher.HashNode(node, sibling)
+		} else {
+			node = hasher.HashNode(sibling, node)
+		}
+	}
+
+	if !bytes.Equal(node, p.Peaks[mountain]) {
+		if height == 0 {
+			return ErrLeafMismatch
+		}
+		return ErrRootMismatch
+	}
+
+	if !bytes.Equal(BagPeaksWith(hasher, p.Peaks), root) {
+		return ErrRootMismatch
+	}
+
+	return nil
+}
+
+// JSONMMRProof is used to Marshal/Unmarshal MMRProof type with hex
+// representation.
+type JSONMMRProof struct {
+	Index    int      `json:"index"`
+	TreeSize int      `json:"treeSize"`
+	Siblings []string `json:"siblings"`
+	Peaks    []string `json:"peaks"`
+}
+
+// MarshalJSON implements encoding/json.Marshaler.MarshalJSON.
+func (p *MMRProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(JSONMMRProof{
+		Index:    p.Index,
+		TreeSize: p.TreeSize,
+		Siblings: encodeHashes(p.Siblings),
+		Peaks:    encodeHashes(p.Peaks),
+	})
+}
+
+// UnmarshalJSON implements encoding/json.Unmarshaler.UnmarshalJSON.
+func (p *MMRProof) UnmarshalJSON(data []byte) error {
+	var j JSONMMRProof
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	siblings, err := decodeHashes(j.Siblings)
+	if err != nil {
+		return err
+	}
+
+	peaks, err := decodeHashes(j.Peaks)
+	if err != nil {
+		return err
+	}
+
+	p.Index = j.Index
+	p.TreeSize = j.TreeSize
+	p.Siblings = siblings
+	p.Peaks = peaks
+
+	return nil
+}
+
+func encodeHashes(hashes [][]byte) []string {
+	s := make([]string, len(hashes))
+	for i, h := range hashes {
+		s[i] = hex.EncodeToString(h)
+	}
+	return s
+}
+
+func decodeHashes(s []string) ([][]byte, error) {
+	hashes := make([][]byte, len(s))
+	for i, h := range s {
+		var err error
+		if hashes[i], err = hex.DecodeString(h); err != nil {
+			return nil, err
+		}
+	}
+	return hashes, nil
+}
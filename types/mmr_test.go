@@ -0,0 +1,119 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/stratumn/merkle/testutil"
+	"github.com/stratumn/merkle/types"
+)
+
+func TestMMRMountain(t *testing.T) {
+	tests := []struct {
+		index, treeSize         int
+		mountain, local, height int
+	}{
+		{0, 1, 0, 0, 0},
+		{1, 2, 0, 1, 1},
+		{2, 3, 1, 0, 0},
+		{5, 7, 1, 1, 1},
+		{6, 7, 2, 0, 0},
+		{3, 7, 0, 3, 2},
+		{10, 11, 2, 0, 0},
+		{7, 8, 0, 7, 3},
+		{8, 8, -1, -1, -1},
+		{-1, 8, -1, -1, -1},
+	}
+
+	for _, test := range tests {
+		mountain, local, height := types.MMRMountain(test.index, test.treeSize)
+		if mountain != test.mountain || local != test.local || height != test.height {
+			t.Errorf("types.MMRMountain(%d, %d) = %d, %d, %d want %d, %d, %d",
+				test.index, test.treeSize, mountain, local, height,
+				test.mountain, test.local, test.height)
+		}
+	}
+}
+
+func TestBagPeaks(t *testing.T) {
+	var (
+		a, b, c = testutil.RandomHash(), testutil.RandomHash(), testutil.RandomHash()
+		h       = types.DefaultHasher
+	)
+
+	if got := types.BagPeaks(nil); got != nil {
+		t.Errorf("types.BagPeaks(nil) = %x want nil", got)
+	}
+	if got, want := types.BagPeaks([][]byte{a, b, c}), h.HashNode(a, h.HashNode(b, c)); !reflect.DeepEqual(got, want) {
+		t.Errorf("types.BagPeaks() = %x want %x", got, want)
+	}
+}
+
+func TestMMRProof_Error(t *testing.T) {
+	var (
+		a, b, c = testutil.RandomHash(), testutil.RandomHash(), testutil.RandomHash()
+		h       = types.DefaultHasher
+		ab      = h.HashNode(a, b)
+		root    = h.HashNode(ab, c)
+	)
+
+	tests := []struct {
+		name  string
+		proof types.MMRProof
+		leaf  []byte
+		err   error
+	}{
+		{"valid", types.MMRProof{Index: 1, TreeSize: 3, Siblings: [][]byte{a}, Peaks: [][]byte{ab, c}}, b, nil},
+		{"single", types.MMRProof{Index: 2, TreeSize: 3, Peaks: [][]byte{ab, c}}, c, nil},
+		{"out of range", types.MMRProof{Index: 3, TreeSize: 3, Peaks: [][]byte{ab, c}}, c, types.ErrIndexOutOfRange},
+		{"siblings", types.MMRProof{Index: 2, TreeSize: 3, Siblings: [][]byte{a}, Peaks: [][]byte{ab, c}}, c, types.ErrIndexMismatch},
+		{"peaks", types.MMRProof{Index: 2, TreeSize: 3, Peaks: [][]byte{c}}, c, types.ErrIndexMismatch},
+		{"leaf", types.MMRProof{Index: 2, TreeSize: 3, Peaks: [][]byte{ab, c}}, a, types.ErrLeafMismatch},
+		{"sibling", types.MMRProof{Index: 1, TreeSize: 3, Siblings: [][]byte{c}, Peaks: [][]byte{ab, c}}, b, types.ErrRootMismatch},
+		{"root", types.MMRProof{Index: 1, TreeSize: 3, Siblings: [][]byte{a}, Peaks: [][]byte{ab, ab}}, b, types.ErrRootMismatch},
+	}
+
+	for _, test := range tests {
+		if err := test.proof.Verify(test.leaf, root); err != test.err {
+			t.Errorf("%s: proof.Verify(): err = %v want %v", test.name, err, test.err)
+		}
+	}
+}
+
+func TestMMRProof_JSON(t *testing.T) {
+	proof := &types.MMRProof{
+		Index:    5,
+		TreeSize: 7,
+		Siblings: [][]byte{testutil.RandomHash()},
+		Peaks:    [][]byte{testutil.RandomHash(), testutil.RandomHash(), testutil.RandomHash()},
+	}
+
+	data, err := json.Marshal(proof)
+	if err != nil {
+		t.Fatalf("json.Marshal(): err: %s", err)
+	}
+
+	var got types.MMRProof
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("json.Unmarshal(): err: %s", err)
+	}
+
+	if !reflect.DeepEqual(&got, proof) {
+		t.Errorf("json.Unmarshal() = %#v want %#v", got, proof)
+	}
+}
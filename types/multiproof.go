@@ -0,0 +1,250 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+)
+
+// MultiProof is a proof that several leaves are included in a tree. It only
+// contains the hashes that cannot be computed from the leaves, ordered bottom
+// up and left to right.
+type MultiProof struct {
+	// Indices are the sorted indices of the leaves.
+	Indices  []int
+	TreeSize int
+	Hashes   [][]byte
+}
+
+// SortIndices sorts the indices of leaves and removes duplicates.
+func SortIndices(indices []int) []int {
+	sorted := append([]int(nil), indices...)
+	sort.Ints(sorted)
+
+	unique := sorted[:0]
+	for i, index := range sorted {
+		if i == 0 || index != sorted[i-1] {
+			unique = append(unique, index)
+		}
+	}
+
+	return unique
+}
+
+// NewMultiProof creates a proof for the leaves at the given indices of a tree
+// of the given size. The path function must return the path of a leaf.
+func NewMultiProof(indices []int, treeSize int, path func(index int) Path) (*MultiProof, error) {
+	var (
+		p     = &MultiProof{Indices: SortIndices(indices), TreeSize: treeSize}
+		paths = map[int]Path{}
+	)
+
+	_, err := walkMultiProof(p.Indices, treeSize, nil, nil, func(node *multiProofNode, isRight bool) ([]byte, error) {
+		leafPath, ok := paths[node.leaf]
+		if !ok {
+			leafPath = path(node.leaf)
+			paths[node.leaf] = leafPath
+		}
+		if node.depth >= len(leafPath) {
+			return nil, ErrIndexMismatch
+		}
+
+		h := leafPath[node.depth]
+		if isRight {
+			p.Hashes = append(p.Hashes, h.Left)
+		} else {
+			p.Hashes = append(p.Hashes, h.Right)
+		}
+
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Verify checks that the proof proves the inclusion of the leaf hashes in the
+// tree with the given root, using the default hasher. The leaves must be in
+// the same order as the indices.
+func (p *MultiProof) Verify(leaves [][]byte, root []byte) error {
+	return p.VerifyWith(DefaultHasher, leaves, root)
+}
+
+// VerifyWith checks that the proof proves the inclusion of the leaf hashes in
+// the tree with the given root, using the given hasher. The leaves must be in
+// the same order as the indices.
+func (p *MultiProof) VerifyWith(hasher Hasher, leaves [][]byte, root []byte) error {
+	if len(leaves) != len(p.Indices) {
+		return errors.New("number of leaves does not match the number of indices")
+	}
+
+	hashes := p.Hashes
+
+	computed, err := walkMultiProof(p.Indices, p.TreeSize, leaves, hasher, func(node *multiProofNode, isRight bool) ([]byte, error) {
+		if len(hashes) < 1 {
+			return nil, ErrIndexMismatch
+		}
+		h := hashes[0]
+		hashes = hashes[1:]
+		return h, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(hashes) > 0 {
+		return ErrIndexMismatch
+	}
+
+	if !bytes.Equal(computed, root) {
+		return ErrRootMismatch
+	}
+
+	return nil
+}
+
+// multiProofNode is a node of a tree that is an ancestor of a leaf of a
+// multiproof.
+type multiProofNode struct {
+	// The index of the node within its row.
+	index int
+
+	// The index of a leaf below the node.
+	leaf int
+
+	// The number of hashes between the leaf and the node in the path of the
+	// leaf.
+	depth int
+
+	hash []byte
+}
+
+// Walks the rows of the tree starting at the leaves with the given sorted
+// indices and returns the root. The sibling function is called, in the order
+// the hashes appear in the proof, with the nodes whose sibling cannot be
+// computed and must return the hash of the sibling. If hasher is nil, no
+// hashes are computed.
+func walkMultiProof(
+	indices []int,
+	treeSize int,
+	leaves [][]byte,
+	hasher Hasher,
+	sibling func(node *multiProofNode, isRight bool) ([]byte, error),
+) ([]byte, error) {
+	if len(indices) < 1 {
+		return nil, errors.New("multiproof should have at least one leaf")
+	}
+
+	row := make([]*multiProofNode, len(indices))
+	for i, index := range indices {
+		if index < 0 || index >= treeSize || i > 0 && index <= indices[i-1] {
+			return nil, ErrIndexOutOfRange
+		}
+		row[i] = &multiProofNode{index: index, leaf: index}
+		if leaves != nil {
+			row[i].hash = leaves[i]
+		}
+	}
+
+	for n := treeSize; n > 1; n = (n + 1) / 2 {
+		next := make([]*multiProofNode, 0, len(row))
+
+		for k := 0; k < len(row); k++ {
+			var (
+				node        = row[k]
+				left, right []byte
+			)
+
+			switch {
+			case node.index%2 == 0 && node.index == n-1:
+				// The node is an orphan.
+				node.index /= 2
+				next = append(next, node)
+				continue
+			case node.index%2 == 0 && k+1 < len(row) && row[k+1].index == node.index+1:
+				left, right = node.hash, row[k+1].hash
+				k++
+			case node.index%2 == 0:
+				s, err := sibling(node, false)
+				if err != nil {
+					return nil, err
+				}
+				left, right = node.hash, s
+			default:
+				s, err := sibling(node, true)
+				if err != nil {
+					return nil, err
+				}
+				left, right = s, node.hash
+			}
+
+			if hasher != nil {
+				node.hash = hasher.HashNode(left, right)
+			}
+			node.index /= 2
+			node.depth++
+			next = append(next, node)
+		}
+
+		row = next
+	}
+
+	return row[0].hash, nil
+}
+
+// JSONMultiProof is used to Marshal/Unmarshal MultiProof type with hex
+// representation.
+type JSONMultiProof struct {
+	Indices  []int    `json:"indices"`
+	TreeSize int      `json:"treeSize"`
+	Hashes   []string `json:"hashes"`
+}
+
+// MarshalJSON implements encoding/json.Marshaler.MarshalJSON.
+func (p *MultiProof) MarshalJSON() ([]byte, error) {
+	j := JSONMultiProof{
+		Indices:  p.Indices,
+		TreeSize: p.TreeSize,
+		Hashes:   make([]string, len(p.Hashes)),
+	}
+	for i, h := range p.Hashes {
+		j.Hashes[i] = hex.EncodeToString(h)
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON implements encoding/json.Unmarshaler.UnmarshalJSON.
+func (p *MultiProof) UnmarshalJSON(data []byte) error {
+	var j JSONMultiProof
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	p.Indices = j.Indices
+	p.TreeSize = j.TreeSize
+	p.Hashes = make([][]byte, len(j.Hashes))
+	for i, h := range j.Hashes {
+		var err error
+		if p.Hashes[i], err = hex.DecodeString(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
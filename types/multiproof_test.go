@@ -0,0 +1,141 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/stratumn/merkle/testutil"
+	"github.com/stratumn/merkle/types"
+)
+
+func loadABCDE(t *testing.T) (func(int) types.Path, [][]byte, []byte) {
+	var (
+		paths  = make([]types.Path, 5)
+		leaves = make([][]byte, 5)
+	)
+	for i := range paths {
+		if err := loadPath(fmt.Sprintf("testdata/path-abcde-%d.json", i), &paths[i]); err != nil {
+			t.Fatalf("loadPath(): err: %s", err)
+		}
+		leaf := sha256.Sum256([]byte{byte('a' + i)})
+		leaves[i] = leaf[:]
+	}
+	root, _ := hex.DecodeString("d71f8983ad4ee170f8129f1ebcdd7440be7798d8e1c80420bf11f1eced610dba")
+	return func(i int) types.Path { return paths[i] }, leaves, root
+}
+
+func TestMultiProof(t *testing.T) {
+	path, leaves, root := loadABCDE(t)
+
+	tests := []struct {
+		indices []int
+		sorted  []int
+		hashes  int
+	}{
+		{[]int{0}, []int{0}, 3},
+		{[]int{4}, []int{4}, 1},
+		{[]int{4, 1, 0, 1}, []int{0, 1, 4}, 1},
+		{[]int{0, 2}, []int{0, 2}, 3},
+		{[]int{0, 1, 2, 3, 4}, []int{0, 1, 2, 3, 4}, 0},
+	}
+
+	for _, test := range tests {
+		p, err := types.NewMultiProof(test.indices, 5, path)
+		if err != nil {
+			t.Fatalf("types.NewMultiProof(%v): err: %s", test.indices, err)
+		}
+		if got, want := p.Indices, test.sorted; !reflect.DeepEqual(got, want) {
+			t.Errorf("types.NewMultiProof(%v): p.Indices = %v want %v", test.indices, got, want)
+		}
+		if got, want := len(p.Hashes), test.hashes; got != want {
+			t.Errorf("types.NewMultiProof(%v): len(p.Hashes) = %d want %d", test.indices, got, want)
+		}
+
+		proved := make([][]byte, len(p.Indices))
+		for i, index := range p.Indices {
+			proved[i] = leaves[index]
+		}
+
+		if err := p.Verify(proved, root); err != nil {
+			t.Errorf("types.NewMultiProof(%v): p.Verify(): err: %s", test.indices, err)
+		}
+
+		proved[0] = testutil.RandomHash()
+		if err := p.Verify(proved, root); err != types.ErrRootMismatch {
+			t.Errorf("types.NewMultiProof(%v): p.Verify(): err = %v want %v", test.indices, err, types.ErrRootMismatch)
+		}
+	}
+}
+
+func TestMultiProof_Error(t *testing.T) {
+	path, leaves, root := loadABCDE(t)
+
+	if _, err := types.NewMultiProof(nil, 5, path); err == nil {
+		t.Error("types.NewMultiProof(): err = nil want Error")
+	}
+	if _, err := types.NewMultiProof([]int{5}, 5, path); err != types.ErrIndexOutOfRange {
+		t.Errorf("types.NewMultiProof(): err = %v want %v", err, types.ErrIndexOutOfRange)
+	}
+
+	p, err := types.NewMultiProof([]int{0, 2}, 5, path)
+	if err != nil {
+		t.Fatalf("types.NewMultiProof(): err: %s", err)
+	}
+	proved := [][]byte{leaves[0], leaves[2]}
+
+	if err := p.Verify(proved[:1], root); err == nil {
+		t.Error("p.Verify(): err = nil want Error")
+	}
+
+	short := *p
+	short.Hashes = short.Hashes[1:]
+	if err := short.Verify(proved, root); err != types.ErrIndexMismatch {
+		t.Errorf("short.Verify(): err = %v want %v", err, types.ErrIndexMismatch)
+	}
+
+	long := *p
+	long.Hashes = append(long.Hashes, testutil.RandomHash())
+	if err := long.Verify(proved, root); err != types.ErrIndexMismatch {
+		t.Errorf("long.Verify(): err = %v want %v", err, types.ErrIndexMismatch)
+	}
+}
+
+func TestMultiProofJSON(t *testing.T) {
+	path, _, _ := loadABCDE(t)
+
+	p, err := types.NewMultiProof([]int{1, 3}, 5, path)
+	if err != nil {
+		t.Fatalf("types.NewMultiProof(): err: %s", err)
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatalf("json.Marshal(): err: %s", err)
+	}
+
+	var got types.MultiProof
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("json.Unmarshal(): err: %s", err)
+	}
+	if !reflect.DeepEqual(&got, p) {
+		t.Errorf("json.Unmarshal() = %#v want %#v", got, p)
+	}
+}
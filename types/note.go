@@ -0,0 +1,416 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// ErrMalformedNote is returned when a signed note is not correctly
+	// formatted.
+	ErrMalformedNote = errors.New("malformed note")
+
+	// ErrUnverifiedNote is returned when a note has no signature from any of
+	// the known keys.
+	ErrUnverifiedNote = errors.New("note has no verifiable signature")
+
+	// ErrInvalidNoteKey is returned when a note signer or verifier key is not
+	// correctly formatted.
+	ErrInvalidNoteKey = errors.New("invalid note key")
+)
+
+// Algorithm identifier of Ed25519 note keys.
+const noteAlgEd25519 = 1
+
+// Maximum number of signatures of a note, to limit the work done when
+// verifying notes from untrusted sources.
+const maxNoteSignatures = 100
+
+// Note is a signed note, as used by the Go checksum database and described by
+// the C2SP signed-note specification.
+//
+// A note is a text ending with a newline, followed by a blank line and one
+// signature per line. A signature line is an em dash, a space, the name of the
+// key, a space and the base64 encoding of the key hash followed by the
+// signature.
+type Note struct {
+	Text       string
+	Signatures []NoteSignature
+}
+
+// NoteSignature is a signature of a note.
+type NoteSignature struct {
+	Name    string
+	KeyHash uint32
+
+	// Signature does not include the key hash.
+	Signature []byte
+}
+
+// ParseNote parses a signed note without verifying its signatures.
+func ParseNote(msg []byte) (*Note, error) {
+	for i := 0; i < len(msg); {
+		r, size := utf8.DecodeRune(msg[i:])
+		if r < 0x20 && r != '\n' || r == utf8.RuneError && size == 1 {
+			return nil, ErrMalformedNote
+		}
+		i += size
+	}
+
+	split := bytes.LastIndex(msg, []byte("\n\n"))
+	if split < 0 {
+		return nil, ErrMalformedNote
+	}
+
+	text, lines := msg[:split+1], msg[split+2:]
+	if len(lines) == 0 || lines[len(lines)-1] != '\n' {
+		return nil, ErrMalformedNote
+	}
+
+	n := &Note{Text: string(text)}
+
+	for _, line := range strings.SplitAfter(string(lines), "\n") {
+		if line == "" {
+			continue
+		}
+		if len(n.Signatures) == maxNoteSignatures {
+			return nil, ErrMalformedNote
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		if !strings.HasPrefix(line, "— ") {
+			return nil, ErrMalformedNote
+		}
+
+		name, b64, _ := strings.Cut(strings.TrimPrefix(line, "— "), " ")
+		sig, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil || !isValidNoteKeyName(name) || len(sig) < 5 {
+			return nil, ErrMalformedNote
+		}
+
+		n.Signatures = append(n.Signatures, NoteSignature{
+			Name:      name,
+			KeyHash:   binary.BigEndian.Uint32(sig),
+			Signature: sig[4:],
+		})
+	}
+
+	return n, nil
+}
+
+// Sign adds the signature of the given signer to the note, replacing a
+// previous signature with the same key.
+func (n *Note) Sign(signer *NoteSigner) error {
+	if !isValidNoteText(n.Text) {
+		return ErrMalformedNote
+	}
+
+	sig, err := signer.signer.Sign([]byte(n.Text))
+	if err != nil {
+		return err
+	}
+
+	s := NoteSignature{Name: signer.name, KeyHash: signer.hash, Signature: sig}
+	for i, other := range n.Signatures {
+		if other.Name == s.Name && other.KeyHash == s.KeyHash {
+			n.Signatures[i] = s
+			return nil
+		}
+	}
+	n.Signatures = append(n.Signatures, s)
+
+	return nil
+}
+
+// Returns whether one of the signatures was made with the key of the given
+// signature.
+func containsNoteKey(sigs []NoteSignature, sig NoteSignature) bool {
+	for _, other := range sigs {
+		if other.Name == sig.Name && other.KeyHash == sig.KeyHash {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify checks the signatures of the note made with the given keys, and
+// ignores the other signatures. It returns the verified signatures, and
+// ErrUnverifiedNote if there are none. It returns ErrInvalidSignature if a
+// signature made with one of the keys is invalid.
+func (n *Note) Verify(verifiers ...*NoteVerifier) ([]NoteSignature, error) {
+	if !isValidNoteText(n.Text) {
+		return nil, ErrMalformedNote
+	}
+
+	var verified []NoteSignature
+
+	for _, sig := range n.Signatures {
+		if containsNoteKey(verified, sig) {
+			continue
+		}
+		for _, v := range verifiers {
+			if sig.Name != v.name || sig.KeyHash != v.hash {
+				continue
+			}
+			if err := v.verifier.Verify([]byte(n.Text), sig.Signature); err != nil {
+				return nil, err
+			}
+			verified = append(verified, sig)
+			break
+		}
+	}
+
+	if len(verified) < 1 {
+		return nil, ErrUnverifiedNote
+	}
+
+	return verified, nil
+}
+
+// MarshalText implements encoding.TextMarshaler.MarshalText. It returns the
+// signed note.
+func (n *Note) MarshalText() ([]byte, error) {
+	if !isValidNoteText(n.Text) || len(n.Signatures) < 1 {
+		return nil, ErrMalformedNote
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(n.Text)
+	buf.WriteString("\n")
+
+	for _, sig := range n.Signatures {
+		if !isValidNoteKeyName(sig.Name) {
+			return nil, ErrMalformedNote
+		}
+
+		raw := binary.BigEndian.AppendUint32(nil, sig.KeyHash)
+		raw = append(raw, sig.Signature...)
+		fmt.Fprintf(&buf, "— %s %s\n", sig.Name, base64.StdEncoding.EncodeToString(raw))
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.UnmarshalText. It does not
+// verify the signatures.
+func (n *Note) UnmarshalText(text []byte) error {
+	parsed, err := ParseNote(text)
+	if err != nil {
+		return err
+	}
+	*n = *parsed
+	return nil
+}
+
+// NoteSigner signs notes using an Ed25519 key with a name.
+type NoteSigner struct {
+	name   string
+	hash   uint32
+	signer *Ed25519Signer
+}
+
+// NewNoteSigner creates a NoteSigner from an encoded private key, which has
+// the form PRIVATE+KEY+<name>+<hash>+<key>, where the hash is the hex encoded
+// key hash and the key is the base64 encoding of the algorithm identifier
+// followed by the Ed25519 seed.
+func NewNoteSigner(skey string) (*NoteSigner, error) {
+	fields := strings.SplitN(skey, "+", 5)
+	if len(fields) != 5 || fields[0] != "PRIVATE" || fields[1] != "KEY" {
+		return nil, ErrInvalidNoteKey
+	}
+
+	hash, key, err := parseNoteKey(fields[2], fields[3], fields[4])
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != ed25519.SeedSize {
+		return nil, ErrInvalidNoteKey
+	}
+
+	s, err := NewEd25519NoteSigner(fields[2], ed25519.NewKeyFromSeed(key))
+	if err != nil {
+		return nil, err
+	}
+	if s.hash != hash {
+		return nil, ErrInvalidNoteKey
+	}
+
+	return s, nil
+}
+
+// NewEd25519NoteSigner creates a NoteSigner from a name and an Ed25519
+// private key.
+func NewEd25519NoteSigner(name string, key ed25519.PrivateKey) (*NoteSigner, error) {
+	if !isValidNoteKeyName(name) {
+		return nil, ErrInvalidNoteKey
+	}
+
+	signer, err := NewEd25519Signer(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NoteSigner{
+		name:   name,
+		hash:   noteKeyHash(name, key.Public().(ed25519.PublicKey)),
+		signer: signer,
+	}, nil
+}
+
+// GenerateNoteKey generates an Ed25519 key with the given name and returns the
+// encoded private key and verifier key.
+func GenerateNoteKey(rand io.Reader, name string) (skey, vkey string, err error) {
+	_, key, err := ed25519.GenerateKey(rand)
+	if err != nil {
+		return "", "", err
+	}
+
+	s, err := NewEd25519NoteSigner(name, key)
+	if err != nil {
+		return "", "", err
+	}
+
+	seed := append([]byte{noteAlgEd25519}, key.Seed()...)
+	skey = fmt.Sprintf("PRIVATE+KEY+%s+%08x+%s", name, s.hash, base64.StdEncoding.EncodeToString(seed))
+
+	return skey, s.VerifierKey(), nil
+}
+
+// Name returns the name of the key.
+func (s *NoteSigner) Name() string {
+	return s.name
+}
+
+// KeyHash returns the hash identifying the key.
+func (s *NoteSigner) KeyHash() uint32 {
+	return s.hash
+}
+
+// VerifierKey returns the encoded verifier key of the signer.
+func (s *NoteSigner) VerifierKey() string {
+	key := append([]byte{noteAlgEd25519}, s.signer.Public().(ed25519.PublicKey)...)
+	return fmt.Sprintf("%s+%08x+%s", s.name, s.hash, base64.StdEncoding.EncodeToString(key))
+}
+
+// NoteVerifier verifies the signatures of notes made with an Ed25519 key with
+// a name.
+type NoteVerifier struct {
+	name     string
+	hash     uint32
+	verifier *Ed25519Verifier
+}
+
+// NewNoteVerifier creates a NoteVerifier from an encoded verifier key, which
+// has the form <name>+<hash>+<key>, where the hash is the hex encoded key hash
+// and the key is the base64 encoding of the algorithm identifier followed by
+// the Ed25519 public key.
+func NewNoteVerifier(vkey string) (*NoteVerifier, error) {
+	fields := strings.SplitN(vkey, "+", 3)
+	if len(fields) != 3 {
+		return nil, ErrInvalidNoteKey
+	}
+
+	hash, key, err := parseNoteKey(fields[0], fields[1], fields[2])
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != ed25519.PublicKeySize || noteKeyHash(fields[0], key) != hash {
+		return nil, ErrInvalidNoteKey
+	}
+
+	return NewEd25519NoteVerifier(fields[0], key)
+}
+
+// NewEd25519NoteVerifier creates a NoteVerifier from a name and an Ed25519
+// public key.
+func NewEd25519NoteVerifier(name string, key ed25519.PublicKey) (*NoteVerifier, error) {
+	if !isValidNoteKeyName(name) {
+		return nil, ErrInvalidNoteKey
+	}
+
+	verifier, err := NewEd25519Verifier(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NoteVerifier{name: name, hash: noteKeyHash(name, key), verifier: verifier}, nil
+}
+
+// Name returns the name of the key.
+func (v *NoteVerifier) Name() string {
+	return v.name
+}
+
+// KeyHash returns the hash identifying the key.
+func (v *NoteVerifier) KeyHash() uint32 {
+	return v.hash
+}
+
+// Parses the fields of an encoded key and returns the key hash and the key
+// without its algorithm identifier.
+func parseNoteKey(name, hash16, key64 string) (uint32, []byte, error) {
+	hash, err := strconv.ParseUint(hash16, 16, 32)
+	if err != nil || len(hash16) != 8 || !isValidNoteKeyName(name) {
+		return 0, nil, ErrInvalidNoteKey
+	}
+
+	key, err := base64.StdEncoding.DecodeString(key64)
+	if err != nil || len(key) < 1 {
+		return 0, nil, ErrInvalidNoteKey
+	}
+	if key[0] != noteAlgEd25519 {
+		return 0, nil, fmt.Errorf("%w: unsupported algorithm %d", ErrInvalidNoteKey, key[0])
+	}
+
+	return uint32(hash), key[1:], nil
+}
+
+// Returns the hash of an Ed25519 key, which is the first four bytes of the
+// SHA-256 hash of the name, a newline, the algorithm identifier and the key.
+func noteKeyHash(name string, key ed25519.PublicKey) uint32 {
+	h := sha256.New()
+	h.Write([]byte(name))
+	h.Write([]byte{'\n', noteAlgEd25519})
+	h.Write(key)
+	return binary.BigEndian.Uint32(h.Sum(nil))
+}
+
+func isValidNoteKeyName(name string) bool {
+	return name != "" && utf8.ValidString(name) && strings.IndexFunc(name, unicode.IsSpace) < 0 && !strings.Contains(name, "+")
+}
+
+func isValidNoteText(text string) bool {
+	if !strings.HasSuffix(text, "\n") || !utf8.ValidString(text) {
+		return false
+	}
+	for _, r := range text {
+		if r < 0x20 && r != '\n' {
+			return false
+		}
+	}
+	return true
+}
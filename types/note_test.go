@@ -0,0 +1,217 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types_test
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stratumn/merkle/types"
+)
+
+// Example keys and notes published in the documentation of the
+// golang.org/x/mod/sumdb/note package.
+const (
+	peterNeumannSkey = "PRIVATE+KEY+PeterNeumann+c74f20a3+AYEKFALVFGyNhPJEMzD1QIDr+Y7hfZx09iUvxdXHKDFz"
+	peterNeumannVkey = "PeterNeumann+c74f20a3+ARpc2QcUPDhMQegwxbzhKqiBfsVkmqq/LDE4izWy10TW"
+
+	peterNeumannText = "If you think cryptography is the answer to your problem,\n" +
+		"then you don't know what your problem is.\n"
+
+	peterNeumannNote = peterNeumannText +
+		"\n" +
+		"— PeterNeumann x08go/ZJkuBS9UG/SffcvIAQxVBtiFupLLr8pAcElZInNIuGUgYN1FFYC2pZSNXgKvqfqdngotpRZb6KE6RyyBwJnAM=\n"
+
+	doublySignedNote = peterNeumannNote +
+		"— EnochRoot rwz+eBzmZa0SO3NbfRGzPCpDckykFXSdeX+MNtCOXm2/5n2tiOHp+vAF1aGrQ5ovTG01oOTGwnWLox33WWd1RvMc+QQ=\n"
+)
+
+func TestNote(t *testing.T) {
+	verifier, err := types.NewNoteVerifier(peterNeumannVkey)
+	if err != nil {
+		t.Fatalf("types.NewNoteVerifier(): err: %s", err)
+	}
+
+	for _, msg := range []string{peterNeumannNote, doublySignedNote} {
+		n, err := types.ParseNote([]byte(msg))
+		if err != nil {
+			t.Fatalf("types.ParseNote(): err: %s", err)
+		}
+		if got, want := n.Text, peterNeumannText; got != want {
+			t.Errorf("n.Text = %q want %q", got, want)
+		}
+
+		sigs, err := n.Verify(verifier)
+		if err != nil {
+			t.Fatalf("n.Verify(): err: %s", err)
+		}
+		if len(sigs) != 1 || sigs[0].Name != "PeterNeumann" || sigs[0].KeyHash != 0xc74f20a3 {
+			t.Errorf("n.Verify() = %v want the signature of PeterNeumann", sigs)
+		}
+
+		got, err := n.MarshalText()
+		if err != nil {
+			t.Fatalf("n.MarshalText(): err: %s", err)
+		}
+		if string(got) != msg {
+			t.Errorf("n.MarshalText() = %q want %q", got, msg)
+		}
+	}
+}
+
+func TestNoteSign(t *testing.T) {
+	signer, err := types.NewNoteSigner(peterNeumannSkey)
+	if err != nil {
+		t.Fatalf("types.NewNoteSigner(): err: %s", err)
+	}
+	if got, want := signer.VerifierKey(), peterNeumannVkey; got != want {
+		t.Errorf("signer.VerifierKey() = %q want %q", got, want)
+	}
+
+	// Ed25519 signatures are deterministic.
+	n := types.Note{Text: peterNeumannText}
+	if err := n.Sign(signer); err != nil {
+		t.Fatalf("n.Sign(): err: %s", err)
+	}
+	got, err := n.MarshalText()
+	if err != nil {
+		t.Fatalf("n.MarshalText(): err: %s", err)
+	}
+	if string(got) != peterNeumannNote {
+		t.Errorf("n.MarshalText() = %q want %q", got, peterNeumannNote)
+	}
+
+	// Signing again replaces the signature.
+	if err := n.Sign(signer); err != nil {
+		t.Fatalf("n.Sign(): err: %s", err)
+	}
+	if got, want := len(n.Signatures), 1; got != want {
+		t.Errorf("len(n.Signatures) = %d want %d", got, want)
+	}
+
+	if err := (&types.Note{Text: "no newline"}).Sign(signer); err != types.ErrMalformedNote {
+		t.Errorf("n.Sign() err = %v want %v", err, types.ErrMalformedNote)
+	}
+}
+
+func TestNoteCosign(t *testing.T) {
+	skey, vkey, err := types.GenerateNoteKey(rand.Reader, "EnochRoot")
+	if err != nil {
+		t.Fatalf("types.GenerateNoteKey(): err: %s", err)
+	}
+	signer, err := types.NewNoteSigner(skey)
+	if err != nil {
+		t.Fatalf("types.NewNoteSigner(): err: %s", err)
+	}
+	verifier, err := types.NewNoteVerifier(vkey)
+	if err != nil {
+		t.Fatalf("types.NewNoteVerifier(): err: %s", err)
+	}
+	peterNeumann, _ := types.NewNoteVerifier(peterNeumannVkey)
+
+	n, err := types.ParseNote([]byte(peterNeumannNote))
+	if err != nil {
+		t.Fatalf("types.ParseNote(): err: %s", err)
+	}
+	if err := n.Sign(signer); err != nil {
+		t.Fatalf("n.Sign(): err: %s", err)
+	}
+
+	sigs, err := n.Verify(peterNeumann, verifier)
+	if err != nil {
+		t.Fatalf("n.Verify(): err: %s", err)
+	}
+	if got, want := len(sigs), 2; got != want {
+		t.Errorf("len(n.Verify()) = %d want %d", got, want)
+	}
+}
+
+func TestNoteVerify_Error(t *testing.T) {
+	verifier, _ := types.NewNoteVerifier(peterNeumannVkey)
+	_, other, _ := types.GenerateNoteKey(rand.Reader, "PeterNeumann")
+	otherVerifier, _ := types.NewNoteVerifier(other)
+
+	tests := []struct {
+		name     string
+		msg      string
+		verifier *types.NoteVerifier
+		err      error
+	}{
+		{"unknown key", peterNeumannNote, otherVerifier, types.ErrUnverifiedNote},
+		{"text", strings.Replace(peterNeumannNote, "answer", "question", 1), verifier, types.ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		n, err := types.ParseNote([]byte(tt.msg))
+		if err != nil {
+			t.Fatalf("%s: types.ParseNote(): err: %s", tt.name, err)
+		}
+		if _, err := n.Verify(tt.verifier); err != tt.err {
+			t.Errorf("%s: n.Verify() err = %v want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestParseNote_Error(t *testing.T) {
+	sig := "— PeterNeumann x08go/ZJkuBS9UG/SffcvIAQxVBtiFupLLr8pAcElZInNIuGUgYN1FFYC2pZSNXgKvqfqdngotpRZb6KE6RyyBwJnAM=\n"
+
+	msgs := []string{
+		"",
+		peterNeumannText,
+		peterNeumannText + "\n",
+		peterNeumannText + "\n" + strings.TrimSuffix(sig, "\n"),
+		peterNeumannText + "\n" + strings.Replace(sig, "—", "-", 1),
+		peterNeumannText + "\n" + strings.Replace(sig, "x08go", "x08g!", 1),
+		peterNeumannText + "\n" + "— PeterNeumann AAAA\n",
+		peterNeumannText + "\n" + "— Peter+Neumann x08go/ZJ\n",
+		"tab\tin text\n\n" + sig,
+		"\xffinvalid\n\n" + sig,
+		peterNeumannText + "\n" + strings.Repeat(sig, 101),
+	}
+
+	for _, msg := range msgs {
+		if _, err := types.ParseNote([]byte(msg)); err != types.ErrMalformedNote {
+			t.Errorf("types.ParseNote(%q) err = %v want %v", msg, err, types.ErrMalformedNote)
+		}
+	}
+}
+
+func TestNewNoteVerifier_Error(t *testing.T) {
+	keys := []string{
+		"",
+		"PeterNeumann",
+		"PeterNeumann+c74f20a3",
+		"PeterNeumann+c74f20a4+ARpc2QcUPDhMQegwxbzhKqiBfsVkmqq/LDE4izWy10TW",
+		"PeterNeumann+c74f20a+ARpc2QcUPDhMQegwxbzhKqiBfsVkmqq/LDE4izWy10TW",
+		"PeterNeumann+c74f20a3+ARpc2QcUPDhMQegwxbzhKqiBfsVkmqq/LDE4izWy10T",
+		"PeterNeumann+c74f20a3+Ahpc2QcUPDhMQegwxbzhKqiBfsVkmqq/LDE4izWy10TW",
+		"Peter Neumann+c74f20a3+ARpc2QcUPDhMQegwxbzhKqiBfsVkmqq/LDE4izWy10TW",
+	}
+
+	for _, key := range keys {
+		if _, err := types.NewNoteVerifier(key); !errors.Is(err, types.ErrInvalidNoteKey) {
+			t.Errorf("types.NewNoteVerifier(%q) err = %v want %v", key, err, types.ErrInvalidNoteKey)
+		}
+	}
+
+	if _, err := types.NewNoteSigner(peterNeumannVkey); !errors.Is(err, types.ErrInvalidNoteKey) {
+		t.Errorf("types.NewNoteSigner(vkey) err = %v want %v", err, types.ErrInvalidNoteKey)
+	}
+	if _, err := types.NewNoteSigner(strings.Replace(peterNeumannSkey, "c74f20a3", "c74f20a4", 1)); !errors.Is(err, types.ErrInvalidNoteKey) {
+		t.Errorf("types.NewNoteSigner(wrong hash) err = %v want %v", err, types.ErrInvalidNoteKey)
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+)
+
+// inclusionProofVersion is the version of the binary encoding of an
+// InclusionProof.
+const inclusionProofVersion = 1
+
+// InclusionProof is a compact proof that a leaf is included in a tree. Unlike
+// a Path, it only contains the hashes of the siblings of the nodes from the
+// leaf to the root, ordered bottom up.
+type InclusionProof struct {
+	Index    int
+	TreeSize int
+	Siblings [][]byte
+}
+
+// NewInclusionProof creates a compact proof from the path of the leaf at the
+// given index of a tree of the given size.
+func NewInclusionProof(index, treeSize int, path Path) (*InclusionProof, error) {
+	if index < 0 || index >= treeSize {
+		return nil, ErrIndexOutOfRange
+	}
+
+	layout := pathLayout(index, treeSize)
+	if len(layout) != len(path) {
+		return nil, ErrIndexMismatch
+	}
+
+	p := &InclusionProof{
+		Index:    index,
+		TreeSize: treeSize,
+		Siblings: make([][]byte, len(path)),
+	}
+
+	for i, isRight := range layout {
+		h := path[i]
+		node, sibling := h.Left, h.Right
+		if isRight {
+			node, sibling = h.Right, h.Left
+		}
+		if i > 0 && !bytes.Equal(node, path[i-1].Parent) {
+			return nil, ErrIndexMismatch
+		}
+		p.Siblings[i] = sibling
+	}
+
+	return p, nil
+}
+
+// Path converts the proof to a path given the hash of the leaf, using the
+// default hasher.
+func (p *InclusionProof) Path(leaf []byte) (Path, error) {
+	return p.PathWith(DefaultHasher, leaf)
+}
+
+// PathWith converts the proof to a path given the hash of the leaf, using the
+// given hasher.
+func (p *InclusionProof) PathWith(hasher Hasher, leaf []byte) (Path, error) {
+	if p.Index < 0 || p.Index >= p.TreeSize {
+		return nil, ErrIndexOutOfRange
+	}
+
+	layout := pathLayout(p.Index, p.TreeSize)
+	if len(layout) != len(p.Siblings) {
+		return nil, ErrIndexMismatch
+	}
+
+	var (
+		path = make(Path, len(layout))
+		node = leaf
+	)
+
+	for i, isRight := range layout {
+		h := &path[i]
+		if isRight {
+			h.Left, h.Right = p.Siblings[i], node
+		} else {
+			h.Left, h.Right = node, p.Siblings[i]
+		}
+		h.Parent = hasher.HashNode(h.Left, h.Right)
+		node = h.Parent
+	}
+
+	return path, nil
+}
+
+// Verify checks that the proof proves the inclusion of the leaf hash in the
+// tree with the given root, using the default hasher.
+func (p *InclusionProof) Verify(leaf, root []byte) error {
+	return p.VerifyWith(DefaultHasher, leaf, root)
+}
+
+// VerifyWith checks that the proof proves the inclusion of the leaf hash in
+// the tree with the given root, using the given hasher.
+func (p *InclusionProof) VerifyWith(hasher Hasher, leaf, root []byte) error {
+	path, err := p.PathWith(hasher, leaf)
+	if err != nil {
+		return err
+	}
+
+	computed := leaf
+	if len(path) > 0 {
+		computed = path[len(path)-1].Parent
+	}
+
+	if !bytes.Equal(computed, root) {
+		return ErrRootMismatch
+	}
+
+	return nil
+}
+
+// JSONInclusionProof is used to Marshal/Unmarshal InclusionProof type with hex
+// representation.
+type JSONInclusionProof struct {
+	Index    int      `json:"index"`
+	TreeSize int      `json:"treeSize"`
+	Siblings []string `json:"siblings"`
+}
+
+// MarshalJSON implements encoding/json.Marshaler.MarshalJSON.
+func (p *InclusionProof) MarshalJSON() ([]byte, error) {
+	j := JSONInclusionProof{
+		Index:    p.Index,
+		TreeSize: p.TreeSize,
+		Siblings: make([]string, len(p.Siblings)),
+	}
+	for i, s := range p.Siblings {
+		j.Siblings[i] = hex.EncodeToString(s)
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON implements encoding/json.Unmarshaler.UnmarshalJSON.
+func (p *InclusionProof) UnmarshalJSON(data []byte) error {
+	var j JSONInclusionProof
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	p.Index = j.Index
+	p.TreeSize = j.TreeSize
+	p.Siblings = make([][]byte, len(j.Siblings))
+	for i, s := range j.Siblings {
+		var err error
+		if p.Siblings[i], err = hex.DecodeString(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.MarshalBinary.
+//
+// The encoding is a version byte followed by the index, the tree size, the
+// size of a hash and the number of siblings as unsigned varints, followed by
+// the siblings.
+func (p *InclusionProof) MarshalBinary() ([]byte, error) {
+	if p.Index < 0 || p.TreeSize < 0 {
+		return nil, ErrIndexOutOfRange
+	}
+
+	size := 0
+	if len(p.Siblings) > 0 {
+		size = len(p.Siblings[0])
+	}
+
+	buf := make([]byte, 1, 1+4*binary.MaxVarintLen64+size*len(p.Siblings))
+	buf[0] = inclusionProofVersion
+	buf = binary.AppendUvarint(buf, uint64(p.Index))
+	buf = binary.AppendUvarint(buf, uint64(p.TreeSize))
+	buf = binary.AppendUvarint(buf, uint64(size))
+	buf = binary.AppendUvarint(buf, uint64(len(p.Siblings)))
+
+	for _, s := range p.Siblings {
+		if len(s) != size {
+			return nil, errors.New("siblings should have the same size")
+		}
+		buf = append(buf, s...)
+	}
+
+	return buf, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.UnmarshalBinary.
+func (p *InclusionProof) UnmarshalBinary(data []byte) error {
+	if len(data) < 1 {
+		return errors.New("inclusion proof is empty")
+	}
+	if data[0] != inclusionProofVersion {
+		return errors.New("unsupported inclusion proof version")
+	}
+	data = data[1:]
+
+	var fields [4]uint64
+	for i := range fields {
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("inclusion proof is truncated")
+		}
+		fields[i], data = v, data[n:]
+	}
+
+	// Check the header before allocating the siblings, since the number of
+	// siblings of a malformed proof can be arbitrarily large.
+	index, treeSize, size, count := fields[0], fields[1], fields[2], fields[3]
+	if size == 0 && count > 0 || count > uint64(len(data)) {
+		return errors.New("inclusion proof has an invalid length")
+	}
+	if count > 0 && size > uint64(len(data))/count || size*count != uint64(len(data)) {
+		return errors.New("inclusion proof has an invalid length")
+	}
+
+	p.Index = int(index)
+	p.TreeSize = int(treeSize)
+	p.Siblings = make([][]byte, count)
+	for i := range p.Siblings {
+		p.Siblings[i] = append([]byte(nil), data[:size]...)
+		data = data[size:]
+	}
+
+	return nil
+}
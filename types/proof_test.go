@@ -0,0 +1,224 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types_test
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/stratumn/merkle/testutil"
+	"github.com/stratumn/merkle/types"
+)
+
+var proofFixtures = []struct {
+	filename string
+	index    int
+	treeSize int
+}{
+	{"testdata/path-a-0.json", 0, 1},
+	{"testdata/path-ab-0.json", 0, 2},
+	{"testdata/path-ab-1.json", 1, 2},
+	{"testdata/path-abc-0.json", 0, 3},
+	{"testdata/path-abc-1.json", 1, 3},
+	{"testdata/path-abc-2.json", 2, 3},
+	{"testdata/path-abcd-0.json", 0, 4},
+	{"testdata/path-abcd-1.json", 1, 4},
+	{"testdata/path-abcd-2.json", 2, 4},
+	{"testdata/path-abcd-3.json", 3, 4},
+	{"testdata/path-abcde-0.json", 0, 5},
+	{"testdata/path-abcde-1.json", 1, 5},
+	{"testdata/path-abcde-2.json", 2, 5},
+	{"testdata/path-abcde-3.json", 3, 5},
+	{"testdata/path-abcde-4.json", 4, 5},
+}
+
+// Returns the leaf and root of a path fixture, where leaves are the hashes of
+// letters starting at "a".
+func pathEnds(path types.Path, index int) ([]byte, []byte) {
+	leaf := sha256.Sum256([]byte{byte('a' + index)})
+	return leaf[:], path[len(path)-1].Parent
+}
+
+func TestInclusionProof(t *testing.T) {
+	for _, f := range proofFixtures {
+		var path types.Path
+		if err := loadPath(f.filename, &path); err != nil {
+			t.Fatalf("loadPath(): err: %s", err)
+		}
+		if len(path) == 0 {
+			continue
+		}
+
+		leaf, root := pathEnds(path, f.index)
+
+		proof, err := types.NewInclusionProof(f.index, f.treeSize, path)
+		if err != nil {
+			t.Fatalf("%s: types.NewInclusionProof(): err: %s", f.filename, err)
+		}
+		if got, want := len(proof.Siblings), len(path); got != want {
+			t.Errorf("%s: len(proof.Siblings) = %d want %d", f.filename, got, want)
+		}
+
+		got, err := proof.Path(leaf)
+		if err != nil {
+			t.Fatalf("%s: proof.Path(): err: %s", f.filename, err)
+		}
+		if !reflect.DeepEqual(got, path) {
+			g, _ := json.MarshalIndent(got, "", "  ")
+			w, _ := json.MarshalIndent(path, "", "  ")
+			t.Errorf("%s: proof.Path() = %s\nwant %s", f.filename, g, w)
+		}
+
+		if err := proof.Verify(leaf, root); err != nil {
+			t.Errorf("%s: proof.Verify(): err: %s", f.filename, err)
+		}
+		if err := proof.Verify(leaf, testutil.RandomHash()); err != types.ErrRootMismatch {
+			t.Errorf("%s: proof.Verify(): err = %v want %v", f.filename, err, types.ErrRootMismatch)
+		}
+	}
+}
+
+func TestInclusionProof_singleLeaf(t *testing.T) {
+	leaf := testutil.RandomHash()
+
+	proof, err := types.NewInclusionProof(0, 1, types.Path{})
+	if err != nil {
+		t.Fatalf("types.NewInclusionProof(): err: %s", err)
+	}
+	if err := proof.Verify(leaf, leaf); err != nil {
+		t.Errorf("proof.Verify(): err: %s", err)
+	}
+}
+
+func TestNewInclusionProof_Error(t *testing.T) {
+	var pathABCDE0, pathInvalid1 types.Path
+	if err := loadPath("testdata/path-abcde-0.json", &pathABCDE0); err != nil {
+		t.Fatalf("loadPath(): err: %s", err)
+	}
+	if err := loadPath("testdata/path-invalid-1.json", &pathInvalid1); err != nil {
+		t.Fatalf("loadPath(): err: %s", err)
+	}
+
+	tests := []struct {
+		name     string
+		index    int
+		treeSize int
+		path     types.Path
+		expected error
+	}{
+		{"out of range", 5, 5, pathABCDE0, types.ErrIndexOutOfRange},
+		{"wrong length", 0, 4, pathABCDE0, types.ErrIndexMismatch},
+		{"broken chain", 2, 3, pathInvalid1, types.ErrIndexMismatch},
+	}
+
+	for _, test := range tests {
+		_, err := types.NewInclusionProof(test.index, test.treeSize, test.path)
+		if err != test.expected {
+			t.Errorf("%s: types.NewInclusionProof(): err = %v want %v", test.name, err, test.expected)
+		}
+	}
+}
+
+func TestInclusionProofJSON(t *testing.T) {
+	proof := &types.InclusionProof{
+		Index:    3,
+		TreeSize: 5,
+		Siblings: [][]byte{testutil.RandomHash(), testutil.RandomHash()},
+	}
+
+	data, err := json.Marshal(proof)
+	if err != nil {
+		t.Fatalf("json.Marshal(): err: %s", err)
+	}
+
+	var got types.InclusionProof
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("json.Unmarshal(): err: %s", err)
+	}
+	if !reflect.DeepEqual(&got, proof) {
+		t.Errorf("json.Unmarshal() = %#v want %#v", got, proof)
+	}
+}
+
+func TestInclusionProofBinary(t *testing.T) {
+	for _, n := range []int{0, 1, 20} {
+		proof := &types.InclusionProof{Index: 1 << uint(n), TreeSize: 1<<uint(n) + 1}
+		for i := 0; i < n; i++ {
+			proof.Siblings = append(proof.Siblings, testutil.RandomHash())
+		}
+
+		data, err := proof.MarshalBinary()
+		if err != nil {
+			t.Fatalf("proof.MarshalBinary(): err: %s", err)
+		}
+
+		var got types.InclusionProof
+		if err := got.UnmarshalBinary(data); err != nil {
+			t.Fatalf("got.UnmarshalBinary(): err: %s", err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(*proof) {
+			t.Errorf("got.UnmarshalBinary() = %v want %v", got, *proof)
+		}
+
+		for i := 0; i < len(data); i++ {
+			if err := got.UnmarshalBinary(data[:i]); err == nil {
+				t.Errorf("got.UnmarshalBinary(data[:%d]): err = nil want Error", i)
+			}
+		}
+	}
+}
+
+func TestInclusionProofBinary_Error(t *testing.T) {
+	proof := &types.InclusionProof{
+		Index:    1,
+		TreeSize: 3,
+		Siblings: [][]byte{testutil.RandomHash(), testutil.RandomHash()[:20]},
+	}
+	if _, err := proof.MarshalBinary(); err == nil {
+		t.Error("proof.MarshalBinary(): err = nil want Error")
+	}
+}
+
+func TestInclusionProofBinary_malformed(t *testing.T) {
+	header := func(fields ...uint64) []byte {
+		data := []byte{1}
+		for _, f := range fields {
+			data = binary.AppendUvarint(data, f)
+		}
+		return data
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty hashes", header(0, 1, 0, 1<<62)},
+		{"empty hashes with data", append(header(0, 1, 0, 3), 1, 2, 3)},
+		{"too many siblings", header(0, 1, 32, 1<<62)},
+		{"overflow", append(header(0, 1, 1<<62, 4), make([]byte, 4)...)},
+		{"trailing data", append(header(0, 1, 1, 1), 1, 2)},
+	}
+
+	for _, tt := range tests {
+		var got types.InclusionProof
+		if err := got.UnmarshalBinary(tt.data); err == nil {
+			t.Errorf("%s: got.UnmarshalBinary(): err = nil want Error", tt.name)
+		}
+	}
+}
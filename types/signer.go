@@ -0,0 +1,152 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidSignature is returned when a signature does not match the signed
+// data and the public key.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Signer signs data such as tree heads. Implementations must be safe for
+// concurrent use.
+type Signer interface {
+	// Public returns the public key of the signer.
+	Public() crypto.PublicKey
+
+	// Sign returns the signature of the data.
+	Sign(data []byte) ([]byte, error)
+}
+
+// Verifier checks signatures made by a Signer.
+type Verifier interface {
+	// Verify checks that the signature of the data is valid. It returns
+	// ErrInvalidSignature if it is not.
+	Verify(data, signature []byte) error
+}
+
+// NewVerifier creates a Verifier from an Ed25519 or ECDSA P-256 public key.
+func NewVerifier(key crypto.PublicKey) (Verifier, error) {
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		return NewEd25519Verifier(k)
+	case *ecdsa.PublicKey:
+		return NewECDSAVerifier(k)
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+}
+
+// Ed25519Signer is a Signer using Ed25519.
+type Ed25519Signer struct {
+	key ed25519.PrivateKey
+}
+
+// NewEd25519Signer creates a Signer from an Ed25519 private key.
+func NewEd25519Signer(key ed25519.PrivateKey) (*Ed25519Signer, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, errors.New("invalid Ed25519 private key")
+	}
+	return &Ed25519Signer{key: key}, nil
+}
+
+// Public implements Signer.Public.
+func (s *Ed25519Signer) Public() crypto.PublicKey {
+	return s.key.Public()
+}
+
+// Sign implements Signer.Sign.
+func (s *Ed25519Signer) Sign(data []byte) ([]byte, error) {
+	return ed25519.Sign(s.key, data), nil
+}
+
+// Ed25519Verifier is a Verifier using Ed25519.
+type Ed25519Verifier struct {
+	key ed25519.PublicKey
+}
+
+// NewEd25519Verifier creates a Verifier from an Ed25519 public key.
+func NewEd25519Verifier(key ed25519.PublicKey) (*Ed25519Verifier, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid Ed25519 public key")
+	}
+	return &Ed25519Verifier{key: key}, nil
+}
+
+// Verify implements Verifier.Verify.
+func (v *Ed25519Verifier) Verify(data, signature []byte) error {
+	if !ed25519.Verify(v.key, data, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// ECDSASigner is a Signer using ECDSA with the P-256 curve and SHA-256. The
+// signatures are ASN.1 encoded, as in RFC 6962.
+type ECDSASigner struct {
+	key *ecdsa.PrivateKey
+}
+
+// NewECDSASigner creates a Signer from an ECDSA private key, which must use
+// the P-256 curve.
+func NewECDSASigner(key *ecdsa.PrivateKey) (*ECDSASigner, error) {
+	if key == nil || key.Curve != elliptic.P256() {
+		return nil, errors.New("ECDSA private key should use the P-256 curve")
+	}
+	return &ECDSASigner{key: key}, nil
+}
+
+// Public implements Signer.Public.
+func (s *ECDSASigner) Public() crypto.PublicKey {
+	return &s.key.PublicKey
+}
+
+// Sign implements Signer.Sign.
+func (s *ECDSASigner) Sign(data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	return ecdsa.SignASN1(rand.Reader, s.key, digest[:])
+}
+
+// ECDSAVerifier is a Verifier using ECDSA with the P-256 curve and SHA-256.
+type ECDSAVerifier struct {
+	key *ecdsa.PublicKey
+}
+
+// NewECDSAVerifier creates a Verifier from an ECDSA public key, which must
+// use the P-256 curve.
+func NewECDSAVerifier(key *ecdsa.PublicKey) (*ECDSAVerifier, error) {
+	if key == nil || key.Curve != elliptic.P256() {
+		return nil, errors.New("ECDSA public key should use the P-256 curve")
+	}
+	return &ECDSAVerifier{key: key}, nil
+}
+
+// Verify implements Verifier.Verify.
+func (v *ECDSAVerifier) Verify(data, signature []byte) error {
+	digest := sha256.Sum256(data)
+	if !ecdsa.VerifyASN1(v.key, digest[:], signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"bytes"
+	"errors"
+)
+
+// SparseKeySize is the number of bytes of the keys of a sparse Merkle tree.
+// The tree has one level per bit of the key.
+const SparseKeySize = 32
+
+// ErrInvalidKey is returned when a key does not have SparseKeySize bytes.
+var ErrInvalidKey = errors.New("key should have 32 bytes")
+
+// sparseLeafPrefix is prepended to the key of a leaf before it is hashed.
+const sparseLeafPrefix = 0x00
+
+// SparseLeaf returns the hash of the leaf of a sparse Merkle tree that maps
+// the key to the value. If the value is nil, it returns the hash of an empty
+// leaf, which is only zeros.
+//
+// The leaf is the hash of a zero byte, the key and the hash of the value.
+// Since the value is hashed, the data hashed for a leaf has a different size
+// than for a node, so a leaf cannot be passed off as a node, and the other
+// way around, even when the hasher does not prefix leaves and nodes.
+func SparseLeaf(hasher Hasher, key, value []byte) []byte {
+	if value == nil {
+		return make([]byte, hasher.Size())
+	}
+
+	prefixed := append([]byte{sparseLeafPrefix}, key...)
+	return hasher.HashNode(prefixed, sparseValueHash(hasher, value))
+}
+
+// Returns the hash of a value, which has the size of the hashes of the
+// hasher.
+func sparseValueHash(hasher Hasher, value []byte) []byte {
+	if dh, ok := hasher.(DataHasher); ok {
+		h := dh.HashFunc()()
+		h.Write(value)
+		return h.Sum(nil)
+	}
+	return hasher.HashNode(value, nil)
+}
+
+// SparseKeyBit returns whether the node at the given height on the path of
+// the key is a right child.
+func SparseKeyBit(key []byte, height int) bool {
+	bit := SparseKeySize*8 - 1 - height
+	return key[bit/8]&(0x80>>uint(bit%8)) != 0
+}
+
+// VerifySparse checks that a path proves that the key maps to the value in a
+// sparse Merkle tree with the given root, using the default hasher. If the
+// value is nil, it checks that the key is not in the tree.
+func VerifySparse(key, value, root []byte, path Path) error {
+	return VerifySparseWith(DefaultHasher, key, value, root, path)
+}
+
+// VerifySparseWith checks that a path proves that the key maps to the value in
+// a sparse Merkle tree with the given root, using the given hasher. If the
+// value is nil, it checks that the key is not in the tree.
+func VerifySparseWith(hasher Hasher, key, value, root []byte, path Path) error {
+	if len(key) != SparseKeySize {
+		return ErrInvalidKey
+	}
+	if len(path) != SparseKeySize*8 {
+		return ErrIndexMismatch
+	}
+
+	if err := path.ValidateWith(hasher); err != nil {
+		return err
+	}
+
+	node := SparseLeaf(hasher, key, value)
+
+	for height, h := range path {
+		got := h.Left
+		if SparseKeyBit(key, height) {
+			got = h.Right
+		}
+
+		if !bytes.Equal(got, node) {
+			if height == 0 {
+				return ErrLeafMismatch
+			}
+			return ErrIndexMismatch
+		}
+
+		node = h.Parent
+	}
+
+	if !bytes.Equal(node, root) {
+		return ErrRootMismatch
+	}
+
+	return nil
+}
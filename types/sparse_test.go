@@ -0,0 +1,80 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stratumn/merkle/testutil"
+	"github.com/stratumn/merkle/types"
+)
+
+func TestSparseKeyBit(t *testing.T) {
+	key := make([]byte, types.SparseKeySize)
+	key[0] = 0x80
+	key[31] = 0x03
+
+	tests := []struct {
+		height   int
+		expected bool
+	}{
+		{0, true},
+		{1, true},
+		{2, false},
+		{254, false},
+		{255, true},
+	}
+
+	for _, test := range tests {
+		if got, want := types.SparseKeyBit(key, test.height), test.expected; got != want {
+			t.Errorf("types.SparseKeyBit(%d) = %v want %v", test.height, got, want)
+		}
+	}
+}
+
+func TestSparseLeaf(t *testing.T) {
+	key, value := testutil.RandomHash(), testutil.RandomHash()
+
+	if got := types.SparseLeaf(types.SHA256, key, nil); !bytes.Equal(got, make([]byte, 32)) {
+		t.Errorf("types.SparseLeaf(nil) = %x want zeros", got)
+	}
+	valueHash := sha256.Sum256(value)
+	want := sha256.Sum256(append(append([]byte{0x00}, key...), valueHash[:]...))
+	if got := types.SparseLeaf(types.SHA256, key, value); !bytes.Equal(got, want[:]) {
+		t.Errorf("types.SparseLeaf() = %x want %x", got, want)
+	}
+
+	// A leaf whose value has the size of a hash is not the parent of its key
+	// and its value.
+	if got, node := types.SparseLeaf(types.SHA256, key, value), types.SHA256.HashNode(key, value); bytes.Equal(got, node) {
+		t.Errorf("types.SparseLeaf() = %x want different from the node %x", got, node)
+	}
+	if got, node := types.SparseLeaf(types.RFC6962SHA256, key, value), types.RFC6962SHA256.HashNode(key, valueHash[:]); bytes.Equal(got, node) {
+		t.Errorf("types.SparseLeaf(RFC 6962) = %x want different from the node %x", got, node)
+	}
+}
+
+func TestVerifySparse_Error(t *testing.T) {
+	key := testutil.RandomHash()
+
+	if err := types.VerifySparse(key[:31], nil, nil, nil); err != types.ErrInvalidKey {
+		t.Errorf("types.VerifySparse(): err = %v want %v", err, types.ErrInvalidKey)
+	}
+	if err := types.VerifySparse(key, nil, nil, types.Path{}); err != types.ErrIndexMismatch {
+		t.Errorf("types.VerifySparse(): err = %v want %v", err, types.ErrIndexMismatch)
+	}
+}
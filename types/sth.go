@@ -0,0 +1,201 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// signedTreeHeadVersion is the version of the binary encoding of a
+// SignedTreeHead.
+const signedTreeHeadVersion = 1
+
+// SignedTreeHead is a signed statement that a tree had a given root when it
+// had a given number of leaves.
+type SignedTreeHead struct {
+	TreeSize int
+
+	// Timestamp is the number of milliseconds since the Unix epoch when the
+	// tree head was created.
+	Timestamp uint64
+
+	RootHash  []byte
+	Signature []byte
+}
+
+// NewSignedTreeHead creates an unsigned tree head with the current time as
+// timestamp.
+func NewSignedTreeHead(treeSize int, rootHash []byte) *SignedTreeHead {
+	return &SignedTreeHead{
+		TreeSize:  treeSize,
+		Timestamp: uint64(time.Now().UnixNano() / int64(time.Millisecond)),
+		RootHash:  rootHash,
+	}
+}
+
+// Time returns the timestamp of the tree head as a time.
+func (h *SignedTreeHead) Time() time.Time {
+	return time.Unix(0, int64(h.Timestamp)*int64(time.Millisecond))
+}
+
+// SignedData returns the data covered by the signature.
+//
+// It is the TreeHeadSignature structure of section 3.5 of RFC 6962: a version
+// byte of zero, a signature type byte of one, the timestamp and the tree size
+// as big-endian 64-bit integers and the root hash. The root hash is the last
+// field so its size does not need to be encoded, which allows hashers other
+// than SHA-256.
+func (h *SignedTreeHead) SignedData() ([]byte, error) {
+	if h.TreeSize < 0 {
+		return nil, ErrSizeOutOfRange
+	}
+
+	data := make([]byte, 0, 18+len(h.RootHash))
+	data = append(data, 0, 1)
+	data = binary.BigEndian.AppendUint64(data, h.Timestamp)
+	data = binary.BigEndian.AppendUint64(data, uint64(h.TreeSize))
+	data = append(data, h.RootHash...)
+
+	return data, nil
+}
+
+// Sign signs the tree head, replacing its signature.
+func (h *SignedTreeHead) Sign(signer Signer) error {
+	data, err := h.SignedData()
+	if err != nil {
+		return err
+	}
+
+	sig, err := signer.Sign(data)
+	if err != nil {
+		return err
+	}
+
+	h.Signature = sig
+
+	return nil
+}
+
+// Verify checks the signature of the tree head. It returns
+// ErrInvalidSignature if it is not valid.
+func (h *SignedTreeHead) Verify(verifier Verifier) error {
+	data, err := h.SignedData()
+	if err != nil {
+		return err
+	}
+
+	return verifier.Verify(data, h.Signature)
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.MarshalBinary.
+//
+// The encoding is a version byte followed by the tree size, the timestamp,
+// the size of the root hash and the size of the signature as unsigned
+// varints, followed by the root hash and the signature.
+func (h *SignedTreeHead) MarshalBinary() ([]byte, error) {
+	if h.TreeSize < 0 {
+		return nil, ErrSizeOutOfRange
+	}
+
+	buf := make([]byte, 1, 1+4*binary.MaxVarintLen64+len(h.RootHash)+len(h.Signature))
+	buf[0] = signedTreeHeadVersion
+	buf = binary.AppendUvarint(buf, uint64(h.TreeSize))
+	buf = binary.AppendUvarint(buf, h.Timestamp)
+	buf = binary.AppendUvarint(buf, uint64(len(h.RootHash)))
+	buf = binary.AppendUvarint(buf, uint64(len(h.Signature)))
+	buf = append(buf, h.RootHash...)
+	buf = append(buf, h.Signature...)
+
+	return buf, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.UnmarshalBinary.
+func (h *SignedTreeHead) UnmarshalBinary(data []byte) error {
+	if len(data) < 1 {
+		return errors.New("signed tree head is empty")
+	}
+	if data[0] != signedTreeHeadVersion {
+		return errors.New("unsupported signed tree head version")
+	}
+	data = data[1:]
+
+	var fields [4]uint64
+	for i := range fields {
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("signed tree head is truncated")
+		}
+		fields[i], data = v, data[n:]
+	}
+
+	treeSize, timestamp, rootLen, sigLen := fields[0], fields[1], fields[2], fields[3]
+	if treeSize > 1<<62 || rootLen > uint64(len(data)) || sigLen != uint64(len(data))-rootLen {
+		return errors.New("signed tree head has an invalid length")
+	}
+
+	h.TreeSize = int(treeSize)
+	h.Timestamp = timestamp
+	h.RootHash = append([]byte(nil), data[:rootLen]...)
+	h.Signature = append([]byte(nil), data[rootLen:]...)
+
+	return nil
+}
+
+// JSONSignedTreeHead is used to Marshal/Unmarshal SignedTreeHead type with hex
+// representation.
+type JSONSignedTreeHead struct {
+	TreeSize  int    `json:"treeSize"`
+	Timestamp uint64 `json:"timestamp"`
+	RootHash  string `json:"rootHash"`
+	Signature string `json:"signature"`
+}
+
+// MarshalJSON implements encoding/json.Marshaler.MarshalJSON.
+func (h *SignedTreeHead) MarshalJSON() ([]byte, error) {
+	return json.Marshal(JSONSignedTreeHead{
+		TreeSize:  h.TreeSize,
+		Timestamp: h.Timestamp,
+		RootHash:  hex.EncodeToString(h.RootHash),
+		Signature: hex.EncodeToString(h.Signature),
+	})
+}
+
+// UnmarshalJSON implements encoding/json.Unmarshaler.UnmarshalJSON.
+func (h *SignedTreeHead) UnmarshalJSON(data []byte) error {
+	var j JSONSignedTreeHead
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	rootHash, err := hex.DecodeString(j.RootHash)
+	if err != nil {
+		return err
+	}
+	signature, err := hex.DecodeString(j.Signature)
+	if err != nil {
+		return err
+	}
+
+	h.TreeSize = j.TreeSize
+	h.Timestamp = j.Timestamp
+	h.RootHash = rootHash
+	h.Signature = signature
+
+	return nil
+}
@@ -0,0 +1,210 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/stratumn/merkle/testutil"
+	"github.com/stratumn/merkle/types"
+)
+
+// signers returns a signer of each supported algorithm.
+func signers(t *testing.T) []struct {
+	name   string
+	signer types.Signer
+} {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey(): err: %s", err)
+	}
+	edSigner, err := types.NewEd25519Signer(edKey)
+	if err != nil {
+		t.Fatalf("types.NewEd25519Signer(): err: %s", err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey(): err: %s", err)
+	}
+	ecSigner, err := types.NewECDSASigner(ecKey)
+	if err != nil {
+		t.Fatalf("types.NewECDSASigner(): err: %s", err)
+	}
+
+	return []struct {
+		name   string
+		signer types.Signer
+	}{
+		{"Ed25519", edSigner},
+		{"ECDSA-P256", ecSigner},
+	}
+}
+
+func TestSignedTreeHead(t *testing.T) {
+	for _, s := range signers(t) {
+		verifier, err := types.NewVerifier(s.signer.Public())
+		if err != nil {
+			t.Fatalf("%s: types.NewVerifier(): err: %s", s.name, err)
+		}
+
+		sth := types.NewSignedTreeHead(42, testutil.RandomHash())
+		if err := sth.Sign(s.signer); err != nil {
+			t.Fatalf("%s: sth.Sign(): err: %s", s.name, err)
+		}
+		if err := sth.Verify(verifier); err != nil {
+			t.Errorf("%s: sth.Verify(): err: %s", s.name, err)
+		}
+
+		tests := []struct {
+			name   string
+			modify func(sth *types.SignedTreeHead)
+		}{
+			{"size", func(sth *types.SignedTreeHead) { sth.TreeSize++ }},
+			{"timestamp", func(sth *types.SignedTreeHead) { sth.Timestamp++ }},
+			{"root", func(sth *types.SignedTreeHead) { sth.RootHash = testutil.RandomHash() }},
+			{"signature", func(sth *types.SignedTreeHead) { sth.Signature[len(sth.Signature)/2] ^= 1 }},
+		}
+
+		for _, tt := range tests {
+			modified := *sth
+			modified.Signature = append([]byte(nil), sth.Signature...)
+			tt.modify(&modified)
+			if err := modified.Verify(verifier); err != types.ErrInvalidSignature {
+				t.Errorf("%s: %s: sth.Verify() err = %v want %v", s.name, tt.name, err, types.ErrInvalidSignature)
+			}
+		}
+	}
+}
+
+func TestSignedTreeHead_otherKey(t *testing.T) {
+	var (
+		ss     = signers(t)
+		others = signers(t)
+	)
+
+	for _, s := range ss {
+		sth := types.NewSignedTreeHead(1, testutil.RandomHash())
+		if err := sth.Sign(s.signer); err != nil {
+			t.Fatalf("%s: sth.Sign(): err: %s", s.name, err)
+		}
+
+		for _, other := range others {
+			verifier, err := types.NewVerifier(other.signer.Public())
+			if err != nil {
+				t.Fatalf("%s: types.NewVerifier(): err: %s", other.name, err)
+			}
+			if err := sth.Verify(verifier); err != types.ErrInvalidSignature {
+				t.Errorf("%s: sth.Verify() with other %s key err = %v want %v", s.name, other.name, err, types.ErrInvalidSignature)
+			}
+		}
+
+	}
+}
+
+func TestSignedTreeHeadSignedData(t *testing.T) {
+	root, _ := hex.DecodeString("6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d")
+	sth := types.SignedTreeHead{TreeSize: 1, Timestamp: 1500000000000, RootHash: root}
+
+	got, err := sth.SignedData()
+	if err != nil {
+		t.Fatalf("sth.SignedData(): err: %s", err)
+	}
+
+	want := "0001" + "0000015d3ef79800" + "0000000000000001" + hex.EncodeToString(root)
+	if hex.EncodeToString(got) != want {
+		t.Errorf("sth.SignedData() = %x want %s", got, want)
+	}
+}
+
+func TestSignedTreeHeadBinary(t *testing.T) {
+	s := signers(t)[0].signer
+
+	sth := types.NewSignedTreeHead(1<<40, testutil.RandomHash())
+	if err := sth.Sign(s); err != nil {
+		t.Fatalf("sth.Sign(): err: %s", err)
+	}
+
+	data, err := sth.MarshalBinary()
+	if err != nil {
+		t.Fatalf("sth.MarshalBinary(): err: %s", err)
+	}
+
+	var got types.SignedTreeHead
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary(): err: %s", err)
+	}
+	if !reflect.DeepEqual(&got, sth) {
+		t.Errorf("UnmarshalBinary() = %v want %v", got, sth)
+	}
+
+	for n := 0; n < len(data); n++ {
+		if err := got.UnmarshalBinary(data[:n]); err == nil {
+			t.Errorf("UnmarshalBinary(%d bytes): err = nil want Error", n)
+		}
+	}
+	if err := got.UnmarshalBinary(append(data, 0)); err == nil {
+		t.Error("UnmarshalBinary(extra byte): err = nil want Error")
+	}
+}
+
+func TestSignedTreeHeadJSON(t *testing.T) {
+	s := signers(t)[1].signer
+
+	sth := types.NewSignedTreeHead(3, testutil.RandomHash())
+	if err := sth.Sign(s); err != nil {
+		t.Fatalf("sth.Sign(): err: %s", err)
+	}
+
+	js, err := json.Marshal(sth)
+	if err != nil {
+		t.Fatalf("json.Marshal(): err: %s", err)
+	}
+
+	var got types.SignedTreeHead
+	if err := json.Unmarshal(js, &got); err != nil {
+		t.Fatalf("json.Unmarshal(): err: %s", err)
+	}
+	if !reflect.DeepEqual(&got, sth) {
+		t.Errorf("json.Unmarshal() = %v want %v", got, sth)
+	}
+}
+
+func TestNewSigner_Error(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey(): err: %s", err)
+	}
+
+	if _, err := types.NewECDSASigner(key); err == nil {
+		t.Error("types.NewECDSASigner(P-384): err = nil want Error")
+	}
+	if _, err := types.NewECDSAVerifier(&key.PublicKey); err == nil {
+		t.Error("types.NewECDSAVerifier(P-384): err = nil want Error")
+	}
+	if _, err := types.NewEd25519Signer(ed25519.PrivateKey{1, 2, 3}); err == nil {
+		t.Error("types.NewEd25519Signer(): err = nil want Error")
+	}
+	if _, err := types.NewVerifier("key"); err == nil {
+		t.Error("types.NewVerifier(string): err = nil want Error")
+	}
+}
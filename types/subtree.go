@@ -0,0 +1,115 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"math/bits"
+)
+
+// Subtree is the subtree of a tree containing the leaves from Start to End
+// (exclusive), as defined in section 2.1 of RFC 6962. The subtrees used in
+// proofs start at a multiple of the largest power of two smaller than their
+// number of leaves.
+type Subtree struct {
+	Start int
+	End   int
+}
+
+// NodePosition is the position of a node in a tree, where level zero contains
+// the leaves. The node at a given level and index is the root of the complete
+// subtree containing the leaves from Index<<Level to (Index+1)<<Level.
+type NodePosition struct {
+	Level int
+	Index int
+}
+
+// InclusionSubtrees returns the subtrees whose hashes are the siblings of the
+// audit path of the leaf at the given index in a tree of the given size,
+// bottom up, as described in section 2.1.1 of RFC 6962.
+func InclusionSubtrees(index, size int) []Subtree {
+	return appendInclusionSubtrees(nil, index, 0, size)
+}
+
+func appendInclusionSubtrees(subtrees []Subtree, m, start, end int) []Subtree {
+	if end-start == 1 {
+		return subtrees
+	}
+
+	k := splitPoint(end - start)
+	if m < start+k {
+		return append(appendInclusionSubtrees(subtrees, m, start, start+k), Subtree{start + k, end})
+	}
+
+	return append(appendInclusionSubtrees(subtrees, m, start+k, end), Subtree{start, start + k})
+}
+
+// ConsistencySubtrees returns the subtrees whose hashes make the proof that
+// the tree with the given old size is a prefix of the tree with the given new
+// size, as described in section 2.1.2 of RFC 6962.
+func ConsistencySubtrees(oldSize, newSize int) []Subtree {
+	return appendConsistencySubtrees(nil, oldSize, 0, newSize, true)
+}
+
+func appendConsistencySubtrees(subtrees []Subtree, m, start, end int, complete bool) []Subtree {
+	if m == end-start {
+		if complete {
+			return subtrees
+		}
+		return append(subtrees, Subtree{start, end})
+	}
+
+	k := splitPoint(end - start)
+	if m <= k {
+		return append(appendConsistencySubtrees(subtrees, m, start, start+k, complete), Subtree{start + k, end})
+	}
+
+	return append(appendConsistencySubtrees(subtrees, m-k, start+k, end, false), Subtree{start, start + k})
+}
+
+// Nodes returns the roots of the complete subtrees the hash of the subtree is
+// computed from, from left to right.
+func (s Subtree) Nodes() []NodePosition {
+	return s.appendNodes(nil)
+}
+
+func (s Subtree) appendNodes(nodes []NodePosition) []NodePosition {
+	n := s.End - s.Start
+	if n&(n-1) == 0 {
+		level := bits.TrailingZeros(uint(n))
+		return append(nodes, NodePosition{level, s.Start >> uint(level)})
+	}
+
+	k := splitPoint(n)
+	return Subtree{s.Start + k, s.End}.appendNodes(Subtree{s.Start, s.Start + k}.appendNodes(nodes))
+}
+
+// Hash computes the hash of the subtree using the given function to get the
+// hashes of the nodes returned by Nodes.
+func (s Subtree) Hash(hasher Hasher, node func(level, index int) []byte) []byte {
+	n := s.End - s.Start
+	if n&(n-1) == 0 {
+		level := bits.TrailingZeros(uint(n))
+		return node(level, s.Start>>uint(level))
+	}
+
+	k := splitPoint(n)
+	return hasher.HashNode(Subtree{s.Start, s.Start + k}.Hash(hasher, node), Subtree{s.Start + k, s.End}.Hash(hasher, node))
+}
+
+// Returns the largest power of two smaller than n, which must be greater than
+// one.
+func splitPoint(n int) int {
+	return 1 << uint(bits.Len(uint(n-1))-1)
+}
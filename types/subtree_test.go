@@ -0,0 +1,69 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stratumn/merkle/types"
+)
+
+// The examples are the trees of section 2.1.3 of RFC 6962, whose leaves are
+// named a to g.
+func TestInclusionSubtrees(t *testing.T) {
+	tests := []struct {
+		index, size int
+		want        []types.Subtree
+	}{
+		{0, 1, nil},
+		{0, 7, []types.Subtree{{1, 2}, {2, 4}, {4, 7}}},
+		{3, 7, []types.Subtree{{2, 3}, {0, 2}, {4, 7}}},
+		{4, 7, []types.Subtree{{5, 6}, {6, 7}, {0, 4}}},
+		{6, 7, []types.Subtree{{4, 6}, {0, 4}}},
+	}
+
+	for _, tt := range tests {
+		if got := types.InclusionSubtrees(tt.index, tt.size); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("types.InclusionSubtrees(%d, %d) = %v want %v", tt.index, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestConsistencySubtrees(t *testing.T) {
+	tests := []struct {
+		oldSize, newSize int
+		want             []types.Subtree
+	}{
+		{7, 7, nil},
+		{3, 7, []types.Subtree{{2, 3}, {3, 4}, {0, 2}, {4, 7}}},
+		{4, 7, []types.Subtree{{4, 7}}},
+		{6, 7, []types.Subtree{{4, 6}, {6, 7}, {0, 4}}},
+	}
+
+	for _, tt := range tests {
+		if got := types.ConsistencySubtrees(tt.oldSize, tt.newSize); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("types.ConsistencySubtrees(%d, %d) = %v want %v", tt.oldSize, tt.newSize, got, tt.want)
+		}
+	}
+}
+
+func TestSubtreeNodes(t *testing.T) {
+	got := types.Subtree{Start: 8, End: 15}.Nodes()
+	want := []types.NodePosition{{Level: 2, Index: 2}, {Level: 1, Index: 6}, {Level: 0, Index: 14}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Subtree.Nodes() = %v want %v", got, want)
+	}
+}
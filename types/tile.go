@@ -0,0 +1,389 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxTileHeight is the maximum height of a tile.
+const MaxTileHeight = 30
+
+// ErrInvalidTile is returned when a tile or its data is not valid.
+var ErrInvalidTile = errors.New("invalid tile")
+
+// Tile is a group of hashes of a tree, as used by the transparency log of the
+// Go checksum database. Tiles can be stored as static files and are never
+// modified once complete, which makes them easy to cache and serve.
+//
+// A tile of a given height at a given level contains the hashes of Width
+// consecutive nodes at level Level*Height of the tree, where level zero
+// contains the leaves, starting at node Index<<Height. A complete tile
+// contains 1<<Height hashes and a partial tile fewer. The nodes of the levels
+// between two tiles can be computed from the hashes of the tile below.
+//
+// Only the nodes of complete subtrees are stored in tiles, so orphans that are
+// promoted to the row above do not appear in them.
+type Tile struct {
+	Height int
+	Level  int
+	Index  int
+	Width  int
+}
+
+// Tile indices are encoded using three digits per path element to limit the
+// size of directories.
+const tilePathBase = 1000
+
+// Path returns the path of the tile, which has the form tile/H/L/NNN[.p/W],
+// where the .p/W suffix is only present for partial tiles. The index is
+// encoded using three digits per path element, and all but the last element
+// start with an x. For instance the path of a partial tile of height 3 at
+// level 4 with index 1234067 and width 1 is tile/3/4/x001/x234/067.p/1.
+func (t Tile) Path() string {
+	n := t.Index
+	index := fmt.Sprintf("%03d", n%tilePathBase)
+	for n >= tilePathBase {
+		n /= tilePathBase
+		index = fmt.Sprintf("x%03d/%s", n%tilePathBase, index)
+	}
+
+	partial := ""
+	if t.Width != 1<<uint(t.Height) {
+		partial = fmt.Sprintf(".p/%d", t.Width)
+	}
+
+	return fmt.Sprintf("tile/%d/%d/%s%s", t.Height, t.Level, index, partial)
+}
+
+// ParseTilePath parses the path of a tile returned by Tile.Path. Paths of
+// data tiles are not supported.
+func ParseTilePath(path string) (Tile, error) {
+	f := strings.Split(path, "/")
+	if len(f) < 4 || f[0] != "tile" {
+		return Tile{}, invalidTilePath(path)
+	}
+
+	height, err1 := strconv.Atoi(f[1])
+	level, err2 := strconv.Atoi(f[2])
+	if err1 != nil || err2 != nil || height < 1 || height > MaxTileHeight || level < 0 || level > 63 {
+		return Tile{}, invalidTilePath(path)
+	}
+
+	t := Tile{Height: height, Level: level, Width: 1 << uint(height)}
+
+	if last := f[len(f)-2]; strings.HasSuffix(last, ".p") {
+		width, err := strconv.Atoi(f[len(f)-1])
+		if err != nil || width < 1 || width >= t.Width {
+			return Tile{}, invalidTilePath(path)
+		}
+		t.Width = width
+		f[len(f)-2] = strings.TrimSuffix(last, ".p")
+		f = f[:len(f)-1]
+	}
+
+	for _, s := range f[3:] {
+		n, err := strconv.Atoi(strings.TrimPrefix(s, "x"))
+		if err != nil || n < 0 || n >= tilePathBase || t.Index > (1<<62)/tilePathBase {
+			return Tile{}, invalidTilePath(path)
+		}
+		t.Index = t.Index*tilePathBase + n
+	}
+
+	// Reject paths that are not in canonical form, such as 12 instead of 012.
+	if t.Path() != path {
+		return Tile{}, invalidTilePath(path)
+	}
+
+	return t, nil
+}
+
+func invalidTilePath(path string) error {
+	return fmt.Errorf("%w: malformed path %q", ErrInvalidTile, path)
+}
+
+// NewTiles returns the tiles of the given height that must be published when
+// a tree grows from the old size to the new size, bottom up. No tiles are
+// needed for a tree without leaves. It returns an error wrapping
+// ErrInvalidTile if the height is invalid.
+func NewTiles(height, oldSize, newSize int) ([]Tile, error) {
+	if height < 1 || height > MaxTileHeight {
+		return nil, fmt.Errorf("%w: height %d", ErrInvalidTile, height)
+	}
+	if oldSize < 0 || oldSize > newSize {
+		return nil, ErrSizeOutOfRange
+	}
+
+	var tiles []Tile
+
+	for level := 0; newSize>>uint(level*height) > 0; level++ {
+		var (
+			oldLen = oldSize >> uint(level*height)
+			newLen = newSize >> uint(level*height)
+		)
+
+		if oldLen == newLen {
+			continue
+		}
+
+		for n := oldLen >> uint(height); n < newLen>>uint(height); n++ {
+			tiles = append(tiles, Tile{Height: height, Level: level, Index: n, Width: 1 << uint(height)})
+		}
+
+		n := newLen >> uint(height)
+		if width := newLen - n<<uint(height); width > 0 {
+			tiles = append(tiles, Tile{Height: height, Level: level, Index: n, Width: width})
+		}
+	}
+
+	return tiles, nil
+}
+
+// TileReader reads the tiles of a tree, for instance from a directory or a
+// file server.
+type TileReader interface {
+	// Height returns the height of the tiles.
+	Height() int
+
+	// ReadTiles returns the data of each tile, which is the concatenation of
+	// the hashes it contains.
+	ReadTiles(tiles []Tile) ([][]byte, error)
+}
+
+// TileTree computes hashes and proofs of a tree of a given size using only
+// the tiles of the tree. It reads the tiles it needs on each call, so caching
+// should be done by the reader if needed.
+//
+// The tiles are not authenticated, so the hashes and proofs must be checked
+// against a trusted root. A proof computed from invalid tiles does not
+// verify.
+type TileTree struct {
+	reader TileReader
+	hasher Hasher
+	size   int
+}
+
+// NewTileTree creates a TileTree for a tree of the given size using the
+// default hasher.
+func NewTileTree(reader TileReader, treeSize int) *TileTree {
+	return NewTileTreeWith(DefaultHasher, reader, treeSize)
+}
+
+// NewTileTreeWith creates a TileTree for a tree of the given size using the
+// given hasher.
+func NewTileTreeWith(hasher Hasher, reader TileReader, treeSize int) *TileTree {
+	return &TileTree{reader: reader, hasher: hasher, size: treeSize}
+}
+
+// LeavesLen returns the number of leaves of the tree.
+func (t *TileTree) LeavesLen() int {
+	return t.size
+}
+
+// Root returns the root of the tree.
+func (t *TileTree) Root() ([]byte, error) {
+	if t.size < 1 {
+		return nil, ErrSizeOutOfRange
+	}
+
+	hashes, err := t.subtreeHashes([]Subtree{{0, t.size}})
+	if err != nil {
+		return nil, err
+	}
+
+	return hashes[0], nil
+}
+
+// Leaf returns the hash of the leaf at the given index.
+func (t *TileTree) Leaf(index int) ([]byte, error) {
+	if index < 0 || index >= t.size {
+		return nil, ErrIndexOutOfRange
+	}
+
+	hashes, err := t.subtreeHashes([]Subtree{{index, index + 1}})
+	if err != nil {
+		return nil, err
+	}
+
+	return hashes[0], nil
+}
+
+// InclusionProof returns a proof that the leaf at the given index is included
+// in the tree.
+func (t *TileTree) InclusionProof(index int) (*InclusionProof, error) {
+	if index < 0 || index >= t.size {
+		return nil, ErrIndexOutOfRange
+	}
+
+	siblings, err := t.subtreeHashes(InclusionSubtrees(index, t.size))
+	if err != nil {
+		return nil, err
+	}
+
+	return &InclusionProof{Index: index, TreeSize: t.size, Siblings: siblings}, nil
+}
+
+// ConsistencyProof returns a proof that the tree with the given old size is a
+// prefix of the tree, in the format described in section 2.1.2 of RFC 6962.
+func (t *TileTree) ConsistencyProof(oldSize int) ([][]byte, error) {
+	if oldSize < 1 || oldSize > t.size {
+		return nil, ErrSizeOutOfRange
+	}
+
+	return t.subtreeHashes(ConsistencySubtrees(oldSize, t.size))
+}
+
+// Returns the hashes of the given subtrees, reading all the tiles they need
+// at once.
+func (t *TileTree) subtreeHashes(subtrees []Subtree) ([][]byte, error) {
+	if len(subtrees) < 1 {
+		return nil, nil
+	}
+
+	var nodes []NodePosition
+	for _, s := range subtrees {
+		nodes = s.appendNodes(nodes)
+	}
+
+	known, err := t.readNodes(nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([][]byte, len(subtrees))
+	for i, s := range subtrees {
+		hashes[i] = s.Hash(t.hasher, func(level, index int) []byte {
+			return known[NodePosition{level, index}]
+		})
+	}
+
+	return hashes, nil
+}
+
+// Reads the hashes of the given nodes from the tiles that contain them.
+func (t *TileTree) readNodes(nodes []NodePosition) (map[NodePosition][]byte, error) {
+	var (
+		height = t.reader.Height()
+		order  = map[Tile]int{}
+		tiles  []Tile
+		places = make([]tilePlace, len(nodes))
+	)
+
+	if height < 1 || height > MaxTileHeight {
+		return nil, fmt.Errorf("%w: height %d", ErrInvalidTile, height)
+	}
+
+	for i, node := range nodes {
+		places[i] = nodeTile(height, t.size, node)
+		if _, ok := order[places[i].tile]; !ok {
+			order[places[i].tile] = len(tiles)
+			tiles = append(tiles, places[i].tile)
+		}
+	}
+
+	data, err := t.reader.ReadTiles(tiles)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != len(tiles) {
+		return nil, fmt.Errorf("%w: got %d tiles want %d", ErrInvalidTile, len(data), len(tiles))
+	}
+
+	for i, tile := range tiles {
+		if err := CheckTileData(t.hasher, tile, data[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	known := make(map[NodePosition][]byte, len(nodes))
+	for i, node := range nodes {
+		var (
+			tileData = data[order[places[i].tile]]
+			size     = len(tileData) / places[i].tile.Width
+		)
+		known[node] = t.tileHash(tileData[places[i].start*size:places[i].end*size], size)
+	}
+
+	return known, nil
+}
+
+// tilePlace is the position of the hashes a node is computed from within a
+// tile.
+type tilePlace struct {
+	tile       Tile
+	start, end int
+}
+
+// Returns the tile of a tree of the given size containing the hashes a
+// complete node is computed from. The tile has the width it has in the tree.
+func nodeTile(height, treeSize int, node NodePosition) tilePlace {
+	var (
+		level = node.Level / height
+		shift = uint(node.Level - level*height)
+		first = node.Index << shift
+		index = first >> uint(height)
+		count = treeSize >> uint(level*height)
+		width = 1 << uint(height)
+	)
+
+	if rest := count - index<<uint(height); rest < width {
+		width = rest
+	}
+
+	start := first - index<<uint(height)
+
+	return tilePlace{
+		tile:  Tile{Height: height, Level: level, Index: index, Width: width},
+		start: start,
+		end:   start + 1<<shift,
+	}
+}
+
+// CheckTileData checks that the data of a tile has the expected length. The
+// tiles above the leaves contain hashes of the size of the hasher, and the
+// tiles of the leaves contain hashes of the same size, which may differ from
+// the size of the hasher if the hasher does not hash leaves.
+func CheckTileData(hasher Hasher, tile Tile, data []byte) error {
+	if tile.Width < 1 {
+		return fmt.Errorf("%w: %s has width %d", ErrInvalidTile, tile.Path(), tile.Width)
+	}
+
+	if tile.Level == 0 {
+		if len(data) < tile.Width || len(data)%tile.Width != 0 {
+			return fmt.Errorf("%w: %s has %d bytes for %d hashes", ErrInvalidTile, tile.Path(), len(data), tile.Width)
+		}
+		return nil
+	}
+
+	if want := tile.Width * hasher.Size(); len(data) != want {
+		return fmt.Errorf("%w: %s has %d bytes want %d", ErrInvalidTile, tile.Path(), len(data), want)
+	}
+
+	return nil
+}
+
+// Computes the root of the complete subtree whose nodes at the level of a
+// tile are the given hashes of the given size.
+func (t *TileTree) tileHash(data []byte, size int) []byte {
+	if len(data) == size {
+		return data
+	}
+
+	n := len(data) / 2
+	return t.hasher.HashNode(t.tileHash(data[:n], size), t.tileHash(data[n:], size))
+}
@@ -0,0 +1,148 @@
+// Copyright 2017 Stratumn SAS. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stratumn/merkle/types"
+)
+
+func TestTilePath(t *testing.T) {
+	tests := []struct {
+		tile types.Tile
+		path string
+	}{
+		{types.Tile{Height: 3, Level: 4, Index: 1234067, Width: 1}, "tile/3/4/x001/x234/067.p/1"},
+		{types.Tile{Height: 3, Level: 4, Index: 1234067, Width: 8}, "tile/3/4/x001/x234/067"},
+		{types.Tile{Height: 8, Level: 0, Index: 0, Width: 256}, "tile/8/0/000"},
+		{types.Tile{Height: 8, Level: 1, Index: 999, Width: 17}, "tile/8/1/999.p/17"},
+		{types.Tile{Height: 8, Level: 1, Index: 1000, Width: 256}, "tile/8/1/x001/000"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.tile.Path(); got != tt.path {
+			t.Errorf("tile.Path() = %q want %q", got, tt.path)
+		}
+
+		got, err := types.ParseTilePath(tt.path)
+		if err != nil {
+			t.Errorf("types.ParseTilePath(%q): err: %s", tt.path, err)
+			continue
+		}
+		if got != tt.tile {
+			t.Errorf("types.ParseTilePath(%q) = %v want %v", tt.path, got, tt.tile)
+		}
+	}
+}
+
+func TestParseTilePath_Error(t *testing.T) {
+	paths := []string{
+		"",
+		"tile/8/0",
+		"tiles/8/0/000",
+		"tile/0/0/000",
+		"tile/31/0/000",
+		"tile/8/-1/000",
+		"tile/8/data/000",
+		"tile/8/0/00",
+		"tile/8/0/x000/001",
+		"tile/8/0/x001/1000",
+		"tile/8/0/000.p/0",
+		"tile/8/0/000.p/256",
+		"tile/8/0/000.p/x",
+	}
+
+	for _, path := range paths {
+		if _, err := types.ParseTilePath(path); !errors.Is(err, types.ErrInvalidTile) {
+			t.Errorf("types.ParseTilePath(%q) err = %v want %v", path, err, types.ErrInvalidTile)
+		}
+	}
+}
+
+func TestNewTiles(t *testing.T) {
+	tile := func(level, index, width int) types.Tile {
+		return types.Tile{Height: 2, Level: level, Index: index, Width: width}
+	}
+
+	tests := []struct {
+		oldSize, newSize int
+		want             []types.Tile
+	}{
+		{0, 0, nil},
+		{0, 1, []types.Tile{tile(0, 0, 1)}},
+		{0, 4, []types.Tile{tile(0, 0, 4), tile(1, 0, 1)}},
+		{3, 4, []types.Tile{tile(0, 0, 4), tile(1, 0, 1)}},
+		{4, 5, []types.Tile{tile(0, 1, 1)}},
+		{4, 15, []types.Tile{tile(0, 1, 4), tile(0, 2, 4), tile(0, 3, 3), tile(1, 0, 3)}},
+		{15, 16, []types.Tile{tile(0, 3, 4), tile(1, 0, 4), tile(2, 0, 1)}},
+	}
+
+	for _, tt := range tests {
+		got, err := types.NewTiles(2, tt.oldSize, tt.newSize)
+		if err != nil {
+			t.Fatalf("types.NewTiles(2, %d, %d): err: %s", tt.oldSize, tt.newSize, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("types.NewTiles(2, %d, %d) = %v want %v", tt.oldSize, tt.newSize, got, tt.want)
+		}
+	}
+}
+
+func TestNewTiles_Error(t *testing.T) {
+	tests := []struct {
+		height, oldSize, newSize int
+		err                      error
+	}{
+		{0, 0, 1, types.ErrInvalidTile},
+		{types.MaxTileHeight + 1, 0, 1, types.ErrInvalidTile},
+		{2, -1, 1, types.ErrSizeOutOfRange},
+		{2, 2, 1, types.ErrSizeOutOfRange},
+	}
+
+	for _, tt := range tests {
+		if _, err := types.NewTiles(tt.height, tt.oldSize, tt.newSize); !errors.Is(err, tt.err) {
+			t.Errorf("types.NewTiles(%d, %d, %d) err = %v want %v", tt.height, tt.oldSize, tt.newSize, err, tt.err)
+		}
+	}
+}
+
+func TestCheckTileData(t *testing.T) {
+	size := types.SHA256.Size()
+
+	tests := []struct {
+		name  string
+		tile  types.Tile
+		len   int
+		valid bool
+	}{
+		{"leaves", types.Tile{Height: 2, Level: 0, Index: 0, Width: 3}, 3 * 40, true},
+		{"leaves uneven", types.Tile{Height: 2, Level: 0, Index: 0, Width: 3}, 3*40 + 1, false},
+		{"nodes", types.Tile{Height: 2, Level: 1, Index: 0, Width: 3}, 3 * size, true},
+		{"nodes short", types.Tile{Height: 2, Level: 1, Index: 0, Width: 3}, 2 * size, false},
+	}
+
+	for _, tt := range tests {
+		err := types.CheckTileData(types.SHA256, tt.tile, make([]byte, tt.len))
+		if tt.valid && err != nil {
+			t.Errorf("%s: types.CheckTileData(): err: %s", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, types.ErrInvalidTile) {
+			t.Errorf("%s: types.CheckTileData() err = %v want %v", tt.name, err, types.ErrInvalidTile)
+		}
+	}
+}
@@ -21,6 +21,18 @@ import (
 	"github.com/stratumn/merkle/types"
 )
 
+// hashers contains the hashers the trees are tested with.
+var hashers = []struct {
+	name   string
+	hasher types.Hasher
+}{
+	{"SHA-256", types.SHA256},
+	{"SHA-512/256", types.SHA512_256},
+	{"SHA3-256", types.SHA3_256},
+	{"SHA-1", types.SHA1},
+	{"RFC6962-SHA-256", types.RFC6962SHA256},
+}
+
 func loadPath(filename string, path *types.Path) error {
 	data, err := ioutil.ReadFile(filename)
 	if err != nil {